@@ -0,0 +1,231 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"sort"
+	"sync"
+	"toysgo/config"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+var (
+	ErrKeyNotFound  = errors.New("signing key not found")
+	ErrKeyAlgorithm = errors.New("unsupported key algorithm")
+)
+
+// JWT 서명 키 (private가 nil이면 검증만 가능한 이전 키)
+type signingKey struct {
+	kid     string
+	method  jwt.SigningMethod
+	private crypto.PrivateKey
+	public  crypto.PublicKey
+}
+
+// 검증에 쓸 수 있는 키 목록과 현재 서명 키 (서명 키가 nil이면 SecretCode로 HS256 서명)
+// 키 교체 시 새 키를 추가해 서명 키로 바꾸고, 이전 키는 발급된 토큰이 만료될 때까지 목록에 남겨 둔다.
+var keys = struct {
+	sync.RWMutex
+	items   map[string]*signingKey
+	signing *signingKey
+}{items: map[string]*signingKey{}}
+
+// 설정된 키 파일을 읽어 서명 키 목록 구성 (서버 시작 시 호출)
+func LoadKeys() error {
+	items := map[string]*signingKey{}
+
+	for _, item := range config.JWTKeys {
+		if item.Kid == "" {
+			return fmt.Errorf("jwt key %s: kid is required", item.File)
+		}
+		if _, ok := items[item.Kid]; ok {
+			return fmt.Errorf("jwt key %s: duplicate kid", item.Kid)
+		}
+
+		key, err := loadKey(item)
+		if err != nil {
+			return fmt.Errorf("jwt key %s: %w", item.Kid, err)
+		}
+		items[item.Kid] = key
+	}
+
+	var signing *signingKey
+	if config.JWTSigningKid != "" {
+		signing = items[config.JWTSigningKid]
+		if signing == nil {
+			return fmt.Errorf("jwt signing key %s: %w", config.JWTSigningKid, ErrKeyNotFound)
+		}
+		if signing.private == nil {
+			return fmt.Errorf("jwt signing key %s: private key required", config.JWTSigningKid)
+		}
+	}
+
+	keys.Lock()
+	keys.items = items
+	keys.signing = signing
+	keys.Unlock()
+
+	if signing != nil {
+		fmt.Printf("🔑 JWT 서명 키 %s (%s), 검증 키 %d개\n", signing.kid, signing.method.Alg(), len(items))
+	}
+	return nil
+}
+
+func loadKey(item config.JWTKey) (*signingKey, error) {
+	data, err := os.ReadFile(item.File)
+	if err != nil {
+		return nil, err
+	}
+
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("invalid PEM file")
+	}
+
+	key := &signingKey{kid: item.Kid}
+
+	switch block.Type {
+	case "PUBLIC KEY":
+		key.public, err = x509.ParsePKIXPublicKey(block.Bytes)
+	case "RSA PRIVATE KEY":
+		key.private, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "EC PRIVATE KEY":
+		key.private, err = x509.ParseECPrivateKey(block.Bytes)
+	case "PRIVATE KEY":
+		key.private, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	default:
+		err = fmt.Errorf("unsupported PEM type %s", block.Type)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if key.private != nil {
+		signer, ok := key.private.(crypto.Signer)
+		if !ok {
+			return nil, fmt.Errorf("%w: %s", ErrKeyAlgorithm, item.Alg)
+		}
+		key.public = signer.Public()
+	}
+
+	// 알고리즘과 키 종류가 맞아야 함 (ES256은 P-256 곡선만)
+	switch public := key.public.(type) {
+	case *rsa.PublicKey:
+		if item.Alg == jwt.SigningMethodRS256.Alg() {
+			key.method = jwt.SigningMethodRS256
+		}
+	case *ecdsa.PublicKey:
+		if item.Alg == jwt.SigningMethodES256.Alg() && public.Curve == elliptic.P256() {
+			key.method = jwt.SigningMethodES256
+		}
+	case ed25519.PublicKey:
+		if item.Alg == jwt.SigningMethodEdDSA.Alg() {
+			key.method = jwt.SigningMethodEdDSA
+		}
+	}
+	if key.method == nil {
+		return nil, fmt.Errorf("%w: %s", ErrKeyAlgorithm, item.Alg)
+	}
+
+	return key, nil
+}
+
+// 현재 서명 키로 토큰 서명 (kid 헤더 포함)
+func signToken(claims jwt.Claims) (string, error) {
+	keys.RLock()
+	signing := keys.signing
+	keys.RUnlock()
+
+	if signing == nil {
+		token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+		return token.SignedString([]byte(config.SecretCode))
+	}
+
+	token := jwt.NewWithClaims(signing.method, claims)
+	token.Header["kid"] = signing.kid
+	return token.SignedString(signing.private)
+}
+
+// 토큰 검증 키 선택 (kid가 있으면 해당 키, 없으면 HS256 SecretCode)
+func verifyKey(token *jwt.Token) (interface{}, error) {
+	keys.RLock()
+	defer keys.RUnlock()
+
+	if kid, ok := token.Header["kid"].(string); ok && kid != "" {
+		key := keys.items[kid]
+		if key == nil {
+			return nil, ErrKeyNotFound
+		}
+		if token.Method.Alg() != key.method.Alg() {
+			return nil, errors.New("Unexpected Signing Method")
+		}
+		return key.public, nil
+	}
+
+	// 비대칭 키로 바꾼 뒤에는 이전 HS256 토큰을 JWTAcceptHMAC 설정이 켜져 있을 때만 받음
+	if keys.signing != nil && !config.JWTAcceptHMAC {
+		return nil, errors.New("Unexpected Signing Method")
+	}
+	if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+		return nil, errors.New("Unexpected Signing Method")
+	}
+	return []byte(config.SecretCode), nil
+}
+
+// 다른 서비스가 토큰을 검증할 수 있도록 공개 키를 JWK Set 형식으로 반환 (HS256 비밀키는 제외)
+func JWKS() map[string]interface{} {
+	keys.RLock()
+	defer keys.RUnlock()
+
+	kids := make([]string, 0, len(keys.items))
+	for kid := range keys.items {
+		kids = append(kids, kid)
+	}
+	sort.Strings(kids)
+
+	items := make([]map[string]interface{}, 0, len(kids))
+	for _, kid := range kids {
+		key := keys.items[kid]
+		jwk := map[string]interface{}{
+			"kid": key.kid,
+			"alg": key.method.Alg(),
+			"use": "sig",
+		}
+
+		switch public := key.public.(type) {
+		case *rsa.PublicKey:
+			jwk["kty"] = "RSA"
+			jwk["n"] = encodeBase64(public.N.Bytes())
+			jwk["e"] = encodeBase64(big.NewInt(int64(public.E)).Bytes())
+		case *ecdsa.PublicKey:
+			size := (public.Curve.Params().BitSize + 7) / 8
+			jwk["kty"] = "EC"
+			jwk["crv"] = public.Curve.Params().Name
+			jwk["x"] = encodeBase64(public.X.FillBytes(make([]byte, size)))
+			jwk["y"] = encodeBase64(public.Y.FillBytes(make([]byte, size)))
+		case ed25519.PublicKey:
+			jwk["kty"] = "OKP"
+			jwk["crv"] = "Ed25519"
+			jwk["x"] = encodeBase64(public)
+		}
+
+		items = append(items, jwk)
+	}
+
+	return map[string]interface{}{"keys": items}
+}
+
+func encodeBase64(data []byte) string {
+	return base64.RawURLEncoding.EncodeToString(data)
+}
@@ -0,0 +1,297 @@
+package auth
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"toysgo/config"
+	"toysgo/models"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// 테스트용 키 파일 (PKCS#8 개인 키 또는 PKIX 공개 키)
+func writeKeyFile(t *testing.T, name string, key interface{}, public bool) string {
+	t.Helper()
+
+	var block *pem.Block
+	if public {
+		der, err := x509.MarshalPKIXPublicKey(key)
+		if err != nil {
+			t.Fatalf("marshal public key: %v", err)
+		}
+		block = &pem.Block{Type: "PUBLIC KEY", Bytes: der}
+	} else {
+		der, err := x509.MarshalPKCS8PrivateKey(key)
+		if err != nil {
+			t.Fatalf("marshal private key: %v", err)
+		}
+		block = &pem.Block{Type: "PRIVATE KEY", Bytes: der}
+	}
+
+	file := filepath.Join(t.TempDir(), name+".pem")
+	if err := os.WriteFile(file, pem.EncodeToMemory(block), 0600); err != nil {
+		t.Fatalf("write key: %v", err)
+	}
+	return file
+}
+
+// 키 설정을 바꿔 다시 읽고, 테스트가 끝나면 이전 설정과 키 목록으로 되돌림
+func loadTestKeys(t *testing.T, signing string, items ...config.JWTKey) error {
+	t.Helper()
+
+	savedKeys, savedKid, savedHMAC := config.JWTKeys, config.JWTSigningKid, config.JWTAcceptHMAC
+	t.Cleanup(func() {
+		config.JWTKeys, config.JWTSigningKid, config.JWTAcceptHMAC = savedKeys, savedKid, savedHMAC
+		keys.Lock()
+		keys.items = map[string]*signingKey{}
+		keys.signing = nil
+		keys.Unlock()
+	})
+
+	config.JWTKeys = items
+	config.JWTSigningKid = signing
+	return LoadKeys()
+}
+
+func testKeys(t *testing.T) (*rsa.PrivateKey, *ecdsa.PrivateKey, ed25519.PrivateKey) {
+	t.Helper()
+
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("rsa key: %v", err)
+	}
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("ec key: %v", err)
+	}
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("ed25519 key: %v", err)
+	}
+	return rsaKey, ecKey, edKey
+}
+
+func tokenKid(t *testing.T, token string) string {
+	t.Helper()
+
+	parsed, _, err := jwt.NewParser().ParseUnverified(token, &Claims{})
+	if err != nil {
+		t.Fatalf("parse header: %v", err)
+	}
+	kid, _ := parsed.Header["kid"].(string)
+	return kid
+}
+
+func TestLoadKeys(t *testing.T) {
+	rsaKey, ecKey, edKey := testKeys(t)
+	p384, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	if err != nil {
+		t.Fatalf("p384 key: %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		key     interface{}
+		public  bool
+		alg     string
+		signing bool
+		err     error
+	}{
+		{"RS256", rsaKey, false, "RS256", true, nil},
+		{"ES256", ecKey, false, "ES256", true, nil},
+		{"EdDSA", edKey, false, "EdDSA", true, nil},
+		{"RSA key as ES256", rsaKey, false, "ES256", false, ErrKeyAlgorithm},
+		{"P-384 key as ES256", p384, false, "ES256", false, ErrKeyAlgorithm},
+		{"Ed25519 key as RS256", edKey, false, "RS256", false, ErrKeyAlgorithm},
+		{"public key cannot sign", &rsaKey.PublicKey, true, "RS256", true, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			file := writeKeyFile(t, "key", tt.key, tt.public)
+			signing := ""
+			if tt.signing {
+				signing = "k1"
+			}
+
+			err := loadTestKeys(t, signing, config.JWTKey{Kid: "k1", Alg: tt.alg, File: file})
+			if tt.public {
+				if err == nil {
+					t.Fatalf("public key accepted as signing key")
+				}
+				return
+			}
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("err = %v, want %v", err, tt.err)
+			}
+			if tt.err != nil {
+				return
+			}
+
+			token, err := GenerateToken(&models.User{Id: 7}, "")
+			if err != nil {
+				t.Fatalf("GenerateToken: %v", err)
+			}
+			if kid := tokenKid(t, token); kid != "k1" {
+				t.Fatalf("kid = %q, want k1", kid)
+			}
+
+			claims, err := ParseToken(token)
+			if err != nil {
+				t.Fatalf("ParseToken: %v", err)
+			}
+			if claims.UserID() != 7 {
+				t.Fatalf("user = %d, want 7", claims.UserID())
+			}
+		})
+	}
+}
+
+func TestLoadKeysConfig(t *testing.T) {
+	rsaKey, _, _ := testKeys(t)
+	file := writeKeyFile(t, "rsa", rsaKey, false)
+
+	tests := []struct {
+		name    string
+		signing string
+		items   []config.JWTKey
+	}{
+		{"missing kid", "", []config.JWTKey{{Alg: "RS256", File: file}}},
+		{"duplicate kid", "", []config.JWTKey{{Kid: "a", Alg: "RS256", File: file}, {Kid: "a", Alg: "RS256", File: file}}},
+		{"unknown signing kid", "b", []config.JWTKey{{Kid: "a", Alg: "RS256", File: file}}},
+		{"missing file", "", []config.JWTKey{{Kid: "a", Alg: "RS256", File: filepath.Join(t.TempDir(), "none.pem")}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := loadTestKeys(t, tt.signing, tt.items...); err == nil {
+				t.Fatalf("LoadKeys succeeded, want error")
+			}
+		})
+	}
+}
+
+// 서명 키를 바꿔도 이전 키로 서명된 토큰은 키가 목록에 남아 있는 동안 검증됨
+func TestKeyRotation(t *testing.T) {
+	rsaKey, ecKey, _ := testKeys(t)
+	oldFile := writeKeyFile(t, "old", rsaKey, false)
+	oldPublic := writeKeyFile(t, "old-public", &rsaKey.PublicKey, true)
+	newFile := writeKeyFile(t, "new", ecKey, false)
+
+	if err := loadTestKeys(t, "old", config.JWTKey{Kid: "old", Alg: "RS256", File: oldFile}); err != nil {
+		t.Fatalf("LoadKeys: %v", err)
+	}
+	oldToken, err := GenerateToken(&models.User{Id: 1}, "")
+	if err != nil {
+		t.Fatalf("GenerateToken: %v", err)
+	}
+
+	// 새 키로 서명하고 이전 키는 공개 키만 남김
+	err = loadTestKeys(t, "new",
+		config.JWTKey{Kid: "old", Alg: "RS256", File: oldPublic},
+		config.JWTKey{Kid: "new", Alg: "ES256", File: newFile},
+	)
+	if err != nil {
+		t.Fatalf("LoadKeys: %v", err)
+	}
+
+	newToken, err := GenerateToken(&models.User{Id: 2}, "")
+	if err != nil {
+		t.Fatalf("GenerateToken: %v", err)
+	}
+	if kid := tokenKid(t, newToken); kid != "new" {
+		t.Fatalf("kid = %q, want new", kid)
+	}
+
+	for _, token := range []string{oldToken, newToken} {
+		if _, err := ParseToken(token); err != nil {
+			t.Fatalf("ParseToken(%s): %v", tokenKid(t, token), err)
+		}
+	}
+
+	jwks := JWKS()["keys"].([]map[string]interface{})
+	if len(jwks) != 2 {
+		t.Fatalf("jwks has %d keys, want 2", len(jwks))
+	}
+	want := []struct{ kid, alg, kty string }{{"new", "ES256", "EC"}, {"old", "RS256", "RSA"}}
+	for i, key := range jwks {
+		if key["kid"] != want[i].kid || key["alg"] != want[i].alg || key["kty"] != want[i].kty || key["use"] != "sig" {
+			t.Fatalf("jwks[%d] = %v, want %+v", i, key, want[i])
+		}
+		if _, ok := key["d"]; ok {
+			t.Fatalf("jwks[%d] exposes a private key", i)
+		}
+	}
+
+	// 이전 키를 목록에서 빼면 그 키로 서명된 토큰은 거부
+	if err := loadTestKeys(t, "new", config.JWTKey{Kid: "new", Alg: "ES256", File: newFile}); err != nil {
+		t.Fatalf("LoadKeys: %v", err)
+	}
+	if _, err := ParseToken(oldToken); !errors.Is(err, ErrKeyNotFound) {
+		t.Fatalf("old token err = %v, want %v", err, ErrKeyNotFound)
+	}
+	if _, err := ParseToken(newToken); err != nil {
+		t.Fatalf("ParseToken(new): %v", err)
+	}
+}
+
+func TestVerifyKeySelection(t *testing.T) {
+	rsaKey, _, _ := testKeys(t)
+	file := writeKeyFile(t, "rsa", rsaKey, false)
+	public, err := x509.MarshalPKIXPublicKey(&rsaKey.PublicKey)
+	if err != nil {
+		t.Fatalf("marshal public key: %v", err)
+	}
+
+	claims := func() *Claims {
+		return &Claims{RegisteredClaims: jwt.RegisteredClaims{Subject: "1"}}
+	}
+	sign := func(method jwt.SigningMethod, kid string, key interface{}) string {
+		token := jwt.NewWithClaims(method, claims())
+		if kid != "" {
+			token.Header["kid"] = kid
+		}
+		signed, err := token.SignedString(key)
+		if err != nil {
+			t.Fatalf("sign: %v", err)
+		}
+		return signed
+	}
+
+	tests := []struct {
+		name       string
+		acceptHMAC bool
+		token      func() string
+		ok         bool
+	}{
+		{"signed with current key", false, func() string { return sign(jwt.SigningMethodRS256, "rsa", rsaKey) }, true},
+		{"unknown kid", false, func() string { return sign(jwt.SigningMethodRS256, "other", rsaKey) }, false},
+		{"HS256 with public key as secret", true, func() string { return sign(jwt.SigningMethodHS256, "rsa", public) }, false},
+		{"HS256 without kid rejected", false, func() string { return sign(jwt.SigningMethodHS256, "", []byte(config.SecretCode)) }, false},
+		{"HS256 without kid accepted", true, func() string { return sign(jwt.SigningMethodHS256, "", []byte(config.SecretCode)) }, true},
+		{"RS256 without kid", true, func() string { return sign(jwt.SigningMethodRS256, "", rsaKey) }, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := loadTestKeys(t, "rsa", config.JWTKey{Kid: "rsa", Alg: "RS256", File: file}); err != nil {
+				t.Fatalf("LoadKeys: %v", err)
+			}
+			config.JWTAcceptHMAC = tt.acceptHMAC
+
+			_, err := ParseToken(tt.token())
+			if (err == nil) != tt.ok {
+				t.Fatalf("ParseToken err = %v, want ok %v", err, tt.ok)
+			}
+		})
+	}
+}
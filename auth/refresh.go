@@ -0,0 +1,144 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+	"toysgo/config"
+	"toysgo/global"
+	"toysgo/models"
+)
+
+var (
+	ErrRefreshTokenInvalid = errors.New("invalid refresh token")
+	ErrRefreshTokenExpired = errors.New("refresh token expired")
+	ErrRefreshTokenReused  = errors.New("refresh token reused")
+)
+
+// 리프레시 토큰 저장소 (models.AuthManager가 구현)
+type refreshStore interface {
+	Insert(item *models.Auth) error
+	GetByToken(token string, args ...interface{}) *models.Auth
+	Rotate(id int64) (bool, error)
+	RevokeFamily(family string) error
+}
+
+// 리프레시 토큰은 임의의 불투명 문자열이며 DB에는 SHA-256 해시만 저장한다.
+func hashRefreshToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+func randomString(size int) (string, error) {
+	buf := make([]byte, size)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
+
+// family에 속한 새 리프레시 토큰 저장 후 원본 토큰 반환
+func insertRefreshToken(manager refreshStore, user int64, family string, info SessionInfo) (*models.Auth, string, error) {
+	token, err := randomString(32)
+	if err != nil {
+		return nil, "", err
+	}
+
+	item := models.Auth{
+		User:       user,
+		Token:      hashRefreshToken(token),
+		Family:     family,
+		Status:     models.AuthStatusActive,
+		Expiredate: global.GetDate(time.Now().Add(time.Duration(config.RefreshTokenExpire) * time.Hour)),
+		Device:     info.Device,
+		Ip:         info.Ip,
+		Useragent:  info.Useragent,
+	}
+
+	if err := manager.Insert(&item); err != nil {
+		return nil, "", err
+	}
+
+	return &item, token, nil
+}
+
+// 로그인 시 새 토큰 family(세션)를 만들고 리프레시 토큰 발급
+// 반환된 Auth의 Family가 세션 ID이며 액세스 토큰의 sid로 넣는다.
+func IssueRefreshToken(conn *sql.DB, user int64, info SessionInfo) (*models.Auth, string, error) {
+	family, err := randomString(16)
+	if err != nil {
+		return nil, "", err
+	}
+
+	manager := models.NewAuthManager(conn)
+	return insertRefreshToken(manager, user, family, info)
+}
+
+// 리프레시 토큰을 사용하고 같은 family의 새 토큰으로 교체
+// 이미 교체된 토큰이 다시 사용되면 탈취로 보고 family 전체를 폐기한다.
+// 새 토큰에는 요청한 기기 정보를 기록해 세션의 마지막 사용 시각과 위치로 쓴다.
+func RotateRefreshToken(conn *sql.DB, token string, info SessionInfo) (*models.User, *models.Auth, string, error) {
+	return rotateRefreshToken(models.NewAuthManager(conn), models.NewUserManager(conn).Get, token, info)
+}
+
+func rotateRefreshToken(manager refreshStore, loadUser func(int64) *models.User, token string, info SessionInfo) (*models.User, *models.Auth, string, error) {
+	if token == "" {
+		return nil, nil, "", ErrRefreshTokenInvalid
+	}
+
+	current := manager.GetByToken(hashRefreshToken(token))
+	if current == nil {
+		return nil, nil, "", ErrRefreshTokenInvalid
+	}
+
+	switch current.Status {
+	case models.AuthStatusActive:
+	case models.AuthStatusRotated:
+		revokeRefreshFamily(manager, current)
+		return nil, nil, "", ErrRefreshTokenReused
+	default:
+		return nil, nil, "", ErrRefreshTokenInvalid
+	}
+
+	expire, err := time.ParseInLocation("2006-01-02 15:04:05", current.Expiredate, time.Local)
+	if err != nil || time.Now().After(expire) {
+		return nil, nil, "", ErrRefreshTokenExpired
+	}
+
+	// 동시에 같은 토큰으로 요청하면 한 요청만 교체에 성공하고 나머지는 재사용으로 처리
+	ok, err := manager.Rotate(current.Id)
+	if err != nil {
+		return nil, nil, "", err
+	}
+	if !ok {
+		revokeRefreshFamily(manager, current)
+		return nil, nil, "", ErrRefreshTokenReused
+	}
+
+	user := loadUser(current.User)
+	if user == nil {
+		revokeSession(manager, current.Family)
+		return nil, nil, "", ErrRefreshTokenInvalid
+	}
+
+	item, next, err := insertRefreshToken(manager, current.User, current.Family, info)
+	if err != nil {
+		return nil, nil, "", err
+	}
+
+	return user, item, next, nil
+}
+
+func revokeRefreshFamily(manager refreshStore, current *models.Auth) {
+	fmt.Printf("🚨 리프레시 토큰 재사용 감지: 사용자 %d, 토큰 family 폐기\n", current.User)
+	if err := manager.RevokeFamily(current.Family); err != nil {
+		fmt.Printf("Error revoking refresh token family: %v\n", err)
+		return
+	}
+	markSessionRevoked(current.Family)
+}
@@ -0,0 +1,233 @@
+package auth
+
+import (
+	"errors"
+	"testing"
+	"time"
+	"toysgo/global"
+	"toysgo/models"
+)
+
+// 메모리 리프레시 토큰 저장소 (조회 시 DB처럼 복사본을 반환)
+type memoryRefreshStore struct {
+	items []*models.Auth
+	// 교체 직전에 다른 요청이 먼저 교체한 상황을 흉내 냄
+	lostRace bool
+}
+
+func (s *memoryRefreshStore) Insert(item *models.Auth) error {
+	item.Id = int64(len(s.items) + 1)
+	saved := *item
+	s.items = append(s.items, &saved)
+	return nil
+}
+
+func (s *memoryRefreshStore) GetByToken(token string, args ...interface{}) *models.Auth {
+	for _, item := range s.items {
+		if item.Token == token {
+			found := *item
+			return &found
+		}
+	}
+	return nil
+}
+
+func (s *memoryRefreshStore) Rotate(id int64) (bool, error) {
+	for _, item := range s.items {
+		if item.Id == id && item.Status == models.AuthStatusActive && !s.lostRace {
+			item.Status = models.AuthStatusRotated
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (s *memoryRefreshStore) RevokeFamily(family string) error {
+	for _, item := range s.items {
+		if item.Family == family {
+			item.Status = models.AuthStatusRevoked
+		}
+	}
+	return nil
+}
+
+func (s *memoryRefreshStore) status(token string) int {
+	item := s.GetByToken(hashRefreshToken(token))
+	if item == nil {
+		return -1
+	}
+	return item.Status
+}
+
+func isMarkedRevoked(session string) bool {
+	revokedSessions.RLock()
+	defer revokedSessions.RUnlock()
+
+	_, ok := revokedSessions.items[session]
+	return ok
+}
+
+func TestRotateRefreshToken(t *testing.T) {
+	users := func(id int64) *models.User {
+		if id != 1 {
+			return nil
+		}
+		return &models.User{Id: id, Name: "tester"}
+	}
+
+	tests := []struct {
+		name string
+		// 저장소를 준비하고 사용할 토큰 반환
+		setup   func(t *testing.T, store *memoryRefreshStore) string
+		err     error
+		revoked bool
+	}{
+		{
+			name: "active token rotates",
+			setup: func(t *testing.T, store *memoryRefreshStore) string {
+				return issueTestToken(t, store, 1)
+			},
+		},
+		{
+			name: "reused token revokes family",
+			setup: func(t *testing.T, store *memoryRefreshStore) string {
+				token := issueTestToken(t, store, 1)
+				if _, _, _, err := rotateRefreshToken(store, users, token, SessionInfo{}); err != nil {
+					t.Fatalf("first rotation: %v", err)
+				}
+				return token
+			},
+			err:     ErrRefreshTokenReused,
+			revoked: true,
+		},
+		{
+			name: "concurrent rotation revokes family",
+			setup: func(t *testing.T, store *memoryRefreshStore) string {
+				token := issueTestToken(t, store, 1)
+				store.lostRace = true
+				return token
+			},
+			err:     ErrRefreshTokenReused,
+			revoked: true,
+		},
+		{
+			name: "revoked token",
+			setup: func(t *testing.T, store *memoryRefreshStore) string {
+				token := issueTestToken(t, store, 1)
+				store.RevokeFamily(store.items[0].Family)
+				return token
+			},
+			err: ErrRefreshTokenInvalid,
+		},
+		{
+			name: "expired token",
+			setup: func(t *testing.T, store *memoryRefreshStore) string {
+				token := issueTestToken(t, store, 1)
+				store.items[0].Expiredate = global.GetDate(time.Now().Add(-time.Minute))
+				return token
+			},
+			err: ErrRefreshTokenExpired,
+		},
+		{
+			name: "unknown token",
+			setup: func(t *testing.T, store *memoryRefreshStore) string {
+				issueTestToken(t, store, 1)
+				return "unknown"
+			},
+			err: ErrRefreshTokenInvalid,
+		},
+		{
+			name: "empty token",
+			setup: func(t *testing.T, store *memoryRefreshStore) string {
+				return ""
+			},
+			err: ErrRefreshTokenInvalid,
+		},
+		{
+			name: "deleted user revokes family",
+			setup: func(t *testing.T, store *memoryRefreshStore) string {
+				return issueTestToken(t, store, 2)
+			},
+			err:     ErrRefreshTokenInvalid,
+			revoked: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := &memoryRefreshStore{}
+			token := tt.setup(t, store)
+
+			user, item, next, err := rotateRefreshToken(store, users, token, SessionInfo{Device: "test"})
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("err = %v, want %v", err, tt.err)
+			}
+
+			if tt.revoked {
+				family := store.items[0].Family
+				for _, item := range store.items {
+					if item.Status != models.AuthStatusRevoked {
+						t.Fatalf("token %d status = %d, want revoked", item.Id, item.Status)
+					}
+				}
+				if !isMarkedRevoked(family) {
+					t.Fatalf("session %s not marked revoked", family)
+				}
+			}
+
+			if tt.err != nil {
+				return
+			}
+
+			if user == nil || user.Id != 1 {
+				t.Fatalf("user = %v, want id 1", user)
+			}
+			if next == "" || next == token {
+				t.Fatalf("next token = %q, want a new token", next)
+			}
+			if item.Family != store.items[0].Family || item.Device != "test" {
+				t.Fatalf("item = %+v, want same family with new device info", item)
+			}
+			if status := store.status(token); status != models.AuthStatusRotated {
+				t.Fatalf("old token status = %d, want rotated", status)
+			}
+			if status := store.status(next); status != models.AuthStatusActive {
+				t.Fatalf("new token status = %d, want active", status)
+			}
+		})
+	}
+}
+
+// 재사용이 감지되면 이미 교체받은 최신 토큰도 더 이상 쓸 수 없어야 함
+func TestRotateRefreshTokenReuseRevokesLatest(t *testing.T) {
+	users := func(id int64) *models.User { return &models.User{Id: id} }
+
+	store := &memoryRefreshStore{}
+	first := issueTestToken(t, store, 1)
+
+	_, _, second, err := rotateRefreshToken(store, users, first, SessionInfo{})
+	if err != nil {
+		t.Fatalf("rotate: %v", err)
+	}
+
+	if _, _, _, err := rotateRefreshToken(store, users, first, SessionInfo{}); !errors.Is(err, ErrRefreshTokenReused) {
+		t.Fatalf("reuse err = %v, want %v", err, ErrRefreshTokenReused)
+	}
+	if _, _, _, err := rotateRefreshToken(store, users, second, SessionInfo{}); !errors.Is(err, ErrRefreshTokenInvalid) {
+		t.Fatalf("latest err = %v, want %v", err, ErrRefreshTokenInvalid)
+	}
+}
+
+func issueTestToken(t *testing.T, store *memoryRefreshStore, user int64) string {
+	t.Helper()
+
+	family, err := randomString(16)
+	if err != nil {
+		t.Fatalf("randomString: %v", err)
+	}
+	_, token, err := insertRefreshToken(store, user, family, SessionInfo{})
+	if err != nil {
+		t.Fatalf("insertRefreshToken: %v", err)
+	}
+	return token
+}
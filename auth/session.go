@@ -0,0 +1,161 @@
+package auth
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+	"toysgo/config"
+	"toysgo/global"
+	"toysgo/models"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+var ErrSessionRevoked = errors.New("session revoked")
+
+// 로그인 세션(리프레시 토큰 family)을 만든 기기 정보
+type SessionInfo struct {
+	Device    string
+	Ip        string
+	Useragent string
+}
+
+// 요청에서 기기 정보 추출 (X-Device 헤더가 없으면 User-Agent로 추정)
+func NewSessionInfo(c *fiber.Ctx) SessionInfo {
+	useragent := c.Get(fiber.HeaderUserAgent)
+
+	device := strings.TrimSpace(c.Get("X-Device"))
+	if device == "" {
+		device = deviceFromUserAgent(useragent)
+	}
+
+	if len(device) > 100 {
+		device = device[:100]
+	}
+	if len(useragent) > 255 {
+		useragent = useragent[:255]
+	}
+
+	return SessionInfo{Device: device, Ip: c.IP(), Useragent: useragent}
+}
+
+func deviceFromUserAgent(useragent string) string {
+	ua := strings.ToLower(useragent)
+
+	switch {
+	case ua == "":
+		return "unknown"
+	case strings.Contains(ua, "iphone"):
+		return "iPhone"
+	case strings.Contains(ua, "ipad"):
+		return "iPad"
+	case strings.Contains(ua, "android"):
+		return "Android"
+	case strings.Contains(ua, "windows"):
+		return "Windows"
+	case strings.Contains(ua, "macintosh"), strings.Contains(ua, "mac os"):
+		return "Mac"
+	case strings.Contains(ua, "linux"):
+		return "Linux"
+	}
+	return "unknown"
+}
+
+// 폐기된 세션 캐시 (세션 ID -> 캐시에서 지워도 되는 시각)
+// 액세스 토큰마다 DB를 조회하지 않도록 메모리에서 확인하고, 다른 인스턴스에서 폐기한 세션은 주기적으로 다시 읽는다.
+var revokedSessions = struct {
+	sync.RWMutex
+	items map[string]time.Time
+}{items: map[string]time.Time{}}
+
+var revocationOnce sync.Once
+
+func IsSessionRevoked(session string) bool {
+	if session == "" {
+		return false
+	}
+
+	revocationOnce.Do(startSessionRevocations)
+
+	revokedSessions.RLock()
+	expire, ok := revokedSessions.items[session]
+	revokedSessions.RUnlock()
+
+	return ok && time.Now().Before(expire)
+}
+
+// 세션 폐기 (리프레시 토큰 family 전체를 폐기하고 해당 세션의 액세스 토큰도 거부)
+func RevokeSession(conn *sql.DB, session string) error {
+	return revokeSession(models.NewAuthManager(conn), session)
+}
+
+func revokeSession(manager refreshStore, session string) error {
+	if err := manager.RevokeFamily(session); err != nil {
+		return err
+	}
+
+	markSessionRevoked(session)
+	return nil
+}
+
+// 폐기된 세션의 액세스 토큰은 길어야 TokenExpire 동안만 유효하므로 그 이후에는 캐시에서 지운다.
+func markSessionRevoked(session string) {
+	revokedSessions.Lock()
+	revokedSessions.items[session] = time.Now().Add(TokenExpire)
+	revokedSessions.Unlock()
+}
+
+// 요청 처리를 막지 않도록 DB 조회는 별도 고루틴에서 수행
+func startSessionRevocations() {
+	go func() {
+		conn := models.NewConnection()
+
+		loadRevokedSessions(conn)
+
+		interval := time.Duration(config.SessionRevocationInterval) * time.Second
+		if interval <= 0 {
+			return
+		}
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for range ticker.C {
+			loadRevokedSessions(conn)
+		}
+	}()
+}
+
+func loadRevokedSessions(conn *sql.DB) {
+	if conn == nil {
+		return
+	}
+
+	now := time.Now()
+	manager := models.NewAuthManager(conn)
+	families := manager.FindRevokedFamilies(global.GetDate(now.Add(-TokenExpire)))
+
+	revokedSessions.Lock()
+	defer revokedSessions.Unlock()
+
+	for session, expire := range revokedSessions.items {
+		if now.After(expire) {
+			delete(revokedSessions.items, session)
+		}
+	}
+
+	added := 0
+	for _, session := range families {
+		if _, ok := revokedSessions.items[session]; !ok {
+			revokedSessions.items[session] = now.Add(TokenExpire)
+			added++
+		}
+	}
+
+	if added > 0 {
+		fmt.Printf("🔒 폐기된 세션 %d개 로드\n", added)
+	}
+}
@@ -0,0 +1,132 @@
+package auth
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+	"toysgo/config"
+	"toysgo/models"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// 액세스 토큰 유효기간
+const TokenExpire = time.Hour * 6
+
+// 기본 역할과 권한 범위
+const (
+	RoleUser = "user"
+	ScopeAPI = "api"
+)
+
+var (
+	ErrTokenInvalid = errors.New("invalid token")
+	ErrUserNotFound = errors.New("user not found")
+)
+
+// 액세스 토큰 클레임 (sub: 사용자 ID, sid: 로그인 세션)
+// User는 이전 형식 토큰을 읽을 때만 쓰며 새 토큰에는 넣지 않는다.
+type Claims struct {
+	Roles   []string     `json:"roles,omitempty"`
+	Scopes  []string     `json:"scopes,omitempty"`
+	Session string       `json:"sid,omitempty"`
+	User    *models.User `json:"user,omitempty"`
+	jwt.RegisteredClaims
+}
+
+func (c *Claims) UserID() int64 {
+	id, _ := strconv.ParseInt(c.Subject, 10, 64)
+	return id
+}
+
+func (c *Claims) HasRole(role string) bool {
+	for _, item := range c.Roles {
+		if item == role {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *Claims) HasScope(scope string) bool {
+	for _, item := range c.Scopes {
+		if item == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// 액세스 토큰 생성 (session이 폐기되면 토큰도 거부됨)
+func GenerateToken(user *models.User, session string) (string, error) {
+	id, err := randomString(16)
+	if err != nil {
+		return "", err
+	}
+
+	now := time.Now()
+	claims := Claims{
+		Roles:   []string{RoleUser},
+		Scopes:  []string{ScopeAPI},
+		Session: session,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject:   strconv.FormatInt(user.Id, 10),
+			ID:        id,
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(TokenExpire)),
+		},
+	}
+
+	return signToken(&claims)
+}
+
+// 액세스 토큰 검증 ("Bearer " 접두사 허용, 폐기된 세션의 토큰은 거부)
+// 사용자 정보를 담은 이전 형식 토큰은 AuthLegacyToken 설정이 켜져 있을 때만 받는다.
+func ParseToken(tokenString string) (*Claims, error) {
+	tokenString = strings.TrimPrefix(tokenString, "Bearer ")
+	if tokenString == "" {
+		return nil, errors.New("empty token")
+	}
+
+	claims := Claims{}
+	if _, err := jwt.ParseWithClaims(tokenString, &claims, verifyKey); err != nil {
+		return nil, err
+	}
+
+	if claims.Subject == "" {
+		if claims.User == nil || claims.User.Id == 0 || !config.AuthLegacyToken {
+			return nil, ErrTokenInvalid
+		}
+		claims.Subject = strconv.FormatInt(claims.User.Id, 10)
+		claims.Roles = []string{RoleUser}
+		claims.Scopes = []string{ScopeAPI}
+	}
+	// 토큰에 담긴 사용자 정보는 오래됐을 수 있으므로 쓰지 않음
+	claims.User = nil
+
+	if claims.UserID() == 0 {
+		return nil, ErrTokenInvalid
+	}
+
+	if IsSessionRevoked(claims.Session) {
+		return nil, ErrSessionRevoked
+	}
+
+	return &claims, nil
+}
+
+// 액세스 토큰 검증 후 현재 사용자 정보 조회
+func Authenticate(tokenString string) (*Claims, *models.User, error) {
+	claims, err := ParseToken(tokenString)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	user := LoadUser(claims.UserID())
+	if user == nil {
+		return nil, nil, ErrUserNotFound
+	}
+
+	return claims, user, nil
+}
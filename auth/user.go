@@ -0,0 +1,70 @@
+package auth
+
+import (
+	"sync"
+	"time"
+	"toysgo/config"
+	"toysgo/models"
+)
+
+type cachedUser struct {
+	user   models.User
+	expire time.Time
+}
+
+// 토큰 사용자 캐시 (사용자 ID -> 비밀번호를 뺀 사용자 정보)
+// 요청마다 DB를 조회하지 않도록 AuthUserCacheTTL 동안 보관하고, 정보가 바뀌면 ForgetUser로 지운다.
+var users = struct {
+	sync.RWMutex
+	items map[int64]cachedUser
+}{items: map[int64]cachedUser{}}
+
+// 사용자 조회 (없으면 nil, 호출자마다 별도 복사본 반환)
+func LoadUser(id int64) *models.User {
+	if id == 0 {
+		return nil
+	}
+
+	now := time.Now()
+
+	users.RLock()
+	item, ok := users.items[id]
+	users.RUnlock()
+
+	if ok && now.Before(item.expire) {
+		user := item.user
+		user.InitExtra()
+		return &user
+	}
+
+	conn := models.NewConnection()
+	defer conn.Close()
+
+	manager := models.NewUserManager(conn)
+	user := manager.Get(id)
+	if user == nil {
+		ForgetUser(id)
+		return nil
+	}
+	user.Passwd = ""
+
+	ttl := time.Duration(config.AuthUserCacheTTL) * time.Second
+	if ttl > 0 {
+		users.Lock()
+		for key, value := range users.items {
+			if now.After(value.expire) {
+				delete(users.items, key)
+			}
+		}
+		users.items[id] = cachedUser{user: *user, expire: now.Add(ttl)}
+		users.Unlock()
+	}
+
+	return user
+}
+
+func ForgetUser(id int64) {
+	users.Lock()
+	delete(users.items, id)
+	users.Unlock()
+}
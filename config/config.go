@@ -12,12 +12,151 @@ var (
 	SecretCode       string
 	Port             string
 	UploadPath       string
+
+	// 시그널링 소켓 인증
+	AllowAnonymousViewer bool
+	WsAuthTimeout        int
+
+	// 웹소켓 송신 큐
+	WsSendQueueSize      int
+	WsWriteTimeout       int
+	WsSlowConsumerPolicy string
+
+	// 하트비트 및 유휴 연결 정리 (초)
+	WsPingInterval int
+	WsIdleTimeout  int
+	WsReapInterval int
+
+	// 방송자 재접속 유예 시간 (초, 0이면 즉시 종료)
+	BroadcasterReconnectGrace int
+
+	// 채팅
+	ChatMaxLength   int
+	ChatBacklogSize int
+	ChatRateLimit   int
+	ChatRateWindow  int
+
+	// 비공개 방송 초대 토큰 유효 시간 (초, 요청 시 더 짧게 지정 가능)
+	InviteTokenTTL int
+
+	// 방송별 최대 시청자 수 (0이면 제한 없음, 방송자 지정 값은 MaxViewersLimit를 넘을 수 없음)
+	MaxViewers      int
+	MaxViewersLimit int
+
+	// 기본 방송 전송 방식 (mesh 또는 sfu, 방송 시작 시 지정 가능)
+	BroadcastMode string
+
+	// SFU 방송의 HLS 출력 (세그먼트 길이 초, 재생 목록에 남길 세그먼트 수)
+	HLSEnabled         bool
+	HLSSegmentDuration int
+	HLSPlaylistSize    int
+
+	// ICE 서버 (클라이언트와 서버 PeerConnection에 같은 목록 사용)
+	ICEServers []ICEServer
+
+	// TURN REST API 임시 자격 증명 (공유 비밀키로 사용자별 자격 증명 발급, 유효 시간 초)
+	TURNURLs          []string
+	TURNSecret        string
+	TURNCredentialTTL int
+
+	// 내장 TURN 서버 (UDP/TCP 수신 주소, 릴레이 공인 IP와 포트 범위, 사용자별 최대 할당 수, 익명 자격 증명별 최대 할당 수)
+	TURNServerEnabled bool
+	TURNListenUDP     string
+	TURNListenTCP     string
+	TURNRealm         string
+	TURNRelayIP       string
+	TURNRelayMinPort  int
+	TURNRelayMaxPort  int
+	TURNUserQuota     int
+	TURNAnonQuota     int
+
+	// 통화 품질 수집 (수집 주기 초, DB 저장 여부)
+	TelemetryInterval int
+	TelemetryPersist  bool
+
+	// 비밀번호 해시 (bcrypt 또는 argon2id, 알고리즘별 비용)
+	PasswordHash          string
+	PasswordBcryptCost    int
+	PasswordArgon2Time    int
+	PasswordArgon2Memory  int
+	PasswordArgon2Threads int
+
+	// 리프레시 토큰 유효기간 (시간)
+	RefreshTokenExpire int
+
+	// 폐기된 세션 목록을 DB에서 다시 읽는 주기 (초)
+	SessionRevocationInterval int
+
+	// 사용자 정보를 담은 이전 형식 토큰 허용 여부, 토큰 사용자 캐시 유지 시간 (초)
+	AuthLegacyToken  bool
+	AuthUserCacheTTL int
+
+	// JWT 서명 키 (비어 있으면 SecretCode로 HS256 서명), 서명에 쓸 키 ID, kid 없는 HS256 토큰 허용 여부
+	JWTKeys       []JWTKey
+	JWTSigningKid string
+	JWTAcceptHMAC bool
 )
 
+// ICE 서버 설정 (RTCIceServer 형식)
+type ICEServer struct {
+	URLs       []string `mapstructure:"urls"`
+	Username   string   `mapstructure:"username"`
+	Credential string   `mapstructure:"credential"`
+}
+
+// JWT 서명 키 (alg: RS256, ES256, EdDSA / file: PEM 개인 키, 검증만 할 키는 공개 키)
+type JWTKey struct {
+	Kid  string `mapstructure:"kid"`
+	Alg  string `mapstructure:"alg"`
+	File string `mapstructure:"file"`
+}
+
 func init() {
 	UploadPath = "webdata"
 	Database = "mysql"
 	Port = "9000"
+	AllowAnonymousViewer = true
+	WsAuthTimeout = 5
+	WsSendQueueSize = 64
+	WsWriteTimeout = 10
+	WsSlowConsumerPolicy = "disconnect"
+	WsPingInterval = 25
+	WsIdleTimeout = 60
+	WsReapInterval = 30
+	BroadcasterReconnectGrace = 30
+	ChatMaxLength = 300
+	ChatBacklogSize = 100
+	ChatRateLimit = 5
+	ChatRateWindow = 10
+	InviteTokenTTL = 86400
+	MaxViewers = 0
+	MaxViewersLimit = 0
+	BroadcastMode = "mesh"
+	HLSEnabled = true
+	HLSSegmentDuration = 2
+	HLSPlaylistSize = 6
+	ICEServers = []ICEServer{{URLs: []string{"stun:stun.l.google.com:19302"}}}
+	TURNCredentialTTL = 86400
+	TURNServerEnabled = false
+	TURNListenUDP = "0.0.0.0:3478"
+	TURNRealm = "toysgo"
+	TURNRelayIP = "127.0.0.1"
+	TURNRelayMinPort = 49152
+	TURNRelayMaxPort = 65535
+	TURNUserQuota = 10
+	TURNAnonQuota = 2
+	TelemetryInterval = 10
+	TelemetryPersist = true
+	PasswordHash = "bcrypt"
+	PasswordBcryptCost = 12
+	PasswordArgon2Time = 3
+	PasswordArgon2Memory = 65536
+	PasswordArgon2Threads = 2
+	RefreshTokenExpire = 24 * 7
+	SessionRevocationInterval = 30
+	AuthLegacyToken = true
+	AuthUserCacheTTL = 60
+	JWTAcceptHMAC = true
 
 	// err := godotenv.Load()
 
@@ -59,4 +198,200 @@ func init() {
 	if value := viper.Get("uploadPath"); value != nil {
 		UploadPath = value.(string)
 	}
+
+	if viper.IsSet("allowAnonymousViewer") {
+		AllowAnonymousViewer = viper.GetBool("allowAnonymousViewer")
+	}
+
+	if viper.IsSet("wsAuthTimeout") {
+		WsAuthTimeout = viper.GetInt("wsAuthTimeout")
+	}
+
+	if viper.IsSet("wsSendQueueSize") {
+		WsSendQueueSize = viper.GetInt("wsSendQueueSize")
+	}
+
+	if viper.IsSet("wsWriteTimeout") {
+		WsWriteTimeout = viper.GetInt("wsWriteTimeout")
+	}
+
+	if value := viper.Get("wsSlowConsumerPolicy"); value != nil {
+		WsSlowConsumerPolicy = value.(string)
+	}
+
+	if viper.IsSet("wsPingInterval") {
+		WsPingInterval = viper.GetInt("wsPingInterval")
+	}
+
+	if viper.IsSet("wsIdleTimeout") {
+		WsIdleTimeout = viper.GetInt("wsIdleTimeout")
+	}
+
+	if viper.IsSet("wsReapInterval") {
+		WsReapInterval = viper.GetInt("wsReapInterval")
+	}
+
+	if viper.IsSet("broadcasterReconnectGrace") {
+		BroadcasterReconnectGrace = viper.GetInt("broadcasterReconnectGrace")
+	}
+
+	if viper.IsSet("chatMaxLength") {
+		ChatMaxLength = viper.GetInt("chatMaxLength")
+	}
+
+	if viper.IsSet("chatBacklogSize") {
+		ChatBacklogSize = viper.GetInt("chatBacklogSize")
+	}
+
+	if viper.IsSet("chatRateLimit") {
+		ChatRateLimit = viper.GetInt("chatRateLimit")
+	}
+
+	if viper.IsSet("chatRateWindow") {
+		ChatRateWindow = viper.GetInt("chatRateWindow")
+	}
+
+	if viper.IsSet("inviteTokenTtl") {
+		InviteTokenTTL = viper.GetInt("inviteTokenTtl")
+	}
+
+	if viper.IsSet("maxViewers") {
+		MaxViewers = viper.GetInt("maxViewers")
+	}
+
+	if viper.IsSet("maxViewersLimit") {
+		MaxViewersLimit = viper.GetInt("maxViewersLimit")
+	}
+
+	if value := viper.Get("broadcastMode"); value != nil {
+		BroadcastMode = value.(string)
+	}
+
+	if viper.IsSet("hlsEnabled") {
+		HLSEnabled = viper.GetBool("hlsEnabled")
+	}
+
+	if viper.IsSet("hlsSegmentDuration") {
+		HLSSegmentDuration = viper.GetInt("hlsSegmentDuration")
+	}
+
+	if viper.IsSet("hlsPlaylistSize") {
+		HLSPlaylistSize = viper.GetInt("hlsPlaylistSize")
+	}
+
+	if viper.IsSet("iceServers") {
+		var servers []ICEServer
+		if err := viper.UnmarshalKey("iceServers", &servers); err != nil {
+			panic(fmt.Errorf("Fatal error config iceServers: %s \n", err))
+		}
+		ICEServers = servers
+	}
+
+	if viper.IsSet("turnUrls") {
+		TURNURLs = viper.GetStringSlice("turnUrls")
+	}
+
+	if value := viper.Get("turnSecret"); value != nil {
+		TURNSecret = value.(string)
+	}
+
+	if viper.IsSet("turnCredentialTtl") {
+		TURNCredentialTTL = viper.GetInt("turnCredentialTtl")
+	}
+
+	if viper.IsSet("turnServerEnabled") {
+		TURNServerEnabled = viper.GetBool("turnServerEnabled")
+	}
+
+	if value := viper.Get("turnListenUdp"); value != nil {
+		TURNListenUDP = value.(string)
+	}
+
+	if value := viper.Get("turnListenTcp"); value != nil {
+		TURNListenTCP = value.(string)
+	}
+
+	if value := viper.Get("turnRealm"); value != nil {
+		TURNRealm = value.(string)
+	}
+
+	if value := viper.Get("turnRelayIp"); value != nil {
+		TURNRelayIP = value.(string)
+	}
+
+	if viper.IsSet("turnRelayMinPort") {
+		TURNRelayMinPort = viper.GetInt("turnRelayMinPort")
+	}
+
+	if viper.IsSet("turnRelayMaxPort") {
+		TURNRelayMaxPort = viper.GetInt("turnRelayMaxPort")
+	}
+
+	if viper.IsSet("turnUserQuota") {
+		TURNUserQuota = viper.GetInt("turnUserQuota")
+	}
+
+	if viper.IsSet("turnAnonQuota") {
+		TURNAnonQuota = viper.GetInt("turnAnonQuota")
+	}
+
+	if viper.IsSet("telemetryInterval") {
+		TelemetryInterval = viper.GetInt("telemetryInterval")
+	}
+
+	if viper.IsSet("telemetryPersist") {
+		TelemetryPersist = viper.GetBool("telemetryPersist")
+	}
+
+	if value := viper.Get("passwordHash"); value != nil {
+		PasswordHash = value.(string)
+	}
+
+	if viper.IsSet("passwordBcryptCost") {
+		PasswordBcryptCost = viper.GetInt("passwordBcryptCost")
+	}
+
+	if viper.IsSet("passwordArgon2Time") {
+		PasswordArgon2Time = viper.GetInt("passwordArgon2Time")
+	}
+
+	if viper.IsSet("passwordArgon2Memory") {
+		PasswordArgon2Memory = viper.GetInt("passwordArgon2Memory")
+	}
+
+	if viper.IsSet("passwordArgon2Threads") {
+		PasswordArgon2Threads = viper.GetInt("passwordArgon2Threads")
+	}
+
+	if viper.IsSet("refreshTokenExpire") {
+		RefreshTokenExpire = viper.GetInt("refreshTokenExpire")
+	}
+
+	if viper.IsSet("sessionRevocationInterval") {
+		SessionRevocationInterval = viper.GetInt("sessionRevocationInterval")
+	}
+
+	if viper.IsSet("authLegacyToken") {
+		AuthLegacyToken = viper.GetBool("authLegacyToken")
+	}
+
+	if viper.IsSet("authUserCacheTtl") {
+		AuthUserCacheTTL = viper.GetInt("authUserCacheTtl")
+	}
+
+	if viper.IsSet("jwtKeys") {
+		var keys []JWTKey
+		if err := viper.UnmarshalKey("jwtKeys", &keys); err != nil {
+			panic(fmt.Errorf("Fatal error config jwtKeys: %s \n", err))
+		}
+		JWTKeys = keys
+	}
+
+	if value := viper.Get("jwtSigningKid"); value != nil {
+		JWTSigningKid = value.(string)
+	}
+
+	if viper.IsSet("jwtAcceptHmac") {
+		JWTAcceptHMAC = viper.GetBool("jwtAcceptHmac")
+	}
 }
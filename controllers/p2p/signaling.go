@@ -3,6 +3,8 @@ package p2p
 import (
 	"encoding/json"
 	"fmt"
+	"sync"
+	"toysgo/models"
 	"toysgo/services"
 
 	"github.com/gofiber/websocket/v2"
@@ -14,14 +16,83 @@ var webrtcService = services.NewWebRTCService()
 
 // Message defines the structure of WebSocket messages
 type Message struct {
-	Type string `json:"type"` // "offer", "answer", or "candidate"
+	Type string `json:"type"` // "offer", "answer", "candidate", "failed" or "error"
 	Data string `json:"data"` // SDP or ICE candidate
 }
 
+// peerSocket serializes writes to a peer's WebSocket
+type peerSocket struct {
+	conn *websocket.Conn
+	mu   sync.Mutex
+}
+
+func (s *peerSocket) send(msg Message) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.write(msg)
+}
+
+// write sends a message (호출자가 잠금을 보유해야 함)
+func (s *peerSocket) write(msg Message) {
+	if err := s.conn.WriteJSON(msg); err != nil {
+		fmt.Println("Failed to send signaling message:", err)
+	}
+}
+
+var (
+	socketsMu sync.Mutex
+	sockets   = make(map[string]*peerSocket)
+)
+
+func init() {
+	// 서버에서 생성한 ICE 후보와 재협상 Offer를 해당 피어의 소켓으로 전달
+	webrtcService.OnSignal(func(peerID string, signalType string, data string) {
+		socketsMu.Lock()
+		socket := sockets[peerID]
+		socketsMu.Unlock()
+		if socket == nil {
+			return
+		}
+		socket.send(Message{Type: signalType, Data: data})
+	})
+}
+
 func WebSocketHandler(c *websocket.Conn) {
 	defer c.Close()
 
-	peerID := c.Query("peer_id") // Peer ID from client
+	// 라우터에서 토큰을 확인한 사용자
+	user, _ := c.Locals("user").(*models.User)
+	if user == nil {
+		fmt.Println("Unauthenticated signaling socket")
+		return
+	}
+
+	if c.Query("peer_id") == "" { // Peer ID from client
+		fmt.Println("Missing peer_id")
+		return
+	}
+	// 다른 사용자의 세션을 가로채지 못하도록 피어 ID는 사용자별로 구분
+	peerID := fmt.Sprintf("%d:%s", user.Id, c.Query("peer_id"))
+
+	// 사용 중인 피어 ID는 교체하지 않고 거부
+	socket := &peerSocket{conn: c}
+	socketsMu.Lock()
+	if sockets[peerID] != nil {
+		socketsMu.Unlock()
+		fmt.Println("Duplicate peer_id:", peerID)
+		socket.send(Message{Type: "error", Data: "peer_id already in use"})
+		return
+	}
+	sockets[peerID] = socket
+	socketsMu.Unlock()
+	defer func() {
+		socketsMu.Lock()
+		if sockets[peerID] == socket {
+			delete(sockets, peerID)
+		}
+		socketsMu.Unlock()
+	}()
+
 	_, err := webrtcService.CreatePeerConnection(peerID)
 	if err != nil {
 		fmt.Println("Failed to create PeerConnection:", err)
@@ -48,14 +119,19 @@ func WebSocketHandler(c *websocket.Conn) {
 		// Handle WebRTC signaling messages
 		switch msg.Type {
 		case "offer":
-			fmt.Printf("Received SDP offer:\n%s\n", msg.Data)
-			err := webrtcService.HandleSDP(peerID, webrtc.SDPTypeOffer, msg.Data)
+			fmt.Println("Received SDP offer")
+			// Answer를 보내기 전에 서버 ICE 후보가 먼저 전송되지 않도록 소켓 잠금을 유지
+			socket.mu.Lock()
+			answer, err := webrtcService.HandleSDP(peerID, webrtc.SDPTypeOffer, msg.Data)
 			if err != nil {
 				fmt.Println("Error handling offer:", err)
+			} else {
+				socket.write(Message{Type: "answer", Data: answer})
 			}
+			socket.mu.Unlock()
 		case "answer":
 			fmt.Println("Received SDP answer")
-			err := webrtcService.HandleSDP(peerID, webrtc.SDPTypeAnswer, msg.Data)
+			_, err := webrtcService.HandleSDP(peerID, webrtc.SDPTypeAnswer, msg.Data)
 			if err != nil {
 				fmt.Println("Error handling answer:", err)
 			}
@@ -65,6 +141,12 @@ func WebSocketHandler(c *websocket.Conn) {
 			if err != nil {
 				fmt.Println("Error handling candidate:", err)
 			}
+		case "renegotiate":
+			// 클라이언트 요청 시 서버에서 새 Offer 생성
+			err := webrtcService.Renegotiate(peerID)
+			if err != nil {
+				fmt.Println("Error renegotiating:", err)
+			}
 		default:
 			fmt.Println("Unknown message type:", msg.Type)
 		}
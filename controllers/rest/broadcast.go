@@ -0,0 +1,143 @@
+package rest
+
+import (
+	"net/http"
+	"strconv"
+	"toysgo/controllers"
+	"toysgo/models"
+)
+
+type BroadcastController struct {
+	controllers.Controller
+}
+
+func (c *BroadcastController) Index(page int, pagesize int) {
+	var args []interface{}
+
+	user := c.Query("user")
+	if user != "" {
+		args = append(args, models.Where{Column: "user", Value: user, Compare: "="})
+	}
+
+	if c.Session == nil || user != strconv.FormatInt(c.Session.Id, 10) {
+		args = append(args, listedBroadcasts()...)
+	}
+
+	c.find(args, page, pagesize)
+}
+
+// 특정 사용자의 방송 기록
+func (c *BroadcastController) User(user int64, page int, pagesize int) {
+	var args []interface{}
+
+	args = append(args, models.Where{Column: "user", Value: user, Compare: "="})
+
+	if c.Session == nil || user != c.Session.Id {
+		args = append(args, listedBroadcasts()...)
+	}
+
+	c.find(args, page, pagesize)
+}
+
+// 다른 사용자의 방송 기록은 공개 방송만 (이전 기록의 빈 값은 공개)
+func listedBroadcasts() []interface{} {
+	return []interface{}{
+		models.Where{Column: "visibility", Value: models.BroadcastVisibilityUnlisted, Compare: "<>"},
+		models.Where{Column: "visibility", Value: models.BroadcastVisibilityPrivate, Compare: "<>"},
+	}
+}
+
+func (c *BroadcastController) find(args []interface{}, page int, pagesize int) {
+	conn := c.NewConnection()
+
+	manager := models.NewBroadcastManager(conn)
+
+	name := c.Query("name")
+	if name != "" {
+		args = append(args, models.Where{Column: "name", Value: name, Compare: "like"})
+	}
+
+	title := c.Query("title")
+	if title != "" {
+		args = append(args, models.Where{Column: "title", Value: title, Compare: "like"})
+	}
+
+	category := c.Query("category")
+	if category != "" {
+		args = append(args, models.Where{Column: "category", Value: category, Compare: "="})
+	}
+
+	status := c.Query("status")
+	if status != "" {
+		args = append(args, models.Where{Column: "status", Value: status, Compare: "="})
+	}
+
+	startdate := c.Query("startdate")
+	enddate := c.Query("enddate")
+	if startdate != "" && enddate != "" {
+		var v [2]string
+		v[0] = startdate
+		v[1] = enddate
+		args = append(args, models.Where{Column: "startdate", Value: v, Compare: "between"})
+	} else if startdate != "" {
+		args = append(args, models.Where{Column: "startdate", Value: startdate, Compare: ">="})
+	} else if enddate != "" {
+		args = append(args, models.Where{Column: "startdate", Value: enddate, Compare: "<="})
+	}
+
+	if page != 0 && pagesize != 0 {
+		args = append(args, models.Paging(page, pagesize))
+	}
+
+	orderby := c.Query("orderby")
+	if orderby == "desc" {
+		orderby = "id desc"
+	} else {
+		orderby = ""
+	}
+
+	if orderby != "" {
+		args = append(args, models.Ordering(orderby))
+	}
+
+	items := manager.Find(args)
+	c.Set("items", items)
+
+	total := manager.Count(args)
+	c.Set("total", total)
+}
+
+func (c *BroadcastController) Read(id int64) {
+	conn := c.NewConnection()
+
+	manager := models.NewBroadcastManager(conn)
+	item := manager.Get(id)
+
+	// 비공개 방송 기록은 방송한 본인만 조회
+	if item != nil && item.Visibility == models.BroadcastVisibilityPrivate && (c.Session == nil || item.User != c.Session.Id) {
+		item = nil
+	}
+
+	c.Set("item", item)
+}
+
+// 본인 방송 기록인지 확인 (아니면 응답 코드를 설정하고 false)
+func ownBroadcast(c *controllers.Controller, id int64) bool {
+	if c.Session == nil {
+		c.Code = http.StatusUnauthorized
+		c.Set("code", "not auth")
+		return false
+	}
+
+	conn := c.NewConnection()
+
+	manager := models.NewBroadcastManager(conn)
+	item := manager.Get(id)
+	if item == nil || item.User != c.Session.Id {
+		c.Code = http.StatusNotFound
+		c.Set("code", "not found")
+		return false
+	}
+
+	return true
+}
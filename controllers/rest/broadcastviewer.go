@@ -0,0 +1,68 @@
+package rest
+
+import (
+	"toysgo/controllers"
+	"toysgo/models"
+)
+
+type BroadcastViewerController struct {
+	controllers.Controller
+}
+
+// 특정 방송의 시청 기록
+func (c *BroadcastViewerController) Broadcast(broadcast int64, page int, pagesize int) {
+	if !ownBroadcast(&c.Controller, broadcast) {
+		return
+	}
+
+	var args []interface{}
+
+	args = append(args, models.Where{Column: "broadcast", Value: broadcast, Compare: "="})
+
+	c.find(args, page, pagesize)
+}
+
+func (c *BroadcastViewerController) find(args []interface{}, page int, pagesize int) {
+	conn := c.NewConnection()
+
+	manager := models.NewBroadcastViewerManager(conn)
+
+	user := c.Query("user")
+	if user != "" {
+		args = append(args, models.Where{Column: "user", Value: user, Compare: "="})
+	}
+
+	startdate := c.Query("startdate")
+	enddate := c.Query("enddate")
+	if startdate != "" && enddate != "" {
+		var v [2]string
+		v[0] = startdate
+		v[1] = enddate
+		args = append(args, models.Where{Column: "joindate", Value: v, Compare: "between"})
+	} else if startdate != "" {
+		args = append(args, models.Where{Column: "joindate", Value: startdate, Compare: ">="})
+	} else if enddate != "" {
+		args = append(args, models.Where{Column: "joindate", Value: enddate, Compare: "<="})
+	}
+
+	if page != 0 && pagesize != 0 {
+		args = append(args, models.Paging(page, pagesize))
+	}
+
+	orderby := c.Query("orderby")
+	if orderby == "desc" {
+		orderby = "id desc"
+	} else {
+		orderby = ""
+	}
+
+	if orderby != "" {
+		args = append(args, models.Ordering(orderby))
+	}
+
+	items := manager.Find(args)
+	c.Set("items", items)
+
+	total := manager.Count(args)
+	c.Set("total", total)
+}
@@ -3,8 +3,8 @@ package rest
 import (
 	"encoding/json"
 	"log"
+	"toysgo/auth"
 	"toysgo/controllers"
-	"toysgo/global"
 	"toysgo/models"
 
 	"github.com/go-resty/resty/v2"
@@ -67,8 +67,22 @@ func (c *KakaoController) KakaoUserApi(AccessToken string) {
 	existingUser := manager.GetByEmail(kakaoResp.KakaoAccount.Email)
 
 	if existingUser != nil {
+		// 가입 경로가 기록되지 않은 이전 소셜 가입자는 지금 기록 (공용 비밀번호도 함께 비움)
+		if existingUser.Type == "" {
+			if err := manager.UpdateSocialType(existingUser.Id, models.UserTypeKakao); err != nil {
+				log.Printf("Error updating user type: %v\n", err)
+			}
+		}
+
+		// 리프레시 토큰 발급 (/api/jwt/token 으로 액세스 토큰 갱신)
+		session, signedRefreshToken, err := auth.IssueRefreshToken(conn, existingUser.Id, auth.NewSessionInfo(c.Context))
+		if err != nil {
+			controllers.SendError(c.Context, "Failed to generate refresh token")
+			return
+		}
+
 		// JWT 토큰 생성
-		signedAuthToken, err := global.GenerateAuthToken(existingUser)
+		signedAuthToken, err := auth.GenerateToken(existingUser, session.Family)
 		if err != nil {
 			controllers.SendError(c.Context, "Failed to generate JWT")
 			return
@@ -76,15 +90,17 @@ func (c *KakaoController) KakaoUserApi(AccessToken string) {
 
 		// 성공 응답 반환
 		controllers.SendResponse(c.Context, "success", "User already exists", map[string]interface{}{
-			"accessToken": signedAuthToken,
-			"user":        existingUser,
+			"accessToken":  signedAuthToken,
+			"refreshToken": signedRefreshToken,
+			"user":         existingUser,
 		})
 		return
 	}
 
 	// 사용자가 없는 경우 새 사용자 생성
 	newUser := &models.User{
-		Passwd: "qwer1234!!",
+		Passwd: "", // 소셜 로그인 계정은 비밀번호로 로그인할 수 없음
+		Type:   models.UserTypeKakao,
 		Name:   kakaoResp.KakaoAccount.Name,
 		Email:  kakaoResp.KakaoAccount.Email,
 	}
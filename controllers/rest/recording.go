@@ -0,0 +1,109 @@
+package rest
+
+import (
+	"net/http"
+	"toysgo/controllers"
+	"toysgo/models"
+	"toysgo/services"
+)
+
+type RecordingController struct {
+	controllers.Controller
+}
+
+// 로그인한 사용자의 녹화 목록
+func (c *RecordingController) Index(page int, pagesize int) {
+	if c.Session == nil {
+		c.Code = http.StatusUnauthorized
+		c.Set("code", "not auth")
+		return
+	}
+
+	conn := c.NewConnection()
+
+	manager := models.NewRecordingManager(conn)
+
+	var args []interface{}
+	args = append(args, models.Where{Column: "user", Value: c.Session.Id, Compare: "="})
+
+	broadcast := c.Query("broadcast")
+	if broadcast != "" {
+		args = append(args, models.Where{Column: "broadcast", Value: broadcast, Compare: "="})
+	}
+
+	status := c.Query("status")
+	if status != "" {
+		args = append(args, models.Where{Column: "status", Value: status, Compare: "="})
+	}
+
+	if page != 0 && pagesize != 0 {
+		args = append(args, models.Paging(page, pagesize))
+	}
+
+	orderby := c.Query("orderby")
+	if orderby == "desc" {
+		orderby = "id desc"
+	} else {
+		orderby = ""
+	}
+
+	if orderby != "" {
+		args = append(args, models.Ordering(orderby))
+	}
+
+	items := manager.Find(args)
+	c.Set("items", items)
+
+	total := manager.Count(args)
+	c.Set("total", total)
+}
+
+func (c *RecordingController) Read(id int64) {
+	item := c.get(id)
+	if item == nil {
+		return
+	}
+
+	c.Set("item", item)
+}
+
+// 녹화 파일 경로 (track: audio 또는 video, 없으면 빈 문자열)
+func (c *RecordingController) File(id int64, track string) string {
+	item := c.get(id)
+	if item == nil {
+		return ""
+	}
+
+	file := item.Videofile
+	if track == "audio" {
+		file = item.Audiofile
+	}
+
+	path := services.RecordingFilePath(file)
+	if path == "" {
+		c.Code = http.StatusNotFound
+		c.Set("code", "not found")
+	}
+	return path
+}
+
+// 본인 녹화만 조회
+func (c *RecordingController) get(id int64) *models.Recording {
+	if c.Session == nil {
+		c.Code = http.StatusUnauthorized
+		c.Set("code", "not auth")
+		return nil
+	}
+
+	conn := c.NewConnection()
+
+	manager := models.NewRecordingManager(conn)
+	item := manager.Get(id)
+	if item == nil || item.User != c.Session.Id {
+		c.Code = http.StatusNotFound
+		c.Set("code", "not found")
+		return nil
+	}
+
+	return item
+}
@@ -0,0 +1,120 @@
+package rest
+
+import (
+	"net/http"
+	"toysgo/auth"
+	"toysgo/controllers"
+	"toysgo/models"
+)
+
+type SessionController struct {
+	controllers.Controller
+}
+
+// 로그인 세션 (리프레시 토큰 family 단위, 토큰 값은 내보내지 않음)
+type SessionItem struct {
+	Id         string `json:"id"`
+	Device     string `json:"device"`
+	Ip         string `json:"ip"`
+	Useragent  string `json:"useragent"`
+	Lastdate   string `json:"lastdate"`
+	Expiredate string `json:"expiredate"`
+	Current    bool   `json:"current"`
+}
+
+// 로그인한 사용자의 사용 중인 세션 목록 (최근 사용 순)
+func (c *SessionController) Index() {
+	if c.Session == nil {
+		c.Code = http.StatusUnauthorized
+		c.Set("code", "not auth")
+		return
+	}
+
+	conn := c.NewConnection()
+
+	manager := models.NewAuthManager(conn)
+
+	var args []interface{}
+	args = append(args, models.Where{Column: "user", Value: c.Session.Id, Compare: "="})
+	args = append(args, models.Where{Column: "status", Value: models.AuthStatusActive, Compare: "="})
+	args = append(args, models.Where{Column: "expiredate", Value: c.Date, Compare: ">="})
+	args = append(args, models.Ordering("date desc"))
+
+	current := c.current()
+
+	items := make([]SessionItem, 0)
+	for _, item := range *manager.Find(args) {
+		// 세션마다 사용 가능한 토큰은 가장 최근에 교체된 하나뿐이며 그 발급 시각이 마지막 사용 시각
+		items = append(items, SessionItem{
+			Id:         item.Family,
+			Device:     item.Device,
+			Ip:         item.Ip,
+			Useragent:  item.Useragent,
+			Lastdate:   item.Date,
+			Expiredate: item.Expiredate,
+			Current:    item.Family == current,
+		})
+	}
+
+	c.Set("items", items)
+	c.Set("total", len(items))
+}
+
+// 다른 기기의 세션 종료
+func (c *SessionController) Delete(id string) {
+	if c.Session == nil {
+		c.Code = http.StatusUnauthorized
+		c.Set("code", "not auth")
+		return
+	}
+
+	conn := c.NewConnection()
+
+	manager := models.NewAuthManager(conn)
+
+	var args []interface{}
+	args = append(args, models.Where{Column: "user", Value: c.Session.Id, Compare: "="})
+	args = append(args, models.Where{Column: "family", Value: id, Compare: "="})
+	args = append(args, models.Where{Column: "status", Value: models.AuthStatusActive, Compare: "="})
+
+	if id == "" || manager.Count(args) == 0 {
+		c.Code = http.StatusNotFound
+		c.Set("code", "not found")
+		return
+	}
+
+	c.revoke(id)
+}
+
+// 현재 세션 로그아웃 (리프레시 토큰과 액세스 토큰 모두 사용할 수 없게 됨)
+func (c *SessionController) Logout() {
+	if c.Session == nil {
+		c.Code = http.StatusUnauthorized
+		c.Set("code", "not auth")
+		return
+	}
+
+	current := c.current()
+	if current == "" {
+		c.Code = http.StatusBadRequest
+		c.Set("code", "session not found")
+		return
+	}
+
+	c.revoke(current)
+}
+
+func (c *SessionController) current() string {
+	session, _ := c.Context.Locals("session").(string)
+	return session
+}
+
+func (c *SessionController) revoke(id string) {
+	conn := c.NewConnection()
+
+	if err := auth.RevokeSession(conn, id); err != nil {
+		c.Code = http.StatusInternalServerError
+		c.Set("code", "error")
+		return
+	}
+}
@@ -0,0 +1,65 @@
+package rest
+
+import (
+	"toysgo/controllers"
+	"toysgo/models"
+)
+
+type StreamStatController struct {
+	controllers.Controller
+}
+
+// 특정 방송의 연결 품질 기록 (시간순)
+func (c *StreamStatController) Broadcast(broadcast int64, page int, pagesize int) {
+	if !ownBroadcast(&c.Controller, broadcast) {
+		return
+	}
+
+	conn := c.NewConnection()
+
+	manager := models.NewStreamStatManager(conn)
+
+	var args []interface{}
+	args = append(args, models.Where{Column: "broadcast", Value: broadcast, Compare: "="})
+
+	for _, column := range []string{"peer", "role", "source", "kind"} {
+		value := c.Query(column)
+		if value != "" {
+			args = append(args, models.Where{Column: column, Value: value, Compare: "="})
+		}
+	}
+
+	startdate := c.Query("startdate")
+	enddate := c.Query("enddate")
+	if startdate != "" && enddate != "" {
+		var v [2]string
+		v[0] = startdate
+		v[1] = enddate
+		args = append(args, models.Where{Column: "date", Value: v, Compare: "between"})
+	} else if startdate != "" {
+		args = append(args, models.Where{Column: "date", Value: startdate, Compare: ">="})
+	} else if enddate != "" {
+		args = append(args, models.Where{Column: "date", Value: enddate, Compare: "<="})
+	}
+
+	if page != 0 && pagesize != 0 {
+		args = append(args, models.Paging(page, pagesize))
+	}
+
+	orderby := c.Query("orderby")
+	if orderby == "desc" {
+		orderby = "id desc"
+	} else {
+		orderby = ""
+	}
+
+	if orderby != "" {
+		args = append(args, models.Ordering(orderby))
+	}
+
+	items := manager.Find(args)
+	c.Set("items", items)
+
+	total := manager.Count(args)
+	c.Set("total", total)
+}
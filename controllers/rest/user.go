@@ -1,6 +1,7 @@
 package rest
 
 import (
+	"toysgo/auth"
 	"toysgo/controllers"
 	"toysgo/models"
 )
@@ -21,11 +22,6 @@ func (c *UserController) Index(page int, pagesize int) {
 		args = append(args, models.Where{Column: "name", Value: name, Compare: "="})
 	}
 
-	passwd := c.Query("passwd")
-	if passwd != "" {
-		args = append(args, models.Where{Column: "passwd", Value: passwd, Compare: "like"})
-	}
-
 	email := c.Query("email")
 	if email != "" {
 		args = append(args, models.Where{Column: "email", Value: email, Compare: "like"})
@@ -77,6 +73,9 @@ func (c *UserController) Read(id int64) {
 }
 
 func (c *UserController) Insert(item *models.User) {
+	// 소셜 가입 경로는 소셜 로그인에서만 기록
+	item.Type = ""
+
 	conn := c.NewConnection()
 
 	manager := models.NewUserManager(conn)
@@ -92,6 +91,9 @@ func (c *UserController) Update(item *models.User) {
 
 	manager := models.NewUserManager(conn)
 	manager.Update(item)
+
+	// 토큰 사용자 캐시에 남은 이전 정보 제거
+	auth.ForgetUser(item.Id)
 }
 
 func (c *UserController) Delete(item *models.User) {
@@ -99,6 +101,7 @@ func (c *UserController) Delete(item *models.User) {
 
 	manager := models.NewUserManager(conn)
 	manager.Delete(item.Id)
+	auth.ForgetUser(item.Id)
 }
 
 func (c *UserController) GetByEmail(email string) *models.User {
@@ -3,7 +3,11 @@ package main
 import (
 	"strings"
 	"time"
+	"toysgo/auth"
+	"toysgo/config"
+	"toysgo/models"
 	"toysgo/router"
+	"toysgo/services"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/gofiber/fiber/v2/middleware/cors"
@@ -34,11 +38,36 @@ func main() {
 			fiber.MethodPut,
 			fiber.MethodDelete,
 			// fiber.MethodHead,
-			// fiber.MethodPatch,
+			fiber.MethodPatch,
 		}, ","),
+		// WHIP/WHEP 리소스 주소
+		ExposeHeaders: "Location",
 	}))
 
+	// 이전 소셜 로그인 가입자의 공용 비밀번호 제거 (해당 계정은 소셜 로그인만 가능)
+	conn := models.NewConnection()
+	if count, err := models.NewUserManager(conn).ClearLegacySocialPasswd(); err != nil {
+		log.Printf("❌ 소셜 로그인 계정 비밀번호 정리 실패: %v\n", err)
+	} else if count > 0 {
+		log.Printf("🔒 소셜 로그인 계정 %d개의 공용 비밀번호 제거\n", count)
+	}
+	conn.Close()
+
+	// JWT 서명 키 (설정된 키 파일을 읽지 못하면 시작하지 않음)
+	if err := auth.LoadKeys(); err != nil {
+		log.Fatal(err)
+	}
+
 	router.SetRouter(app)
 
+	// 내장 TURN 서버 (대칭형 NAT, 방화벽 뒤의 시청자용 릴레이)
+	if config.TURNServerEnabled {
+		turnServer, err := services.StartTURNServer()
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer turnServer.Close()
+	}
+
 	log.Fatal(app.Listen(":9000"))
 }
@@ -14,14 +14,27 @@ import (
 )
 
 type Auth struct {
-	Id    int64  `json:"id"`
-	User  int64  `json:"user"`
-	Token string `json:"token"`
-	Date  string `json:"date"`
+	Id         int64  `json:"id"`
+	User       int64  `json:"user"`
+	Token      string `json:"token"`
+	Family     string `json:"family"`
+	Status     int    `json:"status"`
+	Expiredate string `json:"expiredate"`
+	Device     string `json:"device"`
+	Ip         string `json:"ip"`
+	Useragent  string `json:"useragent"`
+	Date       string `json:"date"`
 
 	Extra map[string]interface{} `json:"extra"`
 }
 
+// 리프레시 토큰 상태
+const (
+	AuthStatusActive  = 1 // 사용 가능
+	AuthStatusRotated = 2 // 새 토큰으로 교체됨 (다시 사용되면 탈취로 간주)
+	AuthStatusRevoked = 3 // 폐기됨
+)
+
 type AuthManager struct {
 	Conn   *sql.DB
 	Tx     *sql.Tx
@@ -81,7 +94,7 @@ func (p *AuthManager) Query(query string, params ...interface{}) (*sql.Rows, err
 func (p *AuthManager) GetQeury() string {
 	ret := ""
 
-	str := "select a_id, a_user, a_token, a_date from auth_tb "
+	str := "select a_id, a_user, a_token, a_family, a_status, a_expiredate, a_device, a_ip, a_useragent, a_date from auth_tb "
 
 	if p.Index == "" {
 		ret = str
@@ -129,17 +142,17 @@ func (p *AuthManager) Insert(item *Auth) error {
 		item.Date = fmt.Sprintf("%04d-%02d-%02d %02d:%02d:%02d", t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second())
 	}
 
-	log.Println(item.Id, item.User, item.Token, item.Date)
+	log.Println(item.Id, item.User, item.Family, item.Status, item.Expiredate, item.Device, item.Ip, item.Date)
 
 	query := ""
 	var res sql.Result
 	var err error
 	if item.Id > 0 {
-		query = "insert into auth_tb (a_id, a_user, a_token, a_date) values (?, ?, ?, ?)"
-		res, err = p.Exec(query, item.Id, item.User, item.Token, item.Date)
+		query = "insert into auth_tb (a_id, a_user, a_token, a_family, a_status, a_expiredate, a_device, a_ip, a_useragent, a_date) values (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)"
+		res, err = p.Exec(query, item.Id, item.User, item.Token, item.Family, item.Status, item.Expiredate, item.Device, item.Ip, item.Useragent, item.Date)
 	} else {
-		query = "insert into auth_tb (a_user, a_token, a_date) values (?, ?, ?)"
-		res, err = p.Exec(query, item.User, item.Token, item.Date)
+		query = "insert into auth_tb (a_user, a_token, a_family, a_status, a_expiredate, a_device, a_ip, a_useragent, a_date) values (?, ?, ?, ?, ?, ?, ?, ?, ?)"
+		res, err = p.Exec(query, item.User, item.Token, item.Family, item.Status, item.Expiredate, item.Device, item.Ip, item.Useragent, item.Date)
 	}
 
 	if err == nil {
@@ -172,8 +185,8 @@ func (p *AuthManager) Update(item *Auth) error {
 		item.Date = fmt.Sprintf("%04d-%02d-%02d %02d:%02d:%02d", t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second())
 	}
 
-	query := "update auth_tb set a_user = ?, a_token = ?, a_date = ? where a_id = ?"
-	_, err := p.Exec(query, item.User, item.Token, item.Date, item.Id)
+	query := "update auth_tb set a_user = ?, a_token = ?, a_family = ?, a_status = ?, a_expiredate = ?, a_device = ?, a_ip = ?, a_useragent = ?, a_date = ? where a_id = ?"
+	_, err := p.Exec(query, item.User, item.Token, item.Family, item.Status, item.Expiredate, item.Device, item.Ip, item.Useragent, item.Date, item.Id)
 
 	return err
 }
@@ -201,7 +214,7 @@ func (p *AuthManager) ReadRow(rows *sql.Rows) *Auth {
 	var err error
 
 	if rows.Next() {
-		err = rows.Scan(&item.Id, &item.User, &item.Token, &item.Date)
+		err = rows.Scan(&item.Id, &item.User, &item.Token, &item.Family, &item.Status, &item.Expiredate, &item.Device, &item.Ip, &item.Useragent, &item.Date)
 	} else {
 		return nil
 	}
@@ -219,7 +232,7 @@ func (p *AuthManager) ReadRows(rows *sql.Rows) *[]Auth {
 	for rows.Next() {
 		var item Auth
 
-		err := rows.Scan(&item.Id, &item.User, &item.Token, &item.Date)
+		err := rows.Scan(&item.Id, &item.User, &item.Token, &item.Family, &item.Status, &item.Expiredate, &item.Device, &item.Ip, &item.Useragent, &item.Date)
 
 		if err != nil {
 			log.Printf("ReadRows error : %v\n", err)
@@ -422,3 +435,77 @@ func (p *AuthManager) GetByUser(user int64, args ...interface{}) *Auth {
 		return nil
 	}
 }
+
+func (p *AuthManager) GetByToken(token string, args ...interface{}) *Auth {
+	if token != "" {
+		args = append(args, Where{Column: "token", Value: token, Compare: "="})
+	}
+
+	items := p.Find(args)
+
+	if items != nil && len(*items) > 0 {
+		return &(*items)[0]
+	} else {
+		return nil
+	}
+}
+
+// 사용 가능한 토큰만 교체됨으로 바꾼다 (동시에 같은 토큰을 쓰면 한 요청만 성공)
+func (p *AuthManager) Rotate(id int64) (bool, error) {
+	if p.Conn == nil && p.Tx == nil {
+		return false, errors.New("Connection Error")
+	}
+
+	query := "update auth_tb set a_status = ? where a_id = ? and a_status = ?"
+	res, err := p.Exec(query, AuthStatusRotated, id, AuthStatusActive)
+	if err != nil {
+		return false, err
+	}
+
+	cnt, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return cnt == 1, nil
+}
+
+func (p *AuthManager) RevokeFamily(family string) error {
+	if p.Conn == nil && p.Tx == nil {
+		return errors.New("Connection Error")
+	}
+
+	query := "update auth_tb set a_status = ? where a_family = ?"
+	_, err := p.Exec(query, AuthStatusRevoked, family)
+
+	return err
+}
+
+// since 이후에 토큰이 발급된 폐기된 family 목록 (아직 유효할 수 있는 액세스 토큰의 세션)
+func (p *AuthManager) FindRevokedFamilies(since string) []string {
+	var items []string
+
+	if p.Conn == nil && p.Tx == nil {
+		return items
+	}
+
+	query := "select distinct a_family from auth_tb where a_status = ? and a_date >= ?"
+	rows, err := p.Query(query, AuthStatusRevoked, since)
+	if err != nil {
+		log.Printf("query error : %v, %v\n", err, query)
+		return items
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var family string
+		if err := rows.Scan(&family); err != nil {
+			log.Printf("ReadRows error : %v\n", err)
+			break
+		}
+		items = append(items, family)
+	}
+
+	return items
+}
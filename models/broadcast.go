@@ -0,0 +1,445 @@
+package models
+
+import (
+	"toysgo/config"
+
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	_ "github.com/go-sql-driver/mysql"
+	log "github.com/sirupsen/logrus"
+)
+
+type Broadcast struct {
+	Id           int64  `json:"id"`
+	User         int64  `json:"user"`
+	Name         string `json:"name"`
+	Title        string `json:"title"`
+	Category     string `json:"category"`
+	Visibility   string `json:"visibility"`
+	Startdate    string `json:"startdate"`
+	Enddate      string `json:"enddate"`
+	Duration     int    `json:"duration"`
+	Peakviewers  int    `json:"peakviewers"`
+	Totalviewers int    `json:"totalviewers"`
+	Status       int    `json:"status"`
+	Date         string `json:"date"`
+
+	Extra map[string]interface{} `json:"extra"`
+}
+
+type BroadcastManager struct {
+	Conn   *sql.DB
+	Tx     *sql.Tx
+	Result *sql.Result
+	Index  string
+}
+
+func (c *Broadcast) AddExtra(key string, value interface{}) {
+	c.Extra[key] = value
+}
+
+func NewBroadcastManager(conn interface{}) *BroadcastManager {
+	var item BroadcastManager
+
+	if conn == nil {
+		item.Conn = NewConnection()
+	} else {
+		if v, ok := conn.(*sql.DB); ok {
+			item.Conn = v
+			item.Tx = nil
+		} else {
+			item.Tx = conn.(*sql.Tx)
+			item.Conn = nil
+		}
+	}
+
+	item.Index = ""
+	return &item
+}
+
+func (p *BroadcastManager) Close() {
+	if p.Conn != nil {
+		p.Conn.Close()
+	}
+}
+
+func (p *BroadcastManager) SetIndex(index string) {
+	p.Index = index
+}
+
+func (p *BroadcastManager) Exec(query string, params ...interface{}) (sql.Result, error) {
+	if p.Conn != nil {
+		return p.Conn.Exec(query, params...)
+	} else {
+		return p.Tx.Exec(query, params...)
+	}
+}
+
+func (p *BroadcastManager) Query(query string, params ...interface{}) (*sql.Rows, error) {
+	if p.Conn != nil {
+		return p.Conn.Query(query, params...)
+	} else {
+		return p.Tx.Query(query+" FOR UPDATE", params...)
+	}
+}
+
+func (p *BroadcastManager) GetQeury() string {
+	ret := ""
+
+	str := "select bc_id, bc_user, bc_name, bc_title, bc_category, bc_visibility, bc_startdate, bc_enddate, bc_duration, bc_peakviewers, bc_totalviewers, bc_status, bc_date from broadcast_tb "
+
+	if p.Index == "" {
+		ret = str
+	} else {
+		ret = str + " use index(" + p.Index + ")"
+	}
+
+	ret += "where 1=1 "
+
+	return ret
+}
+
+func (p *BroadcastManager) GetQeurySelect() string {
+	ret := ""
+
+	str := "select count(*) from broadcast_tb "
+
+	if p.Index == "" {
+		ret = str
+	} else {
+		ret = str + " use index(" + p.Index + ") "
+	}
+
+	return ret
+}
+
+func (p *BroadcastManager) Truncate() error {
+	if p.Conn == nil && p.Tx == nil {
+		return errors.New("Connection Error")
+	}
+
+	query := "truncate broadcast_tb "
+	p.Exec(query)
+
+	return nil
+}
+
+func (p *BroadcastManager) Insert(item *Broadcast) error {
+	if p.Conn == nil && p.Tx == nil {
+		return errors.New("Connection Error")
+	}
+
+	if item.Date == "" {
+		t := time.Now()
+		item.Date = fmt.Sprintf("%04d-%02d-%02d %02d:%02d:%02d", t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second())
+	}
+
+	query := ""
+	var res sql.Result
+	var err error
+	if item.Id > 0 {
+		query = "insert into broadcast_tb (bc_id, bc_user, bc_name, bc_title, bc_category, bc_visibility, bc_startdate, bc_enddate, bc_duration, bc_peakviewers, bc_totalviewers, bc_status, bc_date) values (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)"
+		res, err = p.Exec(query, item.Id, item.User, item.Name, item.Title, item.Category, item.Visibility, item.Startdate, item.Enddate, item.Duration, item.Peakviewers, item.Totalviewers, item.Status, item.Date)
+	} else {
+		query = "insert into broadcast_tb (bc_user, bc_name, bc_title, bc_category, bc_visibility, bc_startdate, bc_enddate, bc_duration, bc_peakviewers, bc_totalviewers, bc_status, bc_date) values (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)"
+		res, err = p.Exec(query, item.User, item.Name, item.Title, item.Category, item.Visibility, item.Startdate, item.Enddate, item.Duration, item.Peakviewers, item.Totalviewers, item.Status, item.Date)
+	}
+
+	if err == nil {
+		p.Result = &res
+	} else {
+		log.Println(err)
+		p.Result = nil
+	}
+
+	return err
+}
+
+func (p *BroadcastManager) Delete(id int64) error {
+	if p.Conn == nil && p.Tx == nil {
+		return errors.New("Connection Error")
+	}
+
+	query := "delete from broadcast_tb where bc_id = ?"
+	_, err := p.Exec(query, id)
+
+	return err
+}
+
+func (p *BroadcastManager) Update(item *Broadcast) error {
+	if p.Conn == nil && p.Tx == nil {
+		return errors.New("Connection Error")
+	}
+
+	query := "update broadcast_tb set bc_user = ?, bc_name = ?, bc_title = ?, bc_category = ?, bc_visibility = ?, bc_startdate = ?, bc_enddate = ?, bc_duration = ?, bc_peakviewers = ?, bc_totalviewers = ?, bc_status = ?, bc_date = ? where bc_id = ?"
+	_, err := p.Exec(query, item.User, item.Name, item.Title, item.Category, item.Visibility, item.Startdate, item.Enddate, item.Duration, item.Peakviewers, item.Totalviewers, item.Status, item.Date, item.Id)
+
+	return err
+}
+
+func (p *BroadcastManager) GetIdentity() int64 {
+	if p.Result == nil && p.Tx == nil {
+		return 0
+	}
+
+	id, err := (*p.Result).LastInsertId()
+
+	if err != nil {
+		return 0
+	} else {
+		return id
+	}
+}
+
+func (p *Broadcast) InitExtra() {
+	p.Extra = map[string]interface{}{}
+}
+
+func (p *BroadcastManager) ReadRow(rows *sql.Rows) *Broadcast {
+	var item Broadcast
+	var err error
+
+	if rows.Next() {
+		err = rows.Scan(&item.Id, &item.User, &item.Name, &item.Title, &item.Category, &item.Visibility, &item.Startdate, &item.Enddate, &item.Duration, &item.Peakviewers, &item.Totalviewers, &item.Status, &item.Date)
+	} else {
+		return nil
+	}
+	if err != nil {
+		return nil
+	} else {
+		item.InitExtra()
+		return &item
+	}
+}
+
+func (p *BroadcastManager) ReadRows(rows *sql.Rows) *[]Broadcast {
+	var items []Broadcast
+
+	for rows.Next() {
+		var item Broadcast
+
+		err := rows.Scan(&item.Id, &item.User, &item.Name, &item.Title, &item.Category, &item.Visibility, &item.Startdate, &item.Enddate, &item.Duration, &item.Peakviewers, &item.Totalviewers, &item.Status, &item.Date)
+
+		if err != nil {
+			log.Printf("ReadRows error : %v\n", err)
+			break
+		}
+
+		item.InitExtra()
+
+		items = append(items, item)
+	}
+	return &items
+}
+
+func (p *BroadcastManager) Get(id int64) *Broadcast {
+	if p.Conn == nil && p.Tx == nil {
+		return nil
+	}
+
+	query := p.GetQeury() + " and bc_id = ?"
+
+	rows, err := p.Query(query, id)
+
+	if err != nil {
+		log.Printf("query error : %v, %v\n", err, query)
+		return nil
+	}
+
+	defer rows.Close()
+
+	return p.ReadRow(rows)
+}
+
+func (p *BroadcastManager) Count(args []interface{}) int {
+	if p.Conn == nil && p.Tx == nil {
+		return 0
+	}
+
+	var params []interface{}
+	query := p.GetQeurySelect() + " where 1=1 "
+
+	for _, arg := range args {
+		switch v := arg.(type) {
+		case Where:
+			item := v
+
+			if item.Compare == "in" {
+				query += " and bc_id in (" + strings.Trim(strings.Replace(fmt.Sprint(item.Value), " ", ", ", -1), "[]") + ")"
+			} else if item.Compare == "between" {
+				query += " and bc_" + item.Column + " between ? and ?"
+
+				s := item.Value.([2]string)
+				params = append(params, s[0])
+				params = append(params, s[1])
+			} else {
+				query += " and bc_" + item.Column + " " + item.Compare + " ?"
+				if item.Compare == "like" {
+					params = append(params, "%"+item.Value.(string)+"%")
+				} else {
+					params = append(params, item.Value)
+				}
+			}
+		}
+	}
+
+	rows, err := p.Query(query, params...)
+
+	if err != nil {
+		log.Printf("query error : %v, %v\n", err, query)
+		return 0
+	}
+
+	defer rows.Close()
+
+	if !rows.Next() {
+		return 0
+	}
+
+	cnt := 0
+	err = rows.Scan(&cnt)
+
+	if err != nil {
+		return 0
+	} else {
+		return cnt
+	}
+}
+
+func (p *BroadcastManager) Find(args []interface{}) *[]Broadcast {
+	if p.Conn == nil && p.Tx == nil {
+		var items []Broadcast
+		return &items
+	}
+
+	var params []interface{}
+	query := p.GetQeury()
+
+	page := 0
+	pagesize := 0
+	orderby := ""
+
+	for _, arg := range args {
+		switch v := arg.(type) {
+		case PagingType:
+			item := v
+			page = item.Page
+			pagesize = item.Pagesize
+			break
+		case OrderingType:
+			item := v
+			orderby = item.Order
+			break
+		case LimitType:
+			item := v
+			page = 1
+			pagesize = item.Limit
+			break
+		case OptionType:
+			item := v
+			if item.Limit > 0 {
+				page = 1
+				pagesize = item.Limit
+			} else {
+				page = item.Page
+				pagesize = item.Pagesize
+			}
+			orderby = item.Order
+			break
+		case Where:
+			item := v
+
+			if item.Compare == "in" {
+				query += " and bc_id in (" + strings.Trim(strings.Replace(fmt.Sprint(item.Value), " ", ", ", -1), "[]") + ")"
+			} else if item.Compare == "between" {
+				query += " and bc_" + item.Column + " between ? and ?"
+
+				s := item.Value.([2]string)
+				params = append(params, s[0])
+				params = append(params, s[1])
+			} else {
+				query += " and bc_" + item.Column + " " + item.Compare + " ?"
+				if item.Compare == "like" {
+					params = append(params, "%"+item.Value.(string)+"%")
+				} else {
+					params = append(params, item.Value)
+				}
+			}
+		}
+	}
+
+	startpage := (page - 1) * pagesize
+
+	if page > 0 && pagesize > 0 {
+		if orderby == "" {
+			orderby = "bc_id"
+		} else {
+			orderby = "bc_" + orderby
+		}
+		query += " order by " + orderby
+		if config.Database == "mysql" {
+			query += " limit ? offset ?"
+			params = append(params, pagesize)
+			params = append(params, startpage)
+		} else if config.Database == "mssql" || config.Database == "sqlserver" {
+			query += "OFFSET ? ROWS FITCH NEXT ? ROWS ONLY"
+			params = append(params, startpage)
+			params = append(params, pagesize)
+		}
+	} else {
+		if orderby == "" {
+			orderby = "bc_id"
+		} else {
+			orderby = "bc_" + orderby
+		}
+		query += " order by " + orderby
+	}
+
+	rows, err := p.Query(query, params...)
+
+	if err != nil {
+		log.Printf("query error : %v, %v\n", err, query)
+		var items []Broadcast
+		return &items
+	}
+
+	defer rows.Close()
+
+	return p.ReadRows(rows)
+}
+
+func (p *BroadcastManager) GetByUser(user int64, args ...interface{}) *[]Broadcast {
+	if user != 0 {
+		args = append(args, Where{Column: "user", Value: user, Compare: "="})
+	}
+
+	return p.Find(args)
+}
+
+// 서버 재시작 등으로 종료 기록이 남지 않은 방송을 종료 처리
+func (p *BroadcastManager) CloseLive(enddate string) error {
+	if p.Conn == nil && p.Tx == nil {
+		return errors.New("Connection Error")
+	}
+
+	query := "update broadcast_tb set bc_enddate = ?, bc_status = ? where bc_status = ?"
+	_, err := p.Exec(query, enddate, BroadcastStatusEnded, BroadcastStatusLive)
+
+	return err
+}
+
+const (
+	BroadcastStatusLive  = 1
+	BroadcastStatusEnded = 2
+)
+
+// 방송 공개 범위 (이전 기록의 빈 값은 공개로 취급)
+const (
+	BroadcastVisibilityPublic   = "public"   // 목록에 노출
+	BroadcastVisibilityUnlisted = "unlisted" // 목록에 노출하지 않고 ID를 아는 사용자만 시청
+	BroadcastVisibilityPrivate  = "private"  // 비밀번호 또는 초대 토큰이 있어야 시청
+)
@@ -0,0 +1,424 @@
+package models
+
+import (
+	"toysgo/config"
+
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	_ "github.com/go-sql-driver/mysql"
+	log "github.com/sirupsen/logrus"
+)
+
+type BroadcastModerator struct {
+	Id          int64  `json:"id"`
+	Broadcaster int64  `json:"broadcaster"`
+	User        int64  `json:"user"`
+	Date        string `json:"date"`
+
+	Extra map[string]interface{} `json:"extra"`
+}
+
+type BroadcastModeratorManager struct {
+	Conn   *sql.DB
+	Tx     *sql.Tx
+	Result *sql.Result
+	Index  string
+}
+
+func (c *BroadcastModerator) AddExtra(key string, value interface{}) {
+	c.Extra[key] = value
+}
+
+func NewBroadcastModeratorManager(conn interface{}) *BroadcastModeratorManager {
+	var item BroadcastModeratorManager
+
+	if conn == nil {
+		item.Conn = NewConnection()
+	} else {
+		if v, ok := conn.(*sql.DB); ok {
+			item.Conn = v
+			item.Tx = nil
+		} else {
+			item.Tx = conn.(*sql.Tx)
+			item.Conn = nil
+		}
+	}
+
+	item.Index = ""
+	return &item
+}
+
+func (p *BroadcastModeratorManager) Close() {
+	if p.Conn != nil {
+		p.Conn.Close()
+	}
+}
+
+func (p *BroadcastModeratorManager) SetIndex(index string) {
+	p.Index = index
+}
+
+func (p *BroadcastModeratorManager) Exec(query string, params ...interface{}) (sql.Result, error) {
+	if p.Conn != nil {
+		return p.Conn.Exec(query, params...)
+	} else {
+		return p.Tx.Exec(query, params...)
+	}
+}
+
+func (p *BroadcastModeratorManager) Query(query string, params ...interface{}) (*sql.Rows, error) {
+	if p.Conn != nil {
+		return p.Conn.Query(query, params...)
+	} else {
+		return p.Tx.Query(query+" FOR UPDATE", params...)
+	}
+}
+
+func (p *BroadcastModeratorManager) GetQeury() string {
+	ret := ""
+
+	str := "select bm_id, bm_broadcaster, bm_user, bm_date from broadcastmoderator_tb "
+
+	if p.Index == "" {
+		ret = str
+	} else {
+		ret = str + " use index(" + p.Index + ")"
+	}
+
+	ret += "where 1=1 "
+
+	return ret
+}
+
+func (p *BroadcastModeratorManager) GetQeurySelect() string {
+	ret := ""
+
+	str := "select count(*) from broadcastmoderator_tb "
+
+	if p.Index == "" {
+		ret = str
+	} else {
+		ret = str + " use index(" + p.Index + ") "
+	}
+
+	return ret
+}
+
+func (p *BroadcastModeratorManager) Truncate() error {
+	if p.Conn == nil && p.Tx == nil {
+		return errors.New("Connection Error")
+	}
+
+	query := "truncate broadcastmoderator_tb "
+	p.Exec(query)
+
+	return nil
+}
+
+func (p *BroadcastModeratorManager) Insert(item *BroadcastModerator) error {
+	if p.Conn == nil && p.Tx == nil {
+		return errors.New("Connection Error")
+	}
+
+	if item.Date == "" {
+		t := time.Now()
+		item.Date = fmt.Sprintf("%04d-%02d-%02d %02d:%02d:%02d", t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second())
+	}
+
+	query := ""
+	var res sql.Result
+	var err error
+	if item.Id > 0 {
+		query = "insert into broadcastmoderator_tb (bm_id, bm_broadcaster, bm_user, bm_date) values (?, ?, ?, ?)"
+		res, err = p.Exec(query, item.Id, item.Broadcaster, item.User, item.Date)
+	} else {
+		query = "insert into broadcastmoderator_tb (bm_broadcaster, bm_user, bm_date) values (?, ?, ?)"
+		res, err = p.Exec(query, item.Broadcaster, item.User, item.Date)
+	}
+
+	if err == nil {
+		p.Result = &res
+	} else {
+		log.Println(err)
+		p.Result = nil
+	}
+
+	return err
+}
+
+func (p *BroadcastModeratorManager) Delete(id int64) error {
+	if p.Conn == nil && p.Tx == nil {
+		return errors.New("Connection Error")
+	}
+
+	query := "delete from broadcastmoderator_tb where bm_id = ?"
+	_, err := p.Exec(query, id)
+
+	return err
+}
+
+func (p *BroadcastModeratorManager) Update(item *BroadcastModerator) error {
+	if p.Conn == nil && p.Tx == nil {
+		return errors.New("Connection Error")
+	}
+
+	query := "update broadcastmoderator_tb set bm_broadcaster = ?, bm_user = ?, bm_date = ? where bm_id = ?"
+	_, err := p.Exec(query, item.Broadcaster, item.User, item.Date, item.Id)
+
+	return err
+}
+
+func (p *BroadcastModeratorManager) GetIdentity() int64 {
+	if p.Result == nil && p.Tx == nil {
+		return 0
+	}
+
+	id, err := (*p.Result).LastInsertId()
+
+	if err != nil {
+		return 0
+	} else {
+		return id
+	}
+}
+
+func (p *BroadcastModerator) InitExtra() {
+	p.Extra = map[string]interface{}{}
+}
+
+func (p *BroadcastModeratorManager) ReadRow(rows *sql.Rows) *BroadcastModerator {
+	var item BroadcastModerator
+	var err error
+
+	if rows.Next() {
+		err = rows.Scan(&item.Id, &item.Broadcaster, &item.User, &item.Date)
+	} else {
+		return nil
+	}
+	if err != nil {
+		return nil
+	} else {
+		item.InitExtra()
+		return &item
+	}
+}
+
+func (p *BroadcastModeratorManager) ReadRows(rows *sql.Rows) *[]BroadcastModerator {
+	var items []BroadcastModerator
+
+	for rows.Next() {
+		var item BroadcastModerator
+
+		err := rows.Scan(&item.Id, &item.Broadcaster, &item.User, &item.Date)
+
+		if err != nil {
+			log.Printf("ReadRows error : %v\n", err)
+			break
+		}
+
+		item.InitExtra()
+
+		items = append(items, item)
+	}
+	return &items
+}
+
+func (p *BroadcastModeratorManager) Get(id int64) *BroadcastModerator {
+	if p.Conn == nil && p.Tx == nil {
+		return nil
+	}
+
+	query := p.GetQeury() + " and bm_id = ?"
+
+	rows, err := p.Query(query, id)
+
+	if err != nil {
+		log.Printf("query error : %v, %v\n", err, query)
+		return nil
+	}
+
+	defer rows.Close()
+
+	return p.ReadRow(rows)
+}
+
+func (p *BroadcastModeratorManager) Count(args []interface{}) int {
+	if p.Conn == nil && p.Tx == nil {
+		return 0
+	}
+
+	var params []interface{}
+	query := p.GetQeurySelect() + " where 1=1 "
+
+	for _, arg := range args {
+		switch v := arg.(type) {
+		case Where:
+			item := v
+
+			if item.Compare == "in" {
+				query += " and bm_id in (" + strings.Trim(strings.Replace(fmt.Sprint(item.Value), " ", ", ", -1), "[]") + ")"
+			} else if item.Compare == "between" {
+				query += " and bm_" + item.Column + " between ? and ?"
+
+				s := item.Value.([2]string)
+				params = append(params, s[0])
+				params = append(params, s[1])
+			} else {
+				query += " and bm_" + item.Column + " " + item.Compare + " ?"
+				if item.Compare == "like" {
+					params = append(params, "%"+item.Value.(string)+"%")
+				} else {
+					params = append(params, item.Value)
+				}
+			}
+		}
+	}
+
+	rows, err := p.Query(query, params...)
+
+	if err != nil {
+		log.Printf("query error : %v, %v\n", err, query)
+		return 0
+	}
+
+	defer rows.Close()
+
+	if !rows.Next() {
+		return 0
+	}
+
+	cnt := 0
+	err = rows.Scan(&cnt)
+
+	if err != nil {
+		return 0
+	} else {
+		return cnt
+	}
+}
+
+func (p *BroadcastModeratorManager) Find(args []interface{}) *[]BroadcastModerator {
+	if p.Conn == nil && p.Tx == nil {
+		var items []BroadcastModerator
+		return &items
+	}
+
+	var params []interface{}
+	query := p.GetQeury()
+
+	page := 0
+	pagesize := 0
+	orderby := ""
+
+	for _, arg := range args {
+		switch v := arg.(type) {
+		case PagingType:
+			item := v
+			page = item.Page
+			pagesize = item.Pagesize
+			break
+		case OrderingType:
+			item := v
+			orderby = item.Order
+			break
+		case LimitType:
+			item := v
+			page = 1
+			pagesize = item.Limit
+			break
+		case OptionType:
+			item := v
+			if item.Limit > 0 {
+				page = 1
+				pagesize = item.Limit
+			} else {
+				page = item.Page
+				pagesize = item.Pagesize
+			}
+			orderby = item.Order
+			break
+		case Where:
+			item := v
+
+			if item.Compare == "in" {
+				query += " and bm_id in (" + strings.Trim(strings.Replace(fmt.Sprint(item.Value), " ", ", ", -1), "[]") + ")"
+			} else if item.Compare == "between" {
+				query += " and bm_" + item.Column + " between ? and ?"
+
+				s := item.Value.([2]string)
+				params = append(params, s[0])
+				params = append(params, s[1])
+			} else {
+				query += " and bm_" + item.Column + " " + item.Compare + " ?"
+				if item.Compare == "like" {
+					params = append(params, "%"+item.Value.(string)+"%")
+				} else {
+					params = append(params, item.Value)
+				}
+			}
+		}
+	}
+
+	startpage := (page - 1) * pagesize
+
+	if page > 0 && pagesize > 0 {
+		if orderby == "" {
+			orderby = "bm_id"
+		} else {
+			orderby = "bm_" + orderby
+		}
+		query += " order by " + orderby
+		if config.Database == "mysql" {
+			query += " limit ? offset ?"
+			params = append(params, pagesize)
+			params = append(params, startpage)
+		} else if config.Database == "mssql" || config.Database == "sqlserver" {
+			query += "OFFSET ? ROWS FITCH NEXT ? ROWS ONLY"
+			params = append(params, startpage)
+			params = append(params, pagesize)
+		}
+	} else {
+		if orderby == "" {
+			orderby = "bm_id"
+		} else {
+			orderby = "bm_" + orderby
+		}
+		query += " order by " + orderby
+	}
+
+	rows, err := p.Query(query, params...)
+
+	if err != nil {
+		log.Printf("query error : %v, %v\n", err, query)
+		var items []BroadcastModerator
+		return &items
+	}
+
+	defer rows.Close()
+
+	return p.ReadRows(rows)
+}
+
+func (p *BroadcastModeratorManager) GetByBroadcaster(broadcaster int64, args ...interface{}) *[]BroadcastModerator {
+	if broadcaster != 0 {
+		args = append(args, Where{Column: "broadcaster", Value: broadcaster, Compare: "="})
+	}
+
+	return p.Find(args)
+}
+
+// 방송자가 지정한 매니저 해제
+func (p *BroadcastModeratorManager) DeleteByUser(broadcaster int64, user int64) error {
+	if p.Conn == nil && p.Tx == nil {
+		return errors.New("Connection Error")
+	}
+
+	query := "delete from broadcastmoderator_tb where bm_broadcaster = ? and bm_user = ?"
+	_, err := p.Exec(query, broadcaster, user)
+
+	return err
+}
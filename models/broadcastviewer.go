@@ -0,0 +1,428 @@
+package models
+
+import (
+	"toysgo/config"
+
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	_ "github.com/go-sql-driver/mysql"
+	log "github.com/sirupsen/logrus"
+)
+
+type BroadcastViewer struct {
+	Id        int64  `json:"id"`
+	Broadcast int64  `json:"broadcast"`
+	User      int64  `json:"user"`
+	Name      string `json:"name"`
+	Joindate  string `json:"joindate"`
+	Leavedate string `json:"leavedate"`
+	Duration  int    `json:"duration"`
+	Date      string `json:"date"`
+
+	Extra map[string]interface{} `json:"extra"`
+}
+
+type BroadcastViewerManager struct {
+	Conn   *sql.DB
+	Tx     *sql.Tx
+	Result *sql.Result
+	Index  string
+}
+
+func (c *BroadcastViewer) AddExtra(key string, value interface{}) {
+	c.Extra[key] = value
+}
+
+func NewBroadcastViewerManager(conn interface{}) *BroadcastViewerManager {
+	var item BroadcastViewerManager
+
+	if conn == nil {
+		item.Conn = NewConnection()
+	} else {
+		if v, ok := conn.(*sql.DB); ok {
+			item.Conn = v
+			item.Tx = nil
+		} else {
+			item.Tx = conn.(*sql.Tx)
+			item.Conn = nil
+		}
+	}
+
+	item.Index = ""
+	return &item
+}
+
+func (p *BroadcastViewerManager) Close() {
+	if p.Conn != nil {
+		p.Conn.Close()
+	}
+}
+
+func (p *BroadcastViewerManager) SetIndex(index string) {
+	p.Index = index
+}
+
+func (p *BroadcastViewerManager) Exec(query string, params ...interface{}) (sql.Result, error) {
+	if p.Conn != nil {
+		return p.Conn.Exec(query, params...)
+	} else {
+		return p.Tx.Exec(query, params...)
+	}
+}
+
+func (p *BroadcastViewerManager) Query(query string, params ...interface{}) (*sql.Rows, error) {
+	if p.Conn != nil {
+		return p.Conn.Query(query, params...)
+	} else {
+		return p.Tx.Query(query+" FOR UPDATE", params...)
+	}
+}
+
+func (p *BroadcastViewerManager) GetQeury() string {
+	ret := ""
+
+	str := "select bv_id, bv_broadcast, bv_user, bv_name, bv_joindate, bv_leavedate, bv_duration, bv_date from broadcastviewer_tb "
+
+	if p.Index == "" {
+		ret = str
+	} else {
+		ret = str + " use index(" + p.Index + ")"
+	}
+
+	ret += "where 1=1 "
+
+	return ret
+}
+
+func (p *BroadcastViewerManager) GetQeurySelect() string {
+	ret := ""
+
+	str := "select count(*) from broadcastviewer_tb "
+
+	if p.Index == "" {
+		ret = str
+	} else {
+		ret = str + " use index(" + p.Index + ") "
+	}
+
+	return ret
+}
+
+func (p *BroadcastViewerManager) Truncate() error {
+	if p.Conn == nil && p.Tx == nil {
+		return errors.New("Connection Error")
+	}
+
+	query := "truncate broadcastviewer_tb "
+	p.Exec(query)
+
+	return nil
+}
+
+func (p *BroadcastViewerManager) Insert(item *BroadcastViewer) error {
+	if p.Conn == nil && p.Tx == nil {
+		return errors.New("Connection Error")
+	}
+
+	if item.Date == "" {
+		t := time.Now()
+		item.Date = fmt.Sprintf("%04d-%02d-%02d %02d:%02d:%02d", t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second())
+	}
+
+	query := ""
+	var res sql.Result
+	var err error
+	if item.Id > 0 {
+		query = "insert into broadcastviewer_tb (bv_id, bv_broadcast, bv_user, bv_name, bv_joindate, bv_leavedate, bv_duration, bv_date) values (?, ?, ?, ?, ?, ?, ?, ?)"
+		res, err = p.Exec(query, item.Id, item.Broadcast, item.User, item.Name, item.Joindate, item.Leavedate, item.Duration, item.Date)
+	} else {
+		query = "insert into broadcastviewer_tb (bv_broadcast, bv_user, bv_name, bv_joindate, bv_leavedate, bv_duration, bv_date) values (?, ?, ?, ?, ?, ?, ?)"
+		res, err = p.Exec(query, item.Broadcast, item.User, item.Name, item.Joindate, item.Leavedate, item.Duration, item.Date)
+	}
+
+	if err == nil {
+		p.Result = &res
+	} else {
+		log.Println(err)
+		p.Result = nil
+	}
+
+	return err
+}
+
+func (p *BroadcastViewerManager) Delete(id int64) error {
+	if p.Conn == nil && p.Tx == nil {
+		return errors.New("Connection Error")
+	}
+
+	query := "delete from broadcastviewer_tb where bv_id = ?"
+	_, err := p.Exec(query, id)
+
+	return err
+}
+
+func (p *BroadcastViewerManager) Update(item *BroadcastViewer) error {
+	if p.Conn == nil && p.Tx == nil {
+		return errors.New("Connection Error")
+	}
+
+	query := "update broadcastviewer_tb set bv_broadcast = ?, bv_user = ?, bv_name = ?, bv_joindate = ?, bv_leavedate = ?, bv_duration = ?, bv_date = ? where bv_id = ?"
+	_, err := p.Exec(query, item.Broadcast, item.User, item.Name, item.Joindate, item.Leavedate, item.Duration, item.Date, item.Id)
+
+	return err
+}
+
+func (p *BroadcastViewerManager) GetIdentity() int64 {
+	if p.Result == nil && p.Tx == nil {
+		return 0
+	}
+
+	id, err := (*p.Result).LastInsertId()
+
+	if err != nil {
+		return 0
+	} else {
+		return id
+	}
+}
+
+func (p *BroadcastViewer) InitExtra() {
+	p.Extra = map[string]interface{}{}
+}
+
+func (p *BroadcastViewerManager) ReadRow(rows *sql.Rows) *BroadcastViewer {
+	var item BroadcastViewer
+	var err error
+
+	if rows.Next() {
+		err = rows.Scan(&item.Id, &item.Broadcast, &item.User, &item.Name, &item.Joindate, &item.Leavedate, &item.Duration, &item.Date)
+	} else {
+		return nil
+	}
+	if err != nil {
+		return nil
+	} else {
+		item.InitExtra()
+		return &item
+	}
+}
+
+func (p *BroadcastViewerManager) ReadRows(rows *sql.Rows) *[]BroadcastViewer {
+	var items []BroadcastViewer
+
+	for rows.Next() {
+		var item BroadcastViewer
+
+		err := rows.Scan(&item.Id, &item.Broadcast, &item.User, &item.Name, &item.Joindate, &item.Leavedate, &item.Duration, &item.Date)
+
+		if err != nil {
+			log.Printf("ReadRows error : %v\n", err)
+			break
+		}
+
+		item.InitExtra()
+
+		items = append(items, item)
+	}
+	return &items
+}
+
+func (p *BroadcastViewerManager) Get(id int64) *BroadcastViewer {
+	if p.Conn == nil && p.Tx == nil {
+		return nil
+	}
+
+	query := p.GetQeury() + " and bv_id = ?"
+
+	rows, err := p.Query(query, id)
+
+	if err != nil {
+		log.Printf("query error : %v, %v\n", err, query)
+		return nil
+	}
+
+	defer rows.Close()
+
+	return p.ReadRow(rows)
+}
+
+func (p *BroadcastViewerManager) Count(args []interface{}) int {
+	if p.Conn == nil && p.Tx == nil {
+		return 0
+	}
+
+	var params []interface{}
+	query := p.GetQeurySelect() + " where 1=1 "
+
+	for _, arg := range args {
+		switch v := arg.(type) {
+		case Where:
+			item := v
+
+			if item.Compare == "in" {
+				query += " and bv_id in (" + strings.Trim(strings.Replace(fmt.Sprint(item.Value), " ", ", ", -1), "[]") + ")"
+			} else if item.Compare == "between" {
+				query += " and bv_" + item.Column + " between ? and ?"
+
+				s := item.Value.([2]string)
+				params = append(params, s[0])
+				params = append(params, s[1])
+			} else {
+				query += " and bv_" + item.Column + " " + item.Compare + " ?"
+				if item.Compare == "like" {
+					params = append(params, "%"+item.Value.(string)+"%")
+				} else {
+					params = append(params, item.Value)
+				}
+			}
+		}
+	}
+
+	rows, err := p.Query(query, params...)
+
+	if err != nil {
+		log.Printf("query error : %v, %v\n", err, query)
+		return 0
+	}
+
+	defer rows.Close()
+
+	if !rows.Next() {
+		return 0
+	}
+
+	cnt := 0
+	err = rows.Scan(&cnt)
+
+	if err != nil {
+		return 0
+	} else {
+		return cnt
+	}
+}
+
+func (p *BroadcastViewerManager) Find(args []interface{}) *[]BroadcastViewer {
+	if p.Conn == nil && p.Tx == nil {
+		var items []BroadcastViewer
+		return &items
+	}
+
+	var params []interface{}
+	query := p.GetQeury()
+
+	page := 0
+	pagesize := 0
+	orderby := ""
+
+	for _, arg := range args {
+		switch v := arg.(type) {
+		case PagingType:
+			item := v
+			page = item.Page
+			pagesize = item.Pagesize
+			break
+		case OrderingType:
+			item := v
+			orderby = item.Order
+			break
+		case LimitType:
+			item := v
+			page = 1
+			pagesize = item.Limit
+			break
+		case OptionType:
+			item := v
+			if item.Limit > 0 {
+				page = 1
+				pagesize = item.Limit
+			} else {
+				page = item.Page
+				pagesize = item.Pagesize
+			}
+			orderby = item.Order
+			break
+		case Where:
+			item := v
+
+			if item.Compare == "in" {
+				query += " and bv_id in (" + strings.Trim(strings.Replace(fmt.Sprint(item.Value), " ", ", ", -1), "[]") + ")"
+			} else if item.Compare == "between" {
+				query += " and bv_" + item.Column + " between ? and ?"
+
+				s := item.Value.([2]string)
+				params = append(params, s[0])
+				params = append(params, s[1])
+			} else {
+				query += " and bv_" + item.Column + " " + item.Compare + " ?"
+				if item.Compare == "like" {
+					params = append(params, "%"+item.Value.(string)+"%")
+				} else {
+					params = append(params, item.Value)
+				}
+			}
+		}
+	}
+
+	startpage := (page - 1) * pagesize
+
+	if page > 0 && pagesize > 0 {
+		if orderby == "" {
+			orderby = "bv_id"
+		} else {
+			orderby = "bv_" + orderby
+		}
+		query += " order by " + orderby
+		if config.Database == "mysql" {
+			query += " limit ? offset ?"
+			params = append(params, pagesize)
+			params = append(params, startpage)
+		} else if config.Database == "mssql" || config.Database == "sqlserver" {
+			query += "OFFSET ? ROWS FITCH NEXT ? ROWS ONLY"
+			params = append(params, startpage)
+			params = append(params, pagesize)
+		}
+	} else {
+		if orderby == "" {
+			orderby = "bv_id"
+		} else {
+			orderby = "bv_" + orderby
+		}
+		query += " order by " + orderby
+	}
+
+	rows, err := p.Query(query, params...)
+
+	if err != nil {
+		log.Printf("query error : %v, %v\n", err, query)
+		var items []BroadcastViewer
+		return &items
+	}
+
+	defer rows.Close()
+
+	return p.ReadRows(rows)
+}
+
+func (p *BroadcastViewerManager) GetByBroadcast(broadcast int64, args ...interface{}) *[]BroadcastViewer {
+	if broadcast != 0 {
+		args = append(args, Where{Column: "broadcast", Value: broadcast, Compare: "="})
+	}
+
+	return p.Find(args)
+}
+
+// 서버 재시작 등으로 퇴장 기록이 남지 않은 시청 기록을 종료 처리
+func (p *BroadcastViewerManager) CloseOpen(leavedate string) error {
+	if p.Conn == nil && p.Tx == nil {
+		return errors.New("Connection Error")
+	}
+
+	query := "update broadcastviewer_tb set bv_leavedate = ? where bv_leavedate = ''"
+	_, err := p.Exec(query, leavedate)
+
+	return err
+}
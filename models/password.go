@@ -0,0 +1,121 @@
+package models
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+	"toysgo/config"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// 비밀번호 해시 알고리즘
+const (
+	PasswordHashBcrypt   = "bcrypt"
+	PasswordHashArgon2id = "argon2id"
+)
+
+// argon2id 솔트와 키 길이 (바이트)
+const (
+	argon2SaltLength = 16
+	argon2KeyLength  = 32
+)
+
+// 이전 소셜 로그인 가입자에게 일괄 저장되던 공용 평문 비밀번호
+// 알려진 값이라 소셜 가입 계정에서는 로그인에 쓸 수 없음 (UserManager.GetPasswd에서 걸러냄)
+const legacySocialPasswd = "qwer1234!!"
+
+var ErrPasswordHash = errors.New("invalid password hash")
+
+// 설정된 알고리즘으로 비밀번호 해시 생성 (빈 비밀번호는 빈 문자열, 사용할 수 없는 비밀번호)
+func HashPassword(passwd string) (string, error) {
+	if passwd == "" {
+		return "", nil
+	}
+
+	if config.PasswordHash == PasswordHashArgon2id {
+		salt := make([]byte, argon2SaltLength)
+		if _, err := rand.Read(salt); err != nil {
+			return "", err
+		}
+		key := argon2.IDKey([]byte(passwd), salt, uint32(config.PasswordArgon2Time), uint32(config.PasswordArgon2Memory), uint8(config.PasswordArgon2Threads), argon2KeyLength)
+		return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s", argon2.Version, config.PasswordArgon2Memory, config.PasswordArgon2Time, config.PasswordArgon2Threads,
+			base64.RawStdEncoding.EncodeToString(salt), base64.RawStdEncoding.EncodeToString(key)), nil
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(passwd), config.PasswordBcryptCost)
+	if err != nil {
+		return "", err
+	}
+	return string(hash), nil
+}
+
+// 저장된 값이 비밀번호 해시인지 (아니면 이전 평문 저장값)
+func IsPasswordHash(stored string) bool {
+	return strings.HasPrefix(stored, "$2a$") || strings.HasPrefix(stored, "$2b$") || strings.HasPrefix(stored, "$2y$") || strings.HasPrefix(stored, "$argon2id$")
+}
+
+// 비밀번호 확인 (일치 여부와 현재 설정으로 다시 해시해야 하는지 반환)
+// 평문으로 저장된 값도 상수 시간 비교로 확인하고, 일치하면 다시 해시하도록 알린다.
+func VerifyPassword(stored string, passwd string) (bool, bool) {
+	if stored == "" || passwd == "" {
+		return false, false
+	}
+
+	switch {
+	case strings.HasPrefix(stored, "$argon2id$"):
+		memory, time, threads, salt, key, err := parseArgon2Hash(stored)
+		if err != nil {
+			return false, false
+		}
+		compare := argon2.IDKey([]byte(passwd), salt, time, memory, threads, uint32(len(key)))
+		if subtle.ConstantTimeCompare(compare, key) != 1 {
+			return false, false
+		}
+		rehash := config.PasswordHash != PasswordHashArgon2id ||
+			memory != uint32(config.PasswordArgon2Memory) || time != uint32(config.PasswordArgon2Time) || threads != uint8(config.PasswordArgon2Threads)
+		return true, rehash
+	case IsPasswordHash(stored):
+		if bcrypt.CompareHashAndPassword([]byte(stored), []byte(passwd)) != nil {
+			return false, false
+		}
+		cost, _ := bcrypt.Cost([]byte(stored))
+		return true, config.PasswordHash == PasswordHashArgon2id || cost < config.PasswordBcryptCost
+	default:
+		ok := subtle.ConstantTimeCompare([]byte(stored), []byte(passwd)) == 1
+		return ok, ok
+	}
+}
+
+// $argon2id$v=19$m=65536,t=3,p=2$salt$key
+func parseArgon2Hash(stored string) (uint32, uint32, uint8, []byte, []byte, error) {
+	parts := strings.Split(stored, "$")
+	if len(parts) != 6 {
+		return 0, 0, 0, nil, nil, ErrPasswordHash
+	}
+
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return 0, 0, 0, nil, nil, ErrPasswordHash
+	}
+
+	var memory, time uint32
+	var threads uint8
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &memory, &time, &threads); err != nil {
+		return 0, 0, 0, nil, nil, ErrPasswordHash
+	}
+
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return 0, 0, 0, nil, nil, ErrPasswordHash
+	}
+	key, err := base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil || len(key) == 0 {
+		return 0, 0, 0, nil, nil, ErrPasswordHash
+	}
+	return memory, time, threads, salt, key, nil
+}
@@ -0,0 +1,126 @@
+package models
+
+import (
+	"strings"
+	"testing"
+	"toysgo/config"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// 테스트 속도를 위해 해시 비용을 낮추고, 끝나면 설정을 되돌림
+func setPasswordConfig(t *testing.T, hash string, cost int, time int, memory int, threads int) {
+	t.Helper()
+
+	saved := []interface{}{config.PasswordHash, config.PasswordBcryptCost, config.PasswordArgon2Time, config.PasswordArgon2Memory, config.PasswordArgon2Threads}
+	t.Cleanup(func() {
+		config.PasswordHash = saved[0].(string)
+		config.PasswordBcryptCost = saved[1].(int)
+		config.PasswordArgon2Time = saved[2].(int)
+		config.PasswordArgon2Memory = saved[3].(int)
+		config.PasswordArgon2Threads = saved[4].(int)
+	})
+
+	config.PasswordHash = hash
+	config.PasswordBcryptCost = cost
+	config.PasswordArgon2Time = time
+	config.PasswordArgon2Memory = memory
+	config.PasswordArgon2Threads = threads
+}
+
+func mustHashPassword(t *testing.T, passwd string) string {
+	t.Helper()
+
+	hash, err := HashPassword(passwd)
+	if err != nil {
+		t.Fatalf("HashPassword: %v", err)
+	}
+	return hash
+}
+
+func TestHashPassword(t *testing.T) {
+	tests := []struct {
+		name   string
+		hash   string
+		passwd string
+		prefix string
+	}{
+		{"bcrypt", PasswordHashBcrypt, "secret", "$2a$"},
+		{"argon2id", PasswordHashArgon2id, "secret", "$argon2id$v=19$m=1024,t=1,p=1$"},
+		{"empty bcrypt", PasswordHashBcrypt, "", ""},
+		{"empty argon2id", PasswordHashArgon2id, "", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setPasswordConfig(t, tt.hash, bcrypt.MinCost, 1, 1024, 1)
+
+			hash := mustHashPassword(t, tt.passwd)
+			if tt.prefix == "" {
+				if hash != "" {
+					t.Fatalf("hash = %q, want empty", hash)
+				}
+				return
+			}
+			if !strings.HasPrefix(hash, tt.prefix) {
+				t.Fatalf("hash = %q, want prefix %q", hash, tt.prefix)
+			}
+			if !IsPasswordHash(hash) {
+				t.Fatalf("IsPasswordHash(%q) = false", hash)
+			}
+			if hash == mustHashPassword(t, tt.passwd) {
+				t.Fatalf("same password hashed twice without a new salt")
+			}
+		})
+	}
+}
+
+func TestVerifyPassword(t *testing.T) {
+	setPasswordConfig(t, PasswordHashBcrypt, bcrypt.MinCost, 1, 1024, 1)
+	bcryptHash := mustHashPassword(t, "secret")
+
+	config.PasswordHash = PasswordHashArgon2id
+	argon2Hash := mustHashPassword(t, "secret")
+
+	tests := []struct {
+		name   string
+		config func()
+		stored string
+		passwd string
+		ok     bool
+		rehash bool
+	}{
+		{"bcrypt match", nil, bcryptHash, "secret", true, false},
+		{"bcrypt mismatch", nil, bcryptHash, "wrong", false, false},
+		{"bcrypt cost raised", func() { config.PasswordBcryptCost = bcrypt.MinCost + 1 }, bcryptHash, "secret", true, true},
+		{"bcrypt to argon2id", func() { config.PasswordHash = PasswordHashArgon2id }, bcryptHash, "secret", true, true},
+		{"argon2id match", func() { config.PasswordHash = PasswordHashArgon2id }, argon2Hash, "secret", true, false},
+		{"argon2id mismatch", func() { config.PasswordHash = PasswordHashArgon2id }, argon2Hash, "wrong", false, false},
+		{"argon2id memory changed", func() {
+			config.PasswordHash = PasswordHashArgon2id
+			config.PasswordArgon2Memory = 2048
+		}, argon2Hash, "secret", true, true},
+		{"argon2id to bcrypt", nil, argon2Hash, "secret", true, true},
+		{"argon2id malformed", nil, "$argon2id$v=19$m=1024$salt$key", "secret", false, false},
+		{"legacy plaintext match", nil, "secret", "secret", true, true},
+		{"legacy plaintext mismatch", nil, "secret", "wrong", false, false},
+		{"legacy social password chosen by email user", nil, legacySocialPasswd, legacySocialPasswd, true, true},
+		{"empty stored", nil, "", "", false, false},
+		{"empty input", nil, bcryptHash, "", false, false},
+		{"hash as input", nil, "secret", bcryptHash, false, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setPasswordConfig(t, PasswordHashBcrypt, bcrypt.MinCost, 1, 1024, 1)
+			if tt.config != nil {
+				tt.config()
+			}
+
+			ok, rehash := VerifyPassword(tt.stored, tt.passwd)
+			if ok != tt.ok || rehash != tt.rehash {
+				t.Fatalf("VerifyPassword = (%v, %v), want (%v, %v)", ok, rehash, tt.ok, tt.rehash)
+			}
+		})
+	}
+}
@@ -0,0 +1,439 @@
+package models
+
+import (
+	"toysgo/config"
+
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	_ "github.com/go-sql-driver/mysql"
+	log "github.com/sirupsen/logrus"
+)
+
+type Recording struct {
+	Id         int64  `json:"id"`
+	Broadcast  int64  `json:"broadcast"`
+	User       int64  `json:"user"`
+	Title      string `json:"title"`
+	Audiofile  string `json:"audiofile"`
+	Videofile  string `json:"videofile"`
+	Videocodec string `json:"videocodec"`
+	Size       int64  `json:"size"`
+	Duration   int    `json:"duration"`
+	Status     int    `json:"status"`
+	Startdate  string `json:"startdate"`
+	Enddate    string `json:"enddate"`
+	Date       string `json:"date"`
+
+	Extra map[string]interface{} `json:"extra"`
+}
+
+type RecordingManager struct {
+	Conn   *sql.DB
+	Tx     *sql.Tx
+	Result *sql.Result
+	Index  string
+}
+
+func (c *Recording) AddExtra(key string, value interface{}) {
+	c.Extra[key] = value
+}
+
+func NewRecordingManager(conn interface{}) *RecordingManager {
+	var item RecordingManager
+
+	if conn == nil {
+		item.Conn = NewConnection()
+	} else {
+		if v, ok := conn.(*sql.DB); ok {
+			item.Conn = v
+			item.Tx = nil
+		} else {
+			item.Tx = conn.(*sql.Tx)
+			item.Conn = nil
+		}
+	}
+
+	item.Index = ""
+	return &item
+}
+
+func (p *RecordingManager) Close() {
+	if p.Conn != nil {
+		p.Conn.Close()
+	}
+}
+
+func (p *RecordingManager) SetIndex(index string) {
+	p.Index = index
+}
+
+func (p *RecordingManager) Exec(query string, params ...interface{}) (sql.Result, error) {
+	if p.Conn != nil {
+		return p.Conn.Exec(query, params...)
+	} else {
+		return p.Tx.Exec(query, params...)
+	}
+}
+
+func (p *RecordingManager) Query(query string, params ...interface{}) (*sql.Rows, error) {
+	if p.Conn != nil {
+		return p.Conn.Query(query, params...)
+	} else {
+		return p.Tx.Query(query+" FOR UPDATE", params...)
+	}
+}
+
+func (p *RecordingManager) GetQeury() string {
+	ret := ""
+
+	str := "select rc_id, rc_broadcast, rc_user, rc_title, rc_audiofile, rc_videofile, rc_videocodec, rc_size, rc_duration, rc_status, rc_startdate, rc_enddate, rc_date from recording_tb "
+
+	if p.Index == "" {
+		ret = str
+	} else {
+		ret = str + " use index(" + p.Index + ")"
+	}
+
+	ret += "where 1=1 "
+
+	return ret
+}
+
+func (p *RecordingManager) GetQeurySelect() string {
+	ret := ""
+
+	str := "select count(*) from recording_tb "
+
+	if p.Index == "" {
+		ret = str
+	} else {
+		ret = str + " use index(" + p.Index + ") "
+	}
+
+	return ret
+}
+
+func (p *RecordingManager) Truncate() error {
+	if p.Conn == nil && p.Tx == nil {
+		return errors.New("Connection Error")
+	}
+
+	query := "truncate recording_tb "
+	p.Exec(query)
+
+	return nil
+}
+
+func (p *RecordingManager) Insert(item *Recording) error {
+	if p.Conn == nil && p.Tx == nil {
+		return errors.New("Connection Error")
+	}
+
+	if item.Date == "" {
+		t := time.Now()
+		item.Date = fmt.Sprintf("%04d-%02d-%02d %02d:%02d:%02d", t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second())
+	}
+
+	query := ""
+	var res sql.Result
+	var err error
+	if item.Id > 0 {
+		query = "insert into recording_tb (rc_id, rc_broadcast, rc_user, rc_title, rc_audiofile, rc_videofile, rc_videocodec, rc_size, rc_duration, rc_status, rc_startdate, rc_enddate, rc_date) values (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)"
+		res, err = p.Exec(query, item.Id, item.Broadcast, item.User, item.Title, item.Audiofile, item.Videofile, item.Videocodec, item.Size, item.Duration, item.Status, item.Startdate, item.Enddate, item.Date)
+	} else {
+		query = "insert into recording_tb (rc_broadcast, rc_user, rc_title, rc_audiofile, rc_videofile, rc_videocodec, rc_size, rc_duration, rc_status, rc_startdate, rc_enddate, rc_date) values (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)"
+		res, err = p.Exec(query, item.Broadcast, item.User, item.Title, item.Audiofile, item.Videofile, item.Videocodec, item.Size, item.Duration, item.Status, item.Startdate, item.Enddate, item.Date)
+	}
+
+	if err == nil {
+		p.Result = &res
+	} else {
+		log.Println(err)
+		p.Result = nil
+	}
+
+	return err
+}
+
+func (p *RecordingManager) Delete(id int64) error {
+	if p.Conn == nil && p.Tx == nil {
+		return errors.New("Connection Error")
+	}
+
+	query := "delete from recording_tb where rc_id = ?"
+	_, err := p.Exec(query, id)
+
+	return err
+}
+
+func (p *RecordingManager) Update(item *Recording) error {
+	if p.Conn == nil && p.Tx == nil {
+		return errors.New("Connection Error")
+	}
+
+	query := "update recording_tb set rc_broadcast = ?, rc_user = ?, rc_title = ?, rc_audiofile = ?, rc_videofile = ?, rc_videocodec = ?, rc_size = ?, rc_duration = ?, rc_status = ?, rc_startdate = ?, rc_enddate = ?, rc_date = ? where rc_id = ?"
+	_, err := p.Exec(query, item.Broadcast, item.User, item.Title, item.Audiofile, item.Videofile, item.Videocodec, item.Size, item.Duration, item.Status, item.Startdate, item.Enddate, item.Date, item.Id)
+
+	return err
+}
+
+func (p *RecordingManager) GetIdentity() int64 {
+	if p.Result == nil && p.Tx == nil {
+		return 0
+	}
+
+	id, err := (*p.Result).LastInsertId()
+
+	if err != nil {
+		return 0
+	} else {
+		return id
+	}
+}
+
+func (p *Recording) InitExtra() {
+	p.Extra = map[string]interface{}{}
+}
+
+func (p *RecordingManager) ReadRow(rows *sql.Rows) *Recording {
+	var item Recording
+	var err error
+
+	if rows.Next() {
+		err = rows.Scan(&item.Id, &item.Broadcast, &item.User, &item.Title, &item.Audiofile, &item.Videofile, &item.Videocodec, &item.Size, &item.Duration, &item.Status, &item.Startdate, &item.Enddate, &item.Date)
+	} else {
+		return nil
+	}
+	if err != nil {
+		return nil
+	} else {
+		item.InitExtra()
+		return &item
+	}
+}
+
+func (p *RecordingManager) ReadRows(rows *sql.Rows) *[]Recording {
+	var items []Recording
+
+	for rows.Next() {
+		var item Recording
+
+		err := rows.Scan(&item.Id, &item.Broadcast, &item.User, &item.Title, &item.Audiofile, &item.Videofile, &item.Videocodec, &item.Size, &item.Duration, &item.Status, &item.Startdate, &item.Enddate, &item.Date)
+
+		if err != nil {
+			log.Printf("ReadRows error : %v\n", err)
+			break
+		}
+
+		item.InitExtra()
+
+		items = append(items, item)
+	}
+	return &items
+}
+
+func (p *RecordingManager) Get(id int64) *Recording {
+	if p.Conn == nil && p.Tx == nil {
+		return nil
+	}
+
+	query := p.GetQeury() + " and rc_id = ?"
+
+	rows, err := p.Query(query, id)
+
+	if err != nil {
+		log.Printf("query error : %v, %v\n", err, query)
+		return nil
+	}
+
+	defer rows.Close()
+
+	return p.ReadRow(rows)
+}
+
+func (p *RecordingManager) Count(args []interface{}) int {
+	if p.Conn == nil && p.Tx == nil {
+		return 0
+	}
+
+	var params []interface{}
+	query := p.GetQeurySelect() + " where 1=1 "
+
+	for _, arg := range args {
+		switch v := arg.(type) {
+		case Where:
+			item := v
+
+			if item.Compare == "in" {
+				query += " and rc_id in (" + strings.Trim(strings.Replace(fmt.Sprint(item.Value), " ", ", ", -1), "[]") + ")"
+			} else if item.Compare == "between" {
+				query += " and rc_" + item.Column + " between ? and ?"
+
+				s := item.Value.([2]string)
+				params = append(params, s[0])
+				params = append(params, s[1])
+			} else {
+				query += " and rc_" + item.Column + " " + item.Compare + " ?"
+				if item.Compare == "like" {
+					params = append(params, "%"+item.Value.(string)+"%")
+				} else {
+					params = append(params, item.Value)
+				}
+			}
+		}
+	}
+
+	rows, err := p.Query(query, params...)
+
+	if err != nil {
+		log.Printf("query error : %v, %v\n", err, query)
+		return 0
+	}
+
+	defer rows.Close()
+
+	if !rows.Next() {
+		return 0
+	}
+
+	cnt := 0
+	err = rows.Scan(&cnt)
+
+	if err != nil {
+		return 0
+	} else {
+		return cnt
+	}
+}
+
+func (p *RecordingManager) Find(args []interface{}) *[]Recording {
+	if p.Conn == nil && p.Tx == nil {
+		var items []Recording
+		return &items
+	}
+
+	var params []interface{}
+	query := p.GetQeury()
+
+	page := 0
+	pagesize := 0
+	orderby := ""
+
+	for _, arg := range args {
+		switch v := arg.(type) {
+		case PagingType:
+			item := v
+			page = item.Page
+			pagesize = item.Pagesize
+			break
+		case OrderingType:
+			item := v
+			orderby = item.Order
+			break
+		case LimitType:
+			item := v
+			page = 1
+			pagesize = item.Limit
+			break
+		case OptionType:
+			item := v
+			if item.Limit > 0 {
+				page = 1
+				pagesize = item.Limit
+			} else {
+				page = item.Page
+				pagesize = item.Pagesize
+			}
+			orderby = item.Order
+			break
+		case Where:
+			item := v
+
+			if item.Compare == "in" {
+				query += " and rc_id in (" + strings.Trim(strings.Replace(fmt.Sprint(item.Value), " ", ", ", -1), "[]") + ")"
+			} else if item.Compare == "between" {
+				query += " and rc_" + item.Column + " between ? and ?"
+
+				s := item.Value.([2]string)
+				params = append(params, s[0])
+				params = append(params, s[1])
+			} else {
+				query += " and rc_" + item.Column + " " + item.Compare + " ?"
+				if item.Compare == "like" {
+					params = append(params, "%"+item.Value.(string)+"%")
+				} else {
+					params = append(params, item.Value)
+				}
+			}
+		}
+	}
+
+	startpage := (page - 1) * pagesize
+
+	if page > 0 && pagesize > 0 {
+		if orderby == "" {
+			orderby = "rc_id"
+		} else {
+			orderby = "rc_" + orderby
+		}
+		query += " order by " + orderby
+		if config.Database == "mysql" {
+			query += " limit ? offset ?"
+			params = append(params, pagesize)
+			params = append(params, startpage)
+		} else if config.Database == "mssql" || config.Database == "sqlserver" {
+			query += "OFFSET ? ROWS FITCH NEXT ? ROWS ONLY"
+			params = append(params, startpage)
+			params = append(params, pagesize)
+		}
+	} else {
+		if orderby == "" {
+			orderby = "rc_id"
+		} else {
+			orderby = "rc_" + orderby
+		}
+		query += " order by " + orderby
+	}
+
+	rows, err := p.Query(query, params...)
+
+	if err != nil {
+		log.Printf("query error : %v, %v\n", err, query)
+		var items []Recording
+		return &items
+	}
+
+	defer rows.Close()
+
+	return p.ReadRows(rows)
+}
+
+func (p *RecordingManager) GetByUser(user int64, args ...interface{}) *[]Recording {
+	if user != 0 {
+		args = append(args, Where{Column: "user", Value: user, Compare: "="})
+	}
+
+	return p.Find(args)
+}
+
+// 서버 재시작 등으로 녹화 종료 처리가 되지 않은 기록을 중단 처리
+func (p *RecordingManager) CloseRecording(enddate string) error {
+	if p.Conn == nil && p.Tx == nil {
+		return errors.New("Connection Error")
+	}
+
+	query := "update recording_tb set rc_enddate = ?, rc_status = ? where rc_status = ?"
+	_, err := p.Exec(query, enddate, RecordingStatusInterrupted, RecordingStatusRecording)
+
+	return err
+}
+
+const (
+	RecordingStatusRecording   = 1
+	RecordingStatusCompleted   = 2
+	RecordingStatusInterrupted = 3
+)
@@ -0,0 +1,429 @@
+package models
+
+import (
+	"toysgo/config"
+
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	_ "github.com/go-sql-driver/mysql"
+	log "github.com/sirupsen/logrus"
+)
+
+type StreamStat struct {
+	Id          int64   `json:"id"`
+	Broadcast   int64   `json:"broadcast"`
+	User        int64   `json:"user"`
+	Peer        string  `json:"peer"`
+	Role        string  `json:"role"`
+	Source      string  `json:"source"`
+	Kind        string  `json:"kind"`
+	Layer       string  `json:"layer"`
+	Bitrate     int64   `json:"bitrate"`
+	Packetslost int64   `json:"packetslost"`
+	Loss        float64 `json:"loss"`
+	Jitter      float64 `json:"jitter"`
+	Rtt         float64 `json:"rtt"`
+	Framerate   float64 `json:"framerate"`
+	Freezes     int     `json:"freezes"`
+	Date        string  `json:"date"`
+
+	Extra map[string]interface{} `json:"extra"`
+}
+
+type StreamStatManager struct {
+	Conn   *sql.DB
+	Tx     *sql.Tx
+	Result *sql.Result
+	Index  string
+}
+
+func (c *StreamStat) AddExtra(key string, value interface{}) {
+	c.Extra[key] = value
+}
+
+func NewStreamStatManager(conn interface{}) *StreamStatManager {
+	var item StreamStatManager
+
+	if conn == nil {
+		item.Conn = NewConnection()
+	} else {
+		if v, ok := conn.(*sql.DB); ok {
+			item.Conn = v
+			item.Tx = nil
+		} else {
+			item.Tx = conn.(*sql.Tx)
+			item.Conn = nil
+		}
+	}
+
+	item.Index = ""
+	return &item
+}
+
+func (p *StreamStatManager) Close() {
+	if p.Conn != nil {
+		p.Conn.Close()
+	}
+}
+
+func (p *StreamStatManager) SetIndex(index string) {
+	p.Index = index
+}
+
+func (p *StreamStatManager) Exec(query string, params ...interface{}) (sql.Result, error) {
+	if p.Conn != nil {
+		return p.Conn.Exec(query, params...)
+	} else {
+		return p.Tx.Exec(query, params...)
+	}
+}
+
+func (p *StreamStatManager) Query(query string, params ...interface{}) (*sql.Rows, error) {
+	if p.Conn != nil {
+		return p.Conn.Query(query, params...)
+	} else {
+		return p.Tx.Query(query+" FOR UPDATE", params...)
+	}
+}
+
+func (p *StreamStatManager) GetQeury() string {
+	ret := ""
+
+	str := "select ss_id, ss_broadcast, ss_user, ss_peer, ss_role, ss_source, ss_kind, ss_layer, ss_bitrate, ss_packetslost, ss_loss, ss_jitter, ss_rtt, ss_framerate, ss_freezes, ss_date from streamstat_tb "
+
+	if p.Index == "" {
+		ret = str
+	} else {
+		ret = str + " use index(" + p.Index + ")"
+	}
+
+	ret += "where 1=1 "
+
+	return ret
+}
+
+func (p *StreamStatManager) GetQeurySelect() string {
+	ret := ""
+
+	str := "select count(*) from streamstat_tb "
+
+	if p.Index == "" {
+		ret = str
+	} else {
+		ret = str + " use index(" + p.Index + ") "
+	}
+
+	return ret
+}
+
+func (p *StreamStatManager) Truncate() error {
+	if p.Conn == nil && p.Tx == nil {
+		return errors.New("Connection Error")
+	}
+
+	query := "truncate streamstat_tb "
+	p.Exec(query)
+
+	return nil
+}
+
+func (p *StreamStatManager) Insert(item *StreamStat) error {
+	if p.Conn == nil && p.Tx == nil {
+		return errors.New("Connection Error")
+	}
+
+	if item.Date == "" {
+		t := time.Now()
+		item.Date = fmt.Sprintf("%04d-%02d-%02d %02d:%02d:%02d", t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second())
+	}
+
+	query := ""
+	var res sql.Result
+	var err error
+	if item.Id > 0 {
+		query = "insert into streamstat_tb (ss_id, ss_broadcast, ss_user, ss_peer, ss_role, ss_source, ss_kind, ss_layer, ss_bitrate, ss_packetslost, ss_loss, ss_jitter, ss_rtt, ss_framerate, ss_freezes, ss_date) values (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)"
+		res, err = p.Exec(query, item.Id, item.Broadcast, item.User, item.Peer, item.Role, item.Source, item.Kind, item.Layer, item.Bitrate, item.Packetslost, item.Loss, item.Jitter, item.Rtt, item.Framerate, item.Freezes, item.Date)
+	} else {
+		query = "insert into streamstat_tb (ss_broadcast, ss_user, ss_peer, ss_role, ss_source, ss_kind, ss_layer, ss_bitrate, ss_packetslost, ss_loss, ss_jitter, ss_rtt, ss_framerate, ss_freezes, ss_date) values (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)"
+		res, err = p.Exec(query, item.Broadcast, item.User, item.Peer, item.Role, item.Source, item.Kind, item.Layer, item.Bitrate, item.Packetslost, item.Loss, item.Jitter, item.Rtt, item.Framerate, item.Freezes, item.Date)
+	}
+
+	if err == nil {
+		p.Result = &res
+	} else {
+		log.Println(err)
+		p.Result = nil
+	}
+
+	return err
+}
+
+func (p *StreamStatManager) Delete(id int64) error {
+	if p.Conn == nil && p.Tx == nil {
+		return errors.New("Connection Error")
+	}
+
+	query := "delete from streamstat_tb where ss_id = ?"
+	_, err := p.Exec(query, id)
+
+	return err
+}
+
+func (p *StreamStatManager) Update(item *StreamStat) error {
+	if p.Conn == nil && p.Tx == nil {
+		return errors.New("Connection Error")
+	}
+
+	query := "update streamstat_tb set ss_broadcast = ?, ss_user = ?, ss_peer = ?, ss_role = ?, ss_source = ?, ss_kind = ?, ss_layer = ?, ss_bitrate = ?, ss_packetslost = ?, ss_loss = ?, ss_jitter = ?, ss_rtt = ?, ss_framerate = ?, ss_freezes = ?, ss_date = ? where ss_id = ?"
+	_, err := p.Exec(query, item.Broadcast, item.User, item.Peer, item.Role, item.Source, item.Kind, item.Layer, item.Bitrate, item.Packetslost, item.Loss, item.Jitter, item.Rtt, item.Framerate, item.Freezes, item.Date, item.Id)
+
+	return err
+}
+
+func (p *StreamStatManager) GetIdentity() int64 {
+	if p.Result == nil && p.Tx == nil {
+		return 0
+	}
+
+	id, err := (*p.Result).LastInsertId()
+
+	if err != nil {
+		return 0
+	} else {
+		return id
+	}
+}
+
+func (p *StreamStat) InitExtra() {
+	p.Extra = map[string]interface{}{}
+}
+
+func (p *StreamStatManager) ReadRow(rows *sql.Rows) *StreamStat {
+	var item StreamStat
+	var err error
+
+	if rows.Next() {
+		err = rows.Scan(&item.Id, &item.Broadcast, &item.User, &item.Peer, &item.Role, &item.Source, &item.Kind, &item.Layer, &item.Bitrate, &item.Packetslost, &item.Loss, &item.Jitter, &item.Rtt, &item.Framerate, &item.Freezes, &item.Date)
+	} else {
+		return nil
+	}
+	if err != nil {
+		return nil
+	} else {
+		item.InitExtra()
+		return &item
+	}
+}
+
+func (p *StreamStatManager) ReadRows(rows *sql.Rows) *[]StreamStat {
+	var items []StreamStat
+
+	for rows.Next() {
+		var item StreamStat
+
+		err := rows.Scan(&item.Id, &item.Broadcast, &item.User, &item.Peer, &item.Role, &item.Source, &item.Kind, &item.Layer, &item.Bitrate, &item.Packetslost, &item.Loss, &item.Jitter, &item.Rtt, &item.Framerate, &item.Freezes, &item.Date)
+
+		if err != nil {
+			log.Printf("ReadRows error : %v\n", err)
+			break
+		}
+
+		item.InitExtra()
+
+		items = append(items, item)
+	}
+	return &items
+}
+
+func (p *StreamStatManager) Get(id int64) *StreamStat {
+	if p.Conn == nil && p.Tx == nil {
+		return nil
+	}
+
+	query := p.GetQeury() + " and ss_id = ?"
+
+	rows, err := p.Query(query, id)
+
+	if err != nil {
+		log.Printf("query error : %v, %v\n", err, query)
+		return nil
+	}
+
+	defer rows.Close()
+
+	return p.ReadRow(rows)
+}
+
+func (p *StreamStatManager) Count(args []interface{}) int {
+	if p.Conn == nil && p.Tx == nil {
+		return 0
+	}
+
+	var params []interface{}
+	query := p.GetQeurySelect() + " where 1=1 "
+
+	for _, arg := range args {
+		switch v := arg.(type) {
+		case Where:
+			item := v
+
+			if item.Compare == "in" {
+				query += " and ss_id in (" + strings.Trim(strings.Replace(fmt.Sprint(item.Value), " ", ", ", -1), "[]") + ")"
+			} else if item.Compare == "between" {
+				query += " and ss_" + item.Column + " between ? and ?"
+
+				s := item.Value.([2]string)
+				params = append(params, s[0])
+				params = append(params, s[1])
+			} else {
+				query += " and ss_" + item.Column + " " + item.Compare + " ?"
+				if item.Compare == "like" {
+					params = append(params, "%"+item.Value.(string)+"%")
+				} else {
+					params = append(params, item.Value)
+				}
+			}
+		}
+	}
+
+	rows, err := p.Query(query, params...)
+
+	if err != nil {
+		log.Printf("query error : %v, %v\n", err, query)
+		return 0
+	}
+
+	defer rows.Close()
+
+	if !rows.Next() {
+		return 0
+	}
+
+	cnt := 0
+	err = rows.Scan(&cnt)
+
+	if err != nil {
+		return 0
+	} else {
+		return cnt
+	}
+}
+
+func (p *StreamStatManager) Find(args []interface{}) *[]StreamStat {
+	if p.Conn == nil && p.Tx == nil {
+		var items []StreamStat
+		return &items
+	}
+
+	var params []interface{}
+	query := p.GetQeury()
+
+	page := 0
+	pagesize := 0
+	orderby := ""
+
+	for _, arg := range args {
+		switch v := arg.(type) {
+		case PagingType:
+			item := v
+			page = item.Page
+			pagesize = item.Pagesize
+			break
+		case OrderingType:
+			item := v
+			orderby = item.Order
+			break
+		case LimitType:
+			item := v
+			page = 1
+			pagesize = item.Limit
+			break
+		case OptionType:
+			item := v
+			if item.Limit > 0 {
+				page = 1
+				pagesize = item.Limit
+			} else {
+				page = item.Page
+				pagesize = item.Pagesize
+			}
+			orderby = item.Order
+			break
+		case Where:
+			item := v
+
+			if item.Compare == "in" {
+				query += " and ss_id in (" + strings.Trim(strings.Replace(fmt.Sprint(item.Value), " ", ", ", -1), "[]") + ")"
+			} else if item.Compare == "between" {
+				query += " and ss_" + item.Column + " between ? and ?"
+
+				s := item.Value.([2]string)
+				params = append(params, s[0])
+				params = append(params, s[1])
+			} else {
+				query += " and ss_" + item.Column + " " + item.Compare + " ?"
+				if item.Compare == "like" {
+					params = append(params, "%"+item.Value.(string)+"%")
+				} else {
+					params = append(params, item.Value)
+				}
+			}
+		}
+	}
+
+	startpage := (page - 1) * pagesize
+
+	if page > 0 && pagesize > 0 {
+		if orderby == "" {
+			orderby = "ss_id"
+		} else {
+			orderby = "ss_" + orderby
+		}
+		query += " order by " + orderby
+		if config.Database == "mysql" {
+			query += " limit ? offset ?"
+			params = append(params, pagesize)
+			params = append(params, startpage)
+		} else if config.Database == "mssql" || config.Database == "sqlserver" {
+			query += "OFFSET ? ROWS FITCH NEXT ? ROWS ONLY"
+			params = append(params, startpage)
+			params = append(params, pagesize)
+		}
+	} else {
+		if orderby == "" {
+			orderby = "ss_id"
+		} else {
+			orderby = "ss_" + orderby
+		}
+		query += " order by " + orderby
+	}
+
+	rows, err := p.Query(query, params...)
+
+	if err != nil {
+		log.Printf("query error : %v, %v\n", err, query)
+		var items []StreamStat
+		return &items
+	}
+
+	defer rows.Close()
+
+	return p.ReadRows(rows)
+}
+
+func (p *StreamStatManager) GetByBroadcast(broadcast int64, args ...interface{}) *[]StreamStat {
+	if broadcast != 0 {
+		args = append(args, Where{Column: "broadcast", Value: broadcast, Compare: "="})
+	}
+
+	return p.Find(args)
+}
+
+const (
+	StreamStatSourceRTCP   = "rtcp"
+	StreamStatSourceClient = "client"
+)
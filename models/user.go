@@ -15,14 +15,22 @@ import (
 
 type User struct {
 	Id     int64  `json:"id"`
-	Passwd string `json:"passwd"`
+	Passwd string `json:"passwd,omitempty"` // 입력 전용 (조회 시에는 읽지 않음)
 	Name   string `json:"name"`
 	Email  string `json:"email"`
+	Type   string `json:"type"` // 가입 경로 (빈 값은 이메일 가입, 소셜 로그인은 google/kakao/naver)
 	Date   string `json:"date"`
 
 	Extra map[string]interface{} `json:"extra"`
 }
 
+// 소셜 로그인 가입 경로
+const (
+	UserTypeGoogle = "google"
+	UserTypeKakao  = "kakao"
+	UserTypeNaver  = "naver"
+)
+
 type UserManager struct {
 	Conn   *sql.DB
 	Tx     *sql.Tx
@@ -82,7 +90,7 @@ func (p *UserManager) Query(query string, params ...interface{}) (*sql.Rows, err
 func (p *UserManager) GetQeury() string {
 	ret := ""
 
-	str := "select u_id, u_passwd, u_name, u_email, u_date from user_tb "
+	str := "select u_id, u_name, u_email, u_type, u_date from user_tb "
 
 	if p.Index == "" {
 		ret = str
@@ -130,15 +138,20 @@ func (p *UserManager) Insert(item *User) error {
 		item.Date = fmt.Sprintf("%04d-%02d-%02d %02d:%02d:%02d", t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second())
 	}
 
+	passwd, err := HashPassword(item.Passwd)
+	if err != nil {
+		return err
+	}
+	item.Passwd = ""
+
 	query := ""
 	var res sql.Result
-	var err error
 	if item.Id > 0 {
-		query = "insert into user_tb (u_id, u_passwd, u_name, u_email, u_date) values (?, ?, ?, ?, ?)"
-		res, err = p.Exec(query, item.Id, item.Passwd, item.Name, item.Email, item.Date)
+		query = "insert into user_tb (u_id, u_passwd, u_name, u_email, u_type, u_date) values (?, ?, ?, ?, ?, ?)"
+		res, err = p.Exec(query, item.Id, passwd, item.Name, item.Email, item.Type, item.Date)
 	} else {
-		query = "insert into user_tb (u_passwd, u_name, u_email, u_date) values (?, ?, ?, ?)"
-		res, err = p.Exec(query, item.Passwd, item.Name, item.Email, item.Date)
+		query = "insert into user_tb (u_passwd, u_name, u_email, u_type, u_date) values (?, ?, ?, ?, ?)"
+		res, err = p.Exec(query, passwd, item.Name, item.Email, item.Type, item.Date)
 	}
 
 	if err == nil {
@@ -167,8 +180,22 @@ func (p *UserManager) Update(item *User) error {
 		return errors.New("Connection Error")
 	}
 
+	// 비밀번호를 보내지 않으면 기존 비밀번호 유지
+	if item.Passwd == "" {
+		query := "update user_tb set u_name = ?, u_email = ?, u_date = ? where u_id = ?"
+		_, err := p.Exec(query, item.Name, item.Email, item.Date, item.Id)
+
+		return err
+	}
+
+	passwd, err := HashPassword(item.Passwd)
+	if err != nil {
+		return err
+	}
+	item.Passwd = ""
+
 	query := "update user_tb set u_passwd = ?, u_name = ?, u_email = ?, u_date = ? where u_id = ?"
-	_, err := p.Exec(query, item.Passwd, item.Name, item.Email, item.Date, item.Id)
+	_, err = p.Exec(query, passwd, item.Name, item.Email, item.Date, item.Id)
 
 	return err
 }
@@ -196,7 +223,7 @@ func (p *UserManager) ReadRow(rows *sql.Rows) *User {
 	var err error
 
 	if rows.Next() {
-		err = rows.Scan(&item.Id, &item.Passwd, &item.Name, &item.Email, &item.Date)
+		err = rows.Scan(&item.Id, &item.Name, &item.Email, &item.Type, &item.Date)
 	} else {
 		return nil
 	}
@@ -214,7 +241,7 @@ func (p *UserManager) ReadRows(rows *sql.Rows) *[]User {
 	for rows.Next() {
 		var item User
 
-		err := rows.Scan(&item.Id, &item.Passwd, &item.Name, &item.Email, &item.Date)
+		err := rows.Scan(&item.Id, &item.Name, &item.Email, &item.Type, &item.Date)
 
 		if err != nil {
 			log.Printf("ReadRows error : %v\n", err)
@@ -417,3 +444,85 @@ func (p *UserManager) GetByEmail(email string, args ...interface{}) *User {
 		return nil
 	}
 }
+
+// 저장된 비밀번호 해시 조회 (로그인 확인 전용, 일반 조회 결과에는 포함하지 않음)
+// 소셜 로그인 가입자에게 일괄 저장되던 공용 비밀번호가 남아 있으면 빈 문자열 (로그인 불가)
+func (p *UserManager) GetPasswd(id int64) string {
+	if p.Conn == nil && p.Tx == nil {
+		return ""
+	}
+
+	query := "select u_passwd, u_type from user_tb where u_id = ?"
+	rows, err := p.Query(query, id)
+	if err != nil {
+		log.Printf("query error : %v, %v\n", err, query)
+		return ""
+	}
+
+	defer rows.Close()
+
+	passwd := ""
+	userType := ""
+	if rows.Next() {
+		rows.Scan(&passwd, &userType)
+	}
+	if IsSocialUserType(userType) && passwd == legacySocialPasswd {
+		return ""
+	}
+	return passwd
+}
+
+// 비밀번호만 변경 (입력값은 항상 해시해서 저장)
+func (p *UserManager) UpdatePasswd(id int64, passwd string) error {
+	if p.Conn == nil && p.Tx == nil {
+		return errors.New("Connection Error")
+	}
+
+	hash, err := HashPassword(passwd)
+	if err != nil {
+		return err
+	}
+
+	query := "update user_tb set u_passwd = ? where u_id = ?"
+	_, err = p.Exec(query, hash, id)
+
+	return err
+}
+
+// 가입 경로가 기록되지 않은 이전 계정에 소셜 로그인 경로 기록 (소셜 로그인 시 호출)
+// 공용 비밀번호가 남아 있으면 함께 비운다.
+func (p *UserManager) UpdateSocialType(id int64, userType string) error {
+	if p.Conn == nil && p.Tx == nil {
+		return errors.New("Connection Error")
+	}
+
+	query := "update user_tb set u_type = ? where u_id = ? and u_type = ''"
+	if _, err := p.Exec(query, userType, id); err != nil {
+		return err
+	}
+
+	query = "update user_tb set u_passwd = '' where u_id = ? and u_passwd = ?"
+	_, err := p.Exec(query, id, legacySocialPasswd)
+
+	return err
+}
+
+// 소셜 로그인 가입자에게 일괄 저장된 공용 비밀번호를 비워 비밀번호 로그인을 막음 (서버 시작 시 실행)
+// 직접 같은 비밀번호를 고른 이메일 가입자는 건드리지 않는다.
+func (p *UserManager) ClearLegacySocialPasswd() (int64, error) {
+	if p.Conn == nil && p.Tx == nil {
+		return 0, errors.New("Connection Error")
+	}
+
+	query := "update user_tb set u_passwd = '' where u_passwd = ? and u_type in (?, ?, ?)"
+	res, err := p.Exec(query, legacySocialPasswd, UserTypeGoogle, UserTypeKakao, UserTypeNaver)
+	if err != nil {
+		return 0, err
+	}
+
+	return res.RowsAffected()
+}
+
+func IsSocialUserType(userType string) bool {
+	return userType == UserTypeGoogle || userType == UserTypeKakao || userType == UserTypeNaver
+}
@@ -1,28 +1,14 @@
 package router
 
 import (
-	"errors"
 	"log"
 	"net/http"
-	"time"
-	"toysgo/config"
+	"toysgo/auth"
 	"toysgo/models"
 
 	"github.com/gofiber/fiber/v2"
-	"github.com/golang-jwt/jwt/v5"
 )
 
-type AuthTokenClaims struct {
-	User models.User `json:"user"`
-	jwt.RegisteredClaims
-}
-
-type RefreshTokenClaims struct {
-	UserId int64  `json:"user_id"`
-	Email  string `json:"email"`
-	jwt.RegisteredClaims
-}
-
 func JwtAuthRequired() fiber.Handler {
 	return func(c *fiber.Ctx) error {
 		var token string
@@ -43,14 +29,6 @@ func JwtAuthRequired() fiber.Handler {
 			return c.Next()
 		}
 
-		if (c.Method() == fiber.MethodPost || c.Method() == fiber.MethodGet) && c.Path() == "/p2p/ws" {
-			return c.Next()
-		}
-
-		if (c.Method() == fiber.MethodPost || c.Method() == fiber.MethodGet) && c.Path() == "/p2p/webrtc" {
-			return c.Next()
-		}
-
 		values := c.Get("Authorization")
 		if values != "" {
 			str := values
@@ -58,20 +36,16 @@ func JwtAuthRequired() fiber.Handler {
 			if len(str) > 7 && str[:7] == "Bearer " {
 				token = str[7:]
 
-				claims := AuthTokenClaims{}
-				key := func(token *jwt.Token) (interface{}, error) {
-					if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-						return nil, errors.New("Unexpected Signing Method")
-					}
-					return []byte(config.SecretCode), nil
-				}
-
-				tok, err := jwt.ParseWithClaims(token, &claims, key)
+				// 로그아웃 등으로 폐기된 세션의 토큰도 여기서 거부됨
+				// 사용자 정보는 토큰의 sub로 조회 (캐시)
+				claims, user, err := auth.Authenticate(token)
 				if err == nil {
-					c.Locals("jwt", tok)
-					c.Locals("user", &(claims.User))
+					c.Locals("jwt", claims)
+					c.Locals("user", user)
+					c.Locals("session", claims.Session)
 					return c.Next()
 				}
+				log.Printf("Jwt rejected: %v\n", err)
 			} else {
 				log.Println("Jwt header is broken")
 			}
@@ -86,8 +60,9 @@ func JwtAuthRequired() fiber.Handler {
 	}
 }
 
-func JwtAuth(email string, passwd string) fiber.Map {
+func JwtAuth(email string, passwd string, info auth.SessionInfo) fiber.Map {
 	conn := models.NewConnection()
+	defer conn.Close()
 
 	manager := models.NewUserManager(conn)
 	user := manager.GetByEmail(email)
@@ -99,61 +74,49 @@ func JwtAuth(email string, passwd string) fiber.Map {
 		}
 	}
 
-	if user.Passwd != passwd {
+	ok, rehash := models.VerifyPassword(manager.GetPasswd(user.Id), passwd)
+	if !ok {
 		return fiber.Map{
 			"code":    "error",
 			"message": "wrong password",
 		}
 	}
 
-	at := AuthTokenClaims{
-		User: *user,
-		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour * 6)),
-			// ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Minute * 5)),
-		},
+	// 평문이나 이전 설정의 해시는 로그인 성공 시 현재 설정으로 교체
+	if rehash {
+		if err := manager.UpdatePasswd(user.Id, passwd); err != nil {
+			log.Printf("Error upgrading password hash: %v\n", err)
+		}
 	}
 
-	// authManager := models.NewAuthManager(conn)
-	// auth := authManager.GetByUser(user.Id)
-
-	// rt := RefreshTokenClaims{
-	// 	UserId: user.Id,
-	// 	Email:  user.Email,
-	// 	RegisteredClaims: jwt.RegisteredClaims{
-	// 		ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour * 24 * 7)),
-	// 		// ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Minute * 60)),
-	// 	},
-	// }
-
-	atoken := jwt.NewWithClaims(jwt.SigningMethodHS256, &at)
-	signedAuthToken, _ := atoken.SignedString([]byte(config.SecretCode))
-
-	// rtoken := jwt.NewWithClaims(jwt.SigningMethodHS256, &rt)
-	// signedRefreshToken, _ := rtoken.SignedString([]byte(config.SecretCode))
-
-	// refreshTokenItem := models.Auth{
-	// 	User:  user.Id,
-	// 	Token: signedRefreshToken,
-	// }
-
-	// if auth == nil {
-	// 	authManager.Insert(&refreshTokenItem)
-	// } else {
-	// 	refreshTokenItem.Id = auth.Id
-	// 	authManager.Update(&refreshTokenItem)
-	// }
+	session, signedRefreshToken, err := auth.IssueRefreshToken(conn, user.Id, info)
+	if err != nil {
+		log.Printf("Error issuing refresh token: %v\n", err)
+		return fiber.Map{
+			"code":    "error",
+			"message": "Failed to generate refresh token",
+		}
+	}
 
 	user.Passwd = ""
+	signedAuthToken, err := auth.GenerateToken(user, session.Family)
+	if err != nil {
+		return fiber.Map{
+			"code":    "error",
+			"message": "Failed to generate JWT",
+		}
+	}
+
 	return fiber.Map{
-		"code":        "ok",
-		"accessToken": signedAuthToken,
-		"user":        user,
-		// "refreshToken": signedRefreshToken,
+		"code":         "ok",
+		"accessToken":  signedAuthToken,
+		"refreshToken": signedRefreshToken,
+		"user":         user,
 	}
 }
 
-func JwtToken(refreshToken string) fiber.Map {
+// 리프레시 토큰으로 새 액세스 토큰 발급 (사용한 리프레시 토큰은 새 토큰으로 교체)
+func JwtToken(refreshToken string, info auth.SessionInfo) fiber.Map {
 	values := refreshToken
 	if values != "" {
 		str := values
@@ -161,59 +124,32 @@ func JwtToken(refreshToken string) fiber.Map {
 		if len(str) > 7 && str[:7] == "Bearer " {
 			refreshToken = str[7:]
 
-			claims := RefreshTokenClaims{}
-			key := func(token *jwt.Token) (interface{}, error) {
-				if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-					return nil, errors.New("Unexpected Signing Method")
-				}
-				return []byte(config.SecretCode), nil
-			}
-
-			_, err := jwt.ParseWithClaims(refreshToken, &claims, key)
-			if err == nil {
-				conn := models.NewConnection()
-
-				manager := models.NewUserManager(conn)
-				user := manager.GetByEmail((claims.Email))
-
-				authManager := models.NewAuthManager((conn))
-				auth := authManager.GetByUser((claims.UserId))
-
-				if auth == nil {
-					return fiber.Map{
-						"code":    "error",
-						"message": "token not found",
-					}
-				}
+			conn := models.NewConnection()
+			defer conn.Close()
 
-				if auth.Token != refreshToken {
-					return nil
-				}
-
-				if user == nil {
-					return fiber.Map{
-						"code":    "error",
-						"message": "user not found",
-					}
-				}
-
-				at := AuthTokenClaims{
-					User: *user,
-					RegisteredClaims: jwt.RegisteredClaims{
-						// ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour * 6)),
-						ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Minute * 5)),
-					},
+			user, session, signedRefreshToken, err := auth.RotateRefreshToken(conn, refreshToken, info)
+			if err != nil {
+				log.Printf("Refresh token rejected: %v\n", err)
+				return fiber.Map{
+					"code":    "error",
+					"message": err.Error(),
 				}
+			}
 
-				atoken := jwt.NewWithClaims(jwt.SigningMethodHS256, &at)
-				signedAuthToken, _ := atoken.SignedString([]byte(config.SecretCode))
-
-				user.Passwd = ""
+			user.Passwd = ""
+			signedAuthToken, err := auth.GenerateToken(user, session.Family)
+			if err != nil {
 				return fiber.Map{
-					"code":        "ok",
-					"accessToken": signedAuthToken,
+					"code":    "error",
+					"message": "Failed to generate JWT",
 				}
+			}
 
+			return fiber.Map{
+				"code":         "ok",
+				"accessToken":  signedAuthToken,
+				"refreshToken": signedRefreshToken,
+				"user":         user,
 			}
 		} else {
 			log.Println("Jwt header is broken")
@@ -236,21 +172,13 @@ func JwtMe(token string) fiber.Map {
 		if len(str) > 7 && str[:7] == "Bearer " {
 			token = str[7:]
 
-			claims := AuthTokenClaims{}
-			key := func(token *jwt.Token) (interface{}, error) {
-				if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-					return nil, errors.New("Unexpected Signing Method")
-				}
-				return []byte(config.SecretCode), nil
-			}
-
-			_, err := jwt.ParseWithClaims(token, &claims, key)
+			_, user, err := auth.Authenticate(token)
 			if err == nil {
 				return fiber.Map{
 					"code":     "ok",
-					"id":       claims.User.Id,
-					"name":     claims.User.Name,
-					"email":    claims.User.Email,
+					"id":       user.Id,
+					"name":     user.Name,
+					"email":    user.Email,
 					"imageUrl": "/logo/codefactory_logo.png",
 				}
 			}
@@ -266,33 +194,3 @@ func JwtMe(token string) fiber.Map {
 		"message": "not auth",
 	}
 }
-
-// GenerateAuthResponse JWT 토큰 생성 및 응답 설정
-func GenerateAuthResponse(c *fiber.Ctx, user *models.User, message string) error {
-	// JWT 클레임 생성
-	claims := AuthTokenClaims{
-		User: *user,
-		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour * 6)), // 유효기간 6시간
-		},
-	}
-
-	// JWT 토큰 생성
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, &claims)
-	signedToken, err := token.SignedString([]byte(config.SecretCode))
-	if err != nil {
-		log.Printf("Error signing JWT: %v\n", err)
-		return c.JSON(fiber.Map{
-			"code":    "error",
-			"message": "Failed to generate JWT",
-		})
-	}
-
-	// 성공 응답 반환
-	return c.JSON(fiber.Map{
-		"code":        "success",
-		"message":     message,
-		"accessToken": signedToken,
-		"user":        user,
-	})
-}
@@ -1,8 +1,13 @@
 package router
 
 import (
+	"encoding/json"
 	"fmt"
 	"strconv"
+	"strings"
+	"time"
+	"toysgo/auth"
+	"toysgo/config"
 	"toysgo/controllers/p2p"
 	"toysgo/controllers/rest"
 	"toysgo/models"
@@ -16,63 +21,207 @@ func SetRouter(app *fiber.App) {
 	app.Get("/api/jwt", func(ctx *fiber.Ctx) error {
 		email := ctx.Query("email")
 		passwd := ctx.Query("passwd")
-		return ctx.JSON(JwtAuth(email, passwd))
+		return ctx.JSON(JwtAuth(email, passwd, auth.NewSessionInfo(ctx)))
 	})
 	app.Get("/api/jwt/token", func(ctx *fiber.Ctx) error {
 		token := ctx.Get("Authorization")
-		return ctx.JSON(JwtToken(token))
+		return ctx.JSON(JwtToken(token, auth.NewSessionInfo(ctx)))
 	})
-	app.Get("/p2p/webrtc", websocket.New(p2p.WebSocketHandler))
+	// 다른 서비스가 액세스 토큰을 직접 검증할 수 있도록 공개 키 제공
+	app.Get("/.well-known/jwks.json", func(ctx *fiber.Ctx) error {
+		ctx.Set(fiber.HeaderCacheControl, "public, max-age=300")
+		return ctx.JSON(auth.JWKS())
+	})
+	// 시그널링 소켓은 헤더를 보낼 수 없으므로 token 쿼리로 인증 (업그레이드 전에 거부)
+	app.Get("/p2p/webrtc", func(c *fiber.Ctx) error {
+		token := c.Query("token")
+		if token == "" {
+			token = c.Get("Authorization")
+		}
+		_, user, err := auth.Authenticate(token)
+		if err != nil {
+			return c.Status(401).JSON(fiber.Map{
+				"code":    "error",
+				"message": "not auth",
+			})
+		}
+		c.Locals("user", user)
+		return c.Next()
+	}, websocket.New(p2p.WebSocketHandler))
 
 	webSocketService := services.NewWebSocketService()
 
 	
-	app.Get("/p2p/ws", websocket.New(func(conn *websocket.Conn) {
-	// 쿼리 파라미터 추출 및 로깅
+	// 클라이언트 주소는 업그레이드 전에 Locals로 넘김
+	app.Get("/p2p/ws", func(c *fiber.Ctx) error {
+		c.Locals("ip", c.IP())
+		return c.Next()
+	}, websocket.New(func(conn *websocket.Conn) {
 	role := conn.Query("role")
-	userID := conn.Query("user_id")
-	userName := conn.Query("user_name")
 	broadcasterID := conn.Query("broadcaster_id")
 
 	fmt.Printf("🔗 새 WebSocket 연결 요청\n")
 	fmt.Printf("  - Role: %s\n", role)
-	fmt.Printf("  - User ID: %s\n", userID)
-	fmt.Printf("  - User Name: %s\n", userName)
 	fmt.Printf("  - Broadcaster ID: %s\n", broadcasterID)
 
-	// 필수 파라미터 검증
-	if userID == "" {
-		fmt.Printf("❌ 연결 거부: user_id 누락\n")
-		conn.WriteMessage(websocket.TextMessage, []byte(`{"type":"error","data":"user_id가 필요합니다"}`))
+	if role != "broadcaster" && role != "viewer" && role != "viewer_list" {
+		fmt.Printf("❌ 알 수 없는 역할: %s\n", role)
+		conn.WriteMessage(websocket.TextMessage, []byte(`{"type":"error","data":"유효하지 않은 역할입니다"}`))
 		conn.Close()
 		return
 	}
 
+	// 토큰 검증 후 클레임에서 사용자 정보 추출
+	connection, err := webSocketService.Authenticate(conn, role)
+	if err != nil {
+		fmt.Printf("❌ 연결 거부 (%s): %v\n", role, err)
+		data, _ := json.Marshal(fiber.Map{"type": "error", "data": err.Error()})
+		conn.WriteMessage(websocket.TextMessage, data)
+		conn.Close()
+		return
+	}
+
+	defer connection.Close()
+
+	fmt.Printf("  - User ID: %s\n", connection.UserID)
+	fmt.Printf("  - User Name: %s\n", connection.Name)
+
 	// 역할별 처리
 	switch role {
 	case "broadcaster":
-		fmt.Printf("✅ 방송자 핸들러로 연결: %s (%s)\n", userName, userID)
-		webSocketService.HandleBroadcaster(conn)
+		fmt.Printf("✅ 방송자 핸들러로 연결: %s (%s)\n", connection.Name, connection.UserID)
+		webSocketService.HandleBroadcaster(connection)
 
 	case "viewer":
-		fmt.Printf("✅ 시청자 핸들러로 연결: %s (%s)\n", userName, userID)
-		webSocketService.HandleViewer(conn)
+		fmt.Printf("✅ 시청자 핸들러로 연결: %s (%s)\n", connection.Name, connection.UserID)
+		webSocketService.HandleViewer(connection)
 
 	case "viewer_list":
-		fmt.Printf("✅ 목록 구독자 핸들러로 연결: %s\n", userID)
-		webSocketService.HandleViewerList(conn)
-
-	default:
-		fmt.Printf("❌ 알 수 없는 역할: %s\n", role)
-		conn.WriteMessage(websocket.TextMessage, []byte(`{"type":"error","data":"유효하지 않은 역할입니다"}`))
-		conn.Close()
+		fmt.Printf("✅ 목록 구독자 핸들러로 연결: %s\n", connection.UserID)
+		webSocketService.HandleViewerList(connection)
 	}
 }))
 
+	// HLS 출력 (SFU 방송의 재생 목록과 fMP4 세그먼트)
+	app.Get("/hls/:broadcaster_id/:file", func(c *fiber.Ctx) error {
+		file := c.Params("file")
+		data, ok := webSocketService.GetHLSFile(c.Params("broadcaster_id"), file)
+		if !ok {
+			return c.Status(404).JSON(fiber.Map{
+				"success": false,
+				"error":   "HLS 출력을 찾을 수 없습니다",
+			})
+		}
+
+		switch {
+		case strings.HasSuffix(file, ".m3u8"):
+			c.Set(fiber.HeaderContentType, "application/vnd.apple.mpegurl")
+			c.Set(fiber.HeaderCacheControl, "no-cache")
+		case strings.HasSuffix(file, ".m4s"):
+			c.Set(fiber.HeaderContentType, "video/iso.segment")
+		default:
+			c.Set(fiber.HeaderContentType, "video/mp4")
+		}
+		return c.Send(data)
+	})
+
+	// WHIP 송출 / WHEP 시청 (Bearer 토큰 인증, SDP는 application/sdp)
+	for _, kind := range []string{services.WHIPKindIngest, services.WHIPKindPlayback} {
+		kind := kind
+
+		app.Post("/"+kind+"/:stream", func(c *fiber.Ctx) error {
+			if !strings.HasPrefix(c.Get(fiber.HeaderContentType), "application/sdp") {
+				return c.SendStatus(415)
+			}
+
+			stream := c.Params("stream")
+			authorization := c.Get(fiber.HeaderAuthorization)
+			var resourceID, answer string
+			var err error
+			if kind == services.WHIPKindIngest {
+				data := map[string]interface{}{}
+				for _, key := range []string{"title", "description", "category", "language", "visibility"} {
+					if value := c.Query(key); value != "" {
+						data[key] = value
+					}
+				}
+				resourceID, answer, err = webSocketService.PublishWHIP(authorization, stream, string(c.Body()), data)
+			} else {
+				resourceID, answer, err = webSocketService.PlayWHEP(authorization, stream, string(c.Body()), c.Query("invite"))
+			}
+			if err != nil {
+				return whipError(c, err)
+			}
+
+			c.Set(fiber.HeaderContentType, "application/sdp")
+			c.Set(fiber.HeaderLocation, "/"+kind+"/"+stream+"/"+resourceID)
+			return c.Status(201).SendString(answer)
+		})
+
+		app.Patch("/"+kind+"/:stream/:resource", func(c *fiber.Ctx) error {
+			if !strings.HasPrefix(c.Get(fiber.HeaderContentType), "application/trickle-ice-sdpfrag") {
+				return c.SendStatus(415)
+			}
+
+			err := webSocketService.PatchWHIPResource(c.Get(fiber.HeaderAuthorization), kind, c.Params("stream"), c.Params("resource"), string(c.Body()))
+			if err != nil {
+				return whipError(c, err)
+			}
+			return c.SendStatus(204)
+		})
+
+		app.Delete("/"+kind+"/:stream/:resource", func(c *fiber.Ctx) error {
+			err := webSocketService.DeleteWHIPResource(c.Get(fiber.HeaderAuthorization), kind, c.Params("stream"), c.Params("resource"))
+			if err != nil {
+				return whipError(c, err)
+			}
+			return c.SendStatus(200)
+		})
+	}
+
 	apiGroup := app.Group("/api")
+	// ICE 서버 목록 (TURN 자격 증명은 사용자별로 발급)
+	// 로그인하지 않은 요청은 익명 시청이 허용된 경우에만 요청마다 다른 익명 ID로 발급한다.
+	apiGroup.Get("/ice-servers", func(c *fiber.Ctx) error {
+		var userID string
+		if token := c.Get("Authorization"); token != "" {
+			claims, err := auth.ParseToken(token)
+			if err != nil {
+				return c.Status(401).JSON(fiber.Map{
+					"code":    "error",
+					"message": "not auth",
+				})
+			}
+			userID = fmt.Sprintf("%d", claims.UserID())
+		} else if config.AllowAnonymousViewer {
+			userID = fmt.Sprintf("anonymous_%d", time.Now().UnixNano())
+		} else {
+			return c.Status(401).JSON(fiber.Map{
+				"code":    "error",
+				"message": "not auth",
+			})
+		}
+
+		servers, expires := services.ICEServers(userID)
+		data := fiber.Map{
+			"ice_servers": servers,
+		}
+		if !expires.IsZero() {
+			data["expires_at"] = expires.Format(time.RFC3339)
+		}
+		return c.JSON(fiber.Map{
+			"success": true,
+			"data":    data,
+		})
+	})
+
 	// 1. 현재 방송 목록 조회
 	apiGroup.Get("/broadcasts", func(c *fiber.Ctx) error {
-		broadcasts := webSocketService.GetActiveBroadcasts()
+		broadcasts := webSocketService.GetActiveBroadcasts(services.BroadcastFilter{
+			Category: c.Query("category"),
+			Tag:      c.Query("tag"),
+			Language: c.Query("language"),
+		})
 		return c.JSON(fiber.Map{
 			"success": true,
 			"count":   len(broadcasts),
@@ -81,6 +230,50 @@ func SetRouter(app *fiber.App) {
 	})
 
 
+	// 방송 기록 조회 (/broadcasts/:broadcaster_id 보다 먼저 등록해야 하므로 경로마다 로그인 확인)
+	apiGroup.Get("/broadcasts/history", JwtAuthRequired(), func(ctx *fiber.Ctx) error {
+		page_, _ := strconv.Atoi(ctx.Query("page"))
+		pagesize_, _ := strconv.Atoi(ctx.Query("pagesize"))
+		var controller rest.BroadcastController
+		controller.Init(ctx)
+		controller.Index(page_, pagesize_)
+		controller.Close()
+		return ctx.JSON(controller.Result)
+	})
+
+	apiGroup.Get("/broadcasts/history/:id", JwtAuthRequired(), func(ctx *fiber.Ctx) error {
+		id_, _ := strconv.ParseInt(ctx.Params("id"), 10, 64)
+		var controller rest.BroadcastController
+		controller.Init(ctx)
+		controller.Read(id_)
+		controller.Close()
+		return ctx.JSON(controller.Result)
+	})
+
+	// 시청 기록과 품질 기록은 방송한 본인만 조회
+	apiGroup.Get("/broadcasts/history/:id/viewers", JwtAuthRequired(), func(ctx *fiber.Ctx) error {
+		id_, _ := strconv.ParseInt(ctx.Params("id"), 10, 64)
+		page_, _ := strconv.Atoi(ctx.Query("page"))
+		pagesize_, _ := strconv.Atoi(ctx.Query("pagesize"))
+		var controller rest.BroadcastViewerController
+		controller.Init(ctx)
+		controller.Broadcast(id_, page_, pagesize_)
+		controller.Close()
+		return ctx.Status(controller.Code).JSON(controller.Result)
+	})
+
+	// 방송 연결 품질 기록 (?peer=, ?role=, ?source=rtcp|client, ?kind=audio|video, ?startdate=, ?enddate=)
+	apiGroup.Get("/broadcasts/history/:id/stats", JwtAuthRequired(), func(ctx *fiber.Ctx) error {
+		id_, _ := strconv.ParseInt(ctx.Params("id"), 10, 64)
+		page_, _ := strconv.Atoi(ctx.Query("page"))
+		pagesize_, _ := strconv.Atoi(ctx.Query("pagesize"))
+		var controller rest.StreamStatController
+		controller.Init(ctx)
+		controller.Broadcast(id_, page_, pagesize_)
+		controller.Close()
+		return ctx.Status(controller.Code).JSON(controller.Result)
+	})
+
 	// 2. 서버 상태 조회 (전체 통계)
 	apiGroup.Get("/status", func(c *fiber.Ctx) error {
 		status := webSocketService.GetServerStatus()
@@ -108,6 +301,26 @@ func SetRouter(app *fiber.App) {
 		})
 	})
 
+	// 4. 방송 채팅 기록
+	apiGroup.Get("/broadcasts/:broadcaster_id/chat", func(c *fiber.Ctx) error {
+		broadcasterID := c.Params("broadcaster_id")
+		after, _ := strconv.ParseInt(c.Query("after"), 10, 64)
+		messages, exists := webSocketService.GetChatHistory(broadcasterID, after)
+
+		if !exists {
+			return c.Status(404).JSON(fiber.Map{
+				"success": false,
+				"error":   "방송을 찾을 수 없습니다",
+			})
+		}
+
+		return c.JSON(fiber.Map{
+			"success": true,
+			"count":   len(messages),
+			"data":    messages,
+		})
+	})
+
 	apiGroup.Get("/board/:id", func(ctx *fiber.Ctx) error {
 		id_, _ := strconv.ParseInt(ctx.Params("id"), 10, 64)
 		var controller rest.BoardController
@@ -203,6 +416,170 @@ func SetRouter(app *fiber.App) {
 			return ctx.JSON(controller.Result)
 		})
 
+		apiGroup.Get("/user/:id/broadcasts", func(ctx *fiber.Ctx) error {
+			id_, _ := strconv.ParseInt(ctx.Params("id"), 10, 64)
+			page_, _ := strconv.Atoi(ctx.Query("page"))
+			pagesize_, _ := strconv.Atoi(ctx.Query("pagesize"))
+			var controller rest.BroadcastController
+			controller.Init(ctx)
+			controller.User(id_, page_, pagesize_)
+			controller.Close()
+			return ctx.JSON(controller.Result)
+		})
+
+		// 비공개 방송 초대 토큰 발급 (방송자 본인 또는 매니저)
+		apiGroup.Post("/broadcasts/:broadcaster_id/invites", func(ctx *fiber.Ctx) error {
+			user, _ := ctx.Locals("user").(*models.User)
+			if user == nil {
+				return ctx.Status(401).JSON(fiber.Map{
+					"success": false,
+					"error":   "not auth",
+				})
+			}
+
+			request := struct {
+				ExpiresIn int `json:"expires_in"`
+			}{}
+			ctx.BodyParser(&request)
+
+			broadcasterID := ctx.Params("broadcaster_id")
+			token, expires, err := webSocketService.CreateInviteToken(broadcasterID, fmt.Sprintf("%d", user.Id), time.Duration(request.ExpiresIn)*time.Second)
+			if err != nil {
+				status := 500
+				if err == services.ErrInviteForbidden {
+					status = 403
+				}
+				return ctx.Status(status).JSON(fiber.Map{
+					"success": false,
+					"error":   err.Error(),
+				})
+			}
+
+			return ctx.JSON(fiber.Map{
+				"success": true,
+				"data": fiber.Map{
+					"broadcaster_id": broadcasterID,
+					"token":          token,
+					"expires_at":     expires.Format(time.RFC3339),
+				},
+			})
+		})
+
+		// 서버 녹화 시작/종료 (방송자 본인, SFU 모드 방송만)
+		apiGroup.Post("/broadcasts/:broadcaster_id/recording", func(ctx *fiber.Ctx) error {
+			return recordingHandler(ctx, webSocketService, true)
+		})
+
+		apiGroup.Delete("/broadcasts/:broadcaster_id/recording", func(ctx *fiber.Ctx) error {
+			return recordingHandler(ctx, webSocketService, false)
+		})
+
+		// 방송 이벤트 발행 (방송자 본인 또는 매니저, 오버레이/투표/타임드 메타데이터)
+		apiGroup.Post("/broadcasts/:broadcaster_id/events", func(ctx *fiber.Ctx) error {
+			user, _ := ctx.Locals("user").(*models.User)
+			if user == nil {
+				return ctx.Status(401).JSON(fiber.Map{
+					"success": false,
+					"error":   "not auth",
+				})
+			}
+
+			request := struct {
+				Topic string      `json:"topic"`
+				Data  interface{} `json:"data"`
+			}{}
+			if err := ctx.BodyParser(&request); err != nil {
+				return ctx.Status(400).JSON(fiber.Map{
+					"success": false,
+					"error":   err.Error(),
+				})
+			}
+
+			event, delivery, err := webSocketService.PublishStreamEvent(ctx.Params("broadcaster_id"), fmt.Sprintf("%d", user.Id), request.Topic, request.Data)
+			if err != nil {
+				status := 500
+				switch err {
+				case services.ErrStreamEventNotFound:
+					status = 404
+				case services.ErrStreamEventForbidden:
+					status = 403
+				case services.ErrStreamEventTopic:
+					status = 400
+				case services.ErrStreamEventTooLarge:
+					status = 413
+				}
+				return ctx.Status(status).JSON(fiber.Map{
+					"success": false,
+					"error":   err.Error(),
+				})
+			}
+
+			return ctx.JSON(fiber.Map{
+				"success": true,
+				"data": fiber.Map{
+					"event":    event,
+					"delivery": delivery,
+				},
+			})
+		})
+
+		apiGroup.Get("/recordings", func(ctx *fiber.Ctx) error {
+			page_, _ := strconv.Atoi(ctx.Query("page"))
+			pagesize_, _ := strconv.Atoi(ctx.Query("pagesize"))
+			var controller rest.RecordingController
+			controller.Init(ctx)
+			controller.Index(page_, pagesize_)
+			controller.Close()
+			return ctx.Status(controller.Code).JSON(controller.Result)
+		})
+
+		apiGroup.Get("/recordings/:id", func(ctx *fiber.Ctx) error {
+			id_, _ := strconv.ParseInt(ctx.Params("id"), 10, 64)
+			var controller rest.RecordingController
+			controller.Init(ctx)
+			controller.Read(id_)
+			controller.Close()
+			return ctx.Status(controller.Code).JSON(controller.Result)
+		})
+
+		// 녹화 파일 다운로드 (?track=audio 이면 음성, 아니면 영상)
+		apiGroup.Get("/recordings/:id/download", func(ctx *fiber.Ctx) error {
+			id_, _ := strconv.ParseInt(ctx.Params("id"), 10, 64)
+			var controller rest.RecordingController
+			controller.Init(ctx)
+			path := controller.File(id_, ctx.Query("track"))
+			controller.Close()
+			if path == "" {
+				return ctx.Status(controller.Code).JSON(controller.Result)
+			}
+			return ctx.Download(path)
+		})
+
+		apiGroup.Post("/logout", func(ctx *fiber.Ctx) error {
+			var controller rest.SessionController
+			controller.Init(ctx)
+			controller.Logout()
+			controller.Close()
+			return ctx.Status(controller.Code).JSON(controller.Result)
+		})
+
+		// 로그인 세션 목록과 원격 로그아웃
+		apiGroup.Get("/sessions", func(ctx *fiber.Ctx) error {
+			var controller rest.SessionController
+			controller.Init(ctx)
+			controller.Index()
+			controller.Close()
+			return ctx.Status(controller.Code).JSON(controller.Result)
+		})
+
+		apiGroup.Delete("/sessions/:id", func(ctx *fiber.Ctx) error {
+			var controller rest.SessionController
+			controller.Init(ctx)
+			controller.Delete(ctx.Params("id"))
+			controller.Close()
+			return ctx.Status(controller.Code).JSON(controller.Result)
+		})
+
 		apiGroup.Get("/me", func(ctx *fiber.Ctx) error {
 			token := ctx.Get("Authorization")
 			return ctx.JSON(JwtMe(token))
@@ -261,3 +638,79 @@ func SetRouter(app *fiber.App) {
 		})
 	}
 }
+
+// 녹화 시작/종료 요청 처리
+func recordingHandler(ctx *fiber.Ctx, webSocketService *services.WebSocketService, start bool) error {
+	user, _ := ctx.Locals("user").(*models.User)
+	if user == nil {
+		return ctx.Status(401).JSON(fiber.Map{
+			"success": false,
+			"error":   "not auth",
+		})
+	}
+
+	broadcasterID := ctx.Params("broadcaster_id")
+	if broadcasterID != fmt.Sprintf("%d", user.Id) {
+		return ctx.Status(403).JSON(fiber.Map{
+			"success": false,
+			"error":   "본인 방송만 녹화할 수 있습니다",
+		})
+	}
+
+	var recording *models.Recording
+	var err error
+	if start {
+		recording, err = webSocketService.StartRecording(broadcasterID)
+	} else {
+		recording, err = webSocketService.StopRecording(broadcasterID)
+	}
+	if err != nil {
+		status := 500
+		switch err {
+		case services.ErrRecordingUnavailable, services.ErrRecordingNotFound:
+			status = 404
+		case services.ErrRecordingActive:
+			status = 409
+		}
+		return ctx.Status(status).JSON(fiber.Map{
+			"success": false,
+			"error":   err.Error(),
+		})
+	}
+
+	// 녹화 기록은 비동기로 저장되므로 ID는 돌려주지 않음 (저장된 기록은 /recordings에서 조회)
+	return ctx.JSON(fiber.Map{
+		"success": true,
+		"data": fiber.Map{
+			"broadcaster_id": broadcasterID,
+			"title":          recording.Title,
+			"status":         recording.Status,
+			"audiofile":      recording.Audiofile,
+			"videofile":      recording.Videofile,
+			"videocodec":     recording.Videocodec,
+			"size":           recording.Size,
+			"duration":       recording.Duration,
+			"startdate":      recording.Startdate,
+			"enddate":        recording.Enddate,
+		},
+	})
+}
+
+// WHIP/WHEP 오류 응답 (본문은 오류 메시지)
+func whipError(ctx *fiber.Ctx, err error) error {
+	status := 400
+	switch err {
+	case services.ErrAuthRequired, services.ErrAuthInvalid:
+		ctx.Set(fiber.HeaderWWWAuthenticate, "Bearer")
+		status = 401
+	case services.ErrWHIPForbidden, services.ErrWHEPForbidden:
+		status = 403
+	case services.ErrWHIPNotFound, services.ErrWHEPUnavailable:
+		status = 404
+	case services.ErrWHIPConflict:
+		status = 409
+	case services.ErrWHEPFull:
+		status = 503
+	}
+	return ctx.Status(status).SendString(err.Error())
+}
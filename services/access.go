@@ -0,0 +1,364 @@
+package services
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+	"toysgo/config"
+	"toysgo/models"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// 방송 공개 범위
+const (
+	BroadcastVisibilityPublic   = models.BroadcastVisibilityPublic
+	BroadcastVisibilityUnlisted = models.BroadcastVisibilityUnlisted
+	BroadcastVisibilityPrivate  = models.BroadcastVisibilityPrivate
+)
+
+const (
+	maxPasswordLength   = 72 // bcrypt 입력 한도
+	maxAccessAttempts   = 5
+	accessLockout       = 10 * time.Minute
+	inviteTokenAudience = "broadcast_invite"
+)
+
+var (
+	ErrInviteForbidden = errors.New("초대 링크를 만들 권한이 없습니다")
+	ErrInviteInvalid   = errors.New("초대 토큰이 유효하지 않습니다")
+)
+
+// 부분 수정용 접근 설정 (전달된 필드만 변경)
+type broadcastAccessUpdate struct {
+	Visibility *string `json:"visibility"`
+	Password   *string `json:"password"`
+}
+
+// 방송별 접근 설정 (서비스 뮤텍스로 보호됨)
+type broadcastAccess struct {
+	// 로그인 비밀번호와 같은 방식으로 해시 (models.HashPassword)
+	passwordHash string
+
+	// 접근이 허용된 시청자 (viewer_id)
+	granted map[string]bool
+	// 비밀번호 실패 기록 (로그인 사용자는 사용자 ID, 익명 시청자는 클라이언트 주소 기준)
+	failures map[string]*accessFailure
+}
+
+// 비밀번호 실패 횟수 (마지막 실패 후 accessLockout이 지나면 초기화)
+type accessFailure struct {
+	count int
+	until time.Time
+}
+
+func newBroadcastAccess() *broadcastAccess {
+	return &broadcastAccess{
+		granted:  make(map[string]bool),
+		failures: make(map[string]*accessFailure),
+	}
+}
+
+// 비밀번호 시도 제한 기준 (익명 시청자는 연결마다 ID가 바뀌므로 주소로 묶음)
+func accessAttemptKey(connection *Connection) string {
+	if connection.Authenticated || connection.IP == "" {
+		return "user:" + connection.UserID
+	}
+	return "ip:" + connection.IP
+}
+
+// 제한 시간 안에 실패 횟수를 넘었는지 (지난 기록은 정리)
+func (access *broadcastAccess) lockedOut(key string, now time.Time) bool {
+	failure := access.failures[key]
+	if failure == nil {
+		return false
+	}
+	if now.After(failure.until) {
+		delete(access.failures, key)
+		return false
+	}
+	return failure.count >= maxAccessAttempts
+}
+
+func (access *broadcastAccess) recordFailure(key string, now time.Time) {
+	for k, failure := range access.failures {
+		if now.After(failure.until) {
+			delete(access.failures, k)
+		}
+	}
+
+	failure := access.failures[key]
+	if failure == nil {
+		failure = &accessFailure{}
+		access.failures[key] = failure
+	}
+	failure.count++
+	failure.until = now.Add(accessLockout)
+}
+
+// 초대 토큰 클레임
+type inviteTokenClaims struct {
+	BroadcasterID string `json:"broadcaster_id"`
+	jwt.RegisteredClaims
+}
+
+// 로그인 토큰과 섞이지 않도록 초대 토큰은 별도 키로 서명
+func inviteSigningKey() []byte {
+	sum := sha256.Sum256([]byte("broadcast-invite:" + config.SecretCode))
+	return sum[:]
+}
+
+// 메시지 data 필드에서 접근 설정 추출
+func parseAccessUpdate(data interface{}) (*broadcastAccessUpdate, error) {
+	update := &broadcastAccessUpdate{}
+	if data == nil {
+		return update, nil
+	}
+
+	raw, err := json.Marshal(data)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(raw, update); err != nil {
+		return nil, fmt.Errorf("공개 범위 형식이 올바르지 않습니다")
+	}
+	return update, nil
+}
+
+// 접근 설정 적용
+func (broadcast *BroadcastInfo) applyAccess(update *broadcastAccessUpdate) error {
+	visibility := broadcast.Visibility
+	if update.Visibility != nil {
+		visibility = *update.Visibility
+	}
+	if visibility == "" {
+		visibility = BroadcastVisibilityPublic
+	}
+	if visibility != BroadcastVisibilityPublic && visibility != BroadcastVisibilityUnlisted && visibility != BroadcastVisibilityPrivate {
+		return fmt.Errorf("공개 범위는 public, unlisted, private 중 하나여야 합니다")
+	}
+	if update.Password != nil && len(*update.Password) > maxPasswordLength {
+		return fmt.Errorf("비밀번호는 %d자까지 설정할 수 있습니다", maxPasswordLength)
+	}
+
+	if broadcast.access == nil {
+		broadcast.access = newBroadcastAccess()
+	}
+
+	if update.Password != nil {
+		if err := broadcast.access.setPassword(*update.Password); err != nil {
+			return fmt.Errorf("비밀번호를 설정할 수 없습니다")
+		}
+	}
+	broadcast.Visibility = visibility
+	broadcast.PasswordProtected = broadcast.Visibility == BroadcastVisibilityPrivate && broadcast.access.passwordHash != ""
+	return nil
+}
+
+// 비밀번호 설정 (빈 문자열이면 해제)
+func (access *broadcastAccess) setPassword(password string) error {
+	hash, err := models.HashPassword(password)
+	if err != nil {
+		return err
+	}
+	access.passwordHash = hash
+	return nil
+}
+
+// 비밀번호 확인
+func (access *broadcastAccess) checkPassword(password string) bool {
+	if access.passwordHash == "" || password == "" {
+		return false
+	}
+	ok, _ := models.VerifyPassword(access.passwordHash, password)
+	return ok
+}
+
+// 목록 노출 여부
+func (broadcast *BroadcastInfo) isListed() bool {
+	return broadcast.Visibility == "" || broadcast.Visibility == BroadcastVisibilityPublic
+}
+
+// 기록에 남길 공개 범위 (설정하지 않았으면 공개)
+func (broadcast *BroadcastInfo) visibility() string {
+	if broadcast.Visibility == "" {
+		return BroadcastVisibilityPublic
+	}
+	return broadcast.Visibility
+}
+
+// 메시지에서 비밀번호와 초대 토큰 추출
+func accessCredentialsFromMessage(msg *Message) (string, string) {
+	if data, ok := msg.Data.(map[string]interface{}); ok {
+		return toString(data["password"]), toString(data["invite"])
+	}
+	return "", ""
+}
+
+// 초대 토큰 발급 (방송자 본인 또는 매니저만)
+func (wsService *WebSocketService) CreateInviteToken(broadcasterID string, userID string, ttl time.Duration) (string, time.Time, error) {
+	wsService.Mutex.RLock()
+	allowed := broadcasterID != "" && wsService.canModerate(broadcasterID, userID)
+	wsService.Mutex.RUnlock()
+	if !allowed {
+		return "", time.Time{}, ErrInviteForbidden
+	}
+
+	maxTTL := time.Duration(config.InviteTokenTTL) * time.Second
+	if ttl <= 0 || ttl > maxTTL {
+		ttl = maxTTL
+	}
+
+	now := time.Now()
+	expires := now.Add(ttl)
+	claims := inviteTokenClaims{
+		BroadcasterID: broadcasterID,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject:   userID,
+			Audience:  jwt.ClaimStrings{inviteTokenAudience},
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(expires),
+			ID:        newResumeToken(),
+		},
+	}
+
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, &claims).SignedString(inviteSigningKey())
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	fmt.Printf("🎟️ 초대 토큰 발급: 방송 %s (by %s, %s까지)\n", broadcasterID, userID, expires.Format("2006-01-02 15:04:05"))
+	return token, expires, nil
+}
+
+// 초대 토큰 검증
+func verifyInviteToken(tokenString string, broadcasterID string) error {
+	claims := inviteTokenClaims{}
+	key := func(token *jwt.Token) (interface{}, error) {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, errors.New("Unexpected Signing Method")
+		}
+		return inviteSigningKey(), nil
+	}
+
+	_, err := jwt.ParseWithClaims(tokenString, &claims, key, jwt.WithAudience(inviteTokenAudience))
+	if err != nil || claims.ExpiresAt == nil || claims.BroadcasterID != broadcasterID {
+		return ErrInviteInvalid
+	}
+	return nil
+}
+
+// 시청자의 방송 접근 확인 (호출자가 잠금을 보유해야 함)
+// 허용되면 빈 문자열을, 거부되면 오류 코드를 반환한다.
+func (wsService *WebSocketService) checkAccess(broadcast *BroadcastInfo, viewer *ViewerInfo, password string, invite string) string {
+	if broadcast.Visibility != BroadcastVisibilityPrivate {
+		return ""
+	}
+
+	viewerID := viewer.Connection.UserID
+	if wsService.canModerate(broadcast.BroadcasterID, viewerID) {
+		return ""
+	}
+
+	access := broadcast.access
+	if access == nil {
+		access = newBroadcastAccess()
+		broadcast.access = access
+	}
+	if access.granted[viewerID] {
+		return ""
+	}
+
+	if invite == "" {
+		invite = viewer.invite
+	}
+	if invite != "" && verifyInviteToken(invite, broadcast.BroadcasterID) == nil {
+		access.granted[viewerID] = true
+		return ""
+	}
+
+	if password != "" {
+		key := accessAttemptKey(viewer.Connection)
+		now := time.Now()
+		if access.lockedOut(key, now) {
+			return "too_many_attempts"
+		}
+		if access.checkPassword(password) {
+			delete(access.failures, key)
+			access.granted[viewerID] = true
+			return ""
+		}
+		access.recordFailure(key, now)
+		return "invalid_password"
+	}
+
+	if invite != "" {
+		return "invalid_invite"
+	}
+	return "access_required"
+}
+
+// 접근 거부 알림 전송
+func (wsService *WebSocketService) sendAccessDenied(connection *Connection, broadcast *BroadcastInfo, code string) {
+	messages := map[string]string{
+		"access_required":   "비공개 방송입니다. 비밀번호 또는 초대 링크가 필요합니다.",
+		"invalid_password":  "비밀번호가 일치하지 않습니다.",
+		"invalid_invite":    "초대 링크가 유효하지 않거나 만료되었습니다.",
+		"too_many_attempts": "비밀번호 입력 횟수를 초과했습니다.",
+	}
+
+	wsService.sendToConnection(connection, &Message{
+		Type:          "access_denied",
+		BroadcasterID: broadcast.BroadcasterID,
+		Data: map[string]interface{}{
+			"code":               code,
+			"message":            messages[code],
+			"visibility":         broadcast.Visibility,
+			"password_protected": broadcast.PasswordProtected,
+		},
+	})
+}
+
+// 비공개로 전환된 방송에서 접근 권한이 없는 시청자를 분리 (호출자가 잠금을 보유해야 함)
+// 연결은 유지하고, 비밀번호나 초대 토큰으로 다시 요청할 수 있도록 알린다.
+func (wsService *WebSocketService) detachUngrantedViewers(broadcast *BroadcastInfo) {
+	if broadcast.Visibility != BroadcastVisibilityPrivate {
+		return
+	}
+
+	broadcasterID := broadcast.BroadcasterID
+	detached := 0
+	for viewerID, viewer := range wsService.Viewers {
+		if viewer.BroadcasterID != broadcasterID {
+			continue
+		}
+		if code := wsService.checkAccess(broadcast, viewer, "", ""); code != "" {
+			wsService.recordViewerLeave(viewer)
+			viewer.BroadcasterID = ""
+			viewer.requested = broadcasterID
+			if viewer.admitted && broadcast.Mode == BroadcastModeSFU {
+				wsService.webrtc.Unsubscribe(broadcasterID, viewerID)
+			}
+			viewer.admitted = false
+			delete(wsService.PendingOffers, viewerID)
+			wsService.sendAccessDenied(viewer.Connection, broadcast, code)
+			if broadcaster := wsService.Broadcasters[broadcasterID]; broadcaster != nil {
+				wsService.sendToConnection(broadcaster, &Message{
+					Type:       "viewer_left",
+					ViewerID:   viewerID,
+					ViewerName: viewer.Connection.Name,
+				})
+			}
+			detached++
+		}
+	}
+
+	if detached > 0 {
+		fmt.Printf("🔒 비공개 방송 접근 권한 없는 시청자 분리: %s, %d명\n", broadcasterID, detached)
+		broadcast.ViewerCount = wsService.countViewers(broadcasterID)
+		wsService.updateViewerCount(broadcasterID, broadcast.ViewerCount)
+		wsService.admitWaiting(broadcasterID)
+	}
+}
@@ -0,0 +1,131 @@
+package services
+
+import (
+	"testing"
+	"time"
+	"toysgo/config"
+	"toysgo/models"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func TestVerifyInviteToken(t *testing.T) {
+	wsService := &WebSocketService{moderation: make(map[string]*moderationList)}
+
+	issued, _, err := wsService.CreateInviteToken("1", "1", time.Hour)
+	if err != nil {
+		t.Fatalf("CreateInviteToken: %v", err)
+	}
+
+	now := time.Now()
+	sign := func(claims *inviteTokenClaims, key []byte) string {
+		token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(key)
+		if err != nil {
+			t.Fatalf("sign: %v", err)
+		}
+		return token
+	}
+	claims := func(audience []string, expires time.Time) *inviteTokenClaims {
+		item := &inviteTokenClaims{
+			BroadcasterID: "1",
+			RegisteredClaims: jwt.RegisteredClaims{
+				Subject:  "1",
+				Audience: audience,
+				IssuedAt: jwt.NewNumericDate(now),
+			},
+		}
+		if !expires.IsZero() {
+			item.ExpiresAt = jwt.NewNumericDate(expires)
+		}
+		return item
+	}
+
+	tests := []struct {
+		name          string
+		token         string
+		broadcasterID string
+		ok            bool
+	}{
+		{"issued token", issued, "1", true},
+		{"other broadcast", issued, "2", false},
+		{"signed with invite key", sign(claims([]string{inviteTokenAudience}, now.Add(time.Hour)), inviteSigningKey()), "1", true},
+		{"missing audience", sign(claims(nil, now.Add(time.Hour)), inviteSigningKey()), "1", false},
+		{"other audience", sign(claims([]string{"api"}, now.Add(time.Hour)), inviteSigningKey()), "1", false},
+		{"extra audience", sign(claims([]string{"api", inviteTokenAudience}, now.Add(time.Hour)), inviteSigningKey()), "1", true},
+		{"login secret", sign(claims([]string{inviteTokenAudience}, now.Add(time.Hour)), []byte(config.SecretCode)), "1", false},
+		{"expired", sign(claims([]string{inviteTokenAudience}, now.Add(-time.Minute)), inviteSigningKey()), "1", false},
+		{"no expiry", sign(claims([]string{inviteTokenAudience}, time.Time{}), inviteSigningKey()), "1", false},
+		{"empty", "", "1", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := verifyInviteToken(tt.token, tt.broadcasterID)
+			if (err == nil) != tt.ok {
+				t.Fatalf("verifyInviteToken err = %v, want ok %v", err, tt.ok)
+			}
+		})
+	}
+}
+
+// 방송자 본인 또는 매니저만 초대 토큰을 발급할 수 있음
+func TestCreateInviteTokenPermission(t *testing.T) {
+	wsService := &WebSocketService{moderation: map[string]*moderationList{"1": newModerationList()}}
+	wsService.moderation["1"].moderators["2"] = true
+
+	tests := []struct {
+		name   string
+		userID string
+		ok     bool
+	}{
+		{"broadcaster", "1", true},
+		{"moderator", "2", true},
+		{"viewer", "3", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, _, err := wsService.CreateInviteToken("1", tt.userID, time.Hour)
+			if (err == nil) != tt.ok {
+				t.Fatalf("CreateInviteToken err = %v, want ok %v", err, tt.ok)
+			}
+		})
+	}
+}
+
+func TestBroadcastPassword(t *testing.T) {
+	access := newBroadcastAccess()
+	if err := access.setPassword("secret"); err != nil {
+		t.Fatalf("setPassword: %v", err)
+	}
+	if !models.IsPasswordHash(access.passwordHash) {
+		t.Fatalf("passwordHash = %q, want a password hash", access.passwordHash)
+	}
+
+	tests := []struct {
+		name     string
+		password string
+		ok       bool
+	}{
+		{"match", "secret", true},
+		{"mismatch", "wrong", false},
+		{"empty", "", false},
+		{"hash as input", access.passwordHash, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if ok := access.checkPassword(tt.password); ok != tt.ok {
+				t.Fatalf("checkPassword = %v, want %v", ok, tt.ok)
+			}
+		})
+	}
+
+	// 빈 문자열이면 비밀번호 해제
+	if err := access.setPassword(""); err != nil {
+		t.Fatalf("setPassword: %v", err)
+	}
+	if access.checkPassword("secret") {
+		t.Fatalf("checkPassword succeeded after clearing the password")
+	}
+}
@@ -0,0 +1,106 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+	"toysgo/auth"
+	"toysgo/config"
+
+	"github.com/gofiber/websocket/v2"
+)
+
+var (
+	ErrAuthRequired = errors.New("인증이 필요합니다")
+	ErrAuthInvalid  = errors.New("유효하지 않은 토큰입니다")
+	ErrAuthLate     = errors.New("인증 대기 시간이 지나 익명으로 연결되었습니다. 토큰과 함께 다시 연결해 주세요")
+)
+
+// 시그널링 소켓 인증
+//
+// 토큰은 쿼리 파라미터(token) 또는 첫 메시지({"type":"auth","token":"..."})로 전달한다.
+// 첫 메시지가 auth 타입이 아니면 익명 연결로 간주하고, 해당 메시지는 핸들러에서 그대로 처리된다.
+// 첫 메시지가 WsAuthTimeout 안에 오지 않아도 익명 시청이 허용되면 익명 연결로 진행한다.
+// 익명 시청자는 쿼리 파라미터 anonymous=1 또는 빈 토큰 auth 메시지로 대기 없이 바로 연결할 수 있다.
+// 대기 시간이 지난 뒤 도착한 auth 메시지는 연결을 인증하지 않고 ErrAuthLate로 연결을 끝낸다 (Connection.ReadMessage).
+// 방송자는 항상 인증이 필요하며, 익명 시청은 config.AllowAnonymousViewer 정책을 따른다.
+// 인증에 성공하면 송신 고루틴이 시작되므로, 호출자는 연결이 끝날 때 Close()를 호출해야 한다.
+func (wsService *WebSocketService) Authenticate(conn *websocket.Conn, role string) (*Connection, error) {
+	connection := newConnection(conn, role, &wsService.sendStats)
+	connection.IP, _ = conn.Locals("ip").(string)
+
+	token := conn.Query("token")
+	if token == "" && conn.Query("anonymous") != "1" {
+		// 첫 메시지는 별도 고루틴에서 읽음 (읽기 제한 시간이 지나면 이후 읽기가 모두 실패하므로 deadline을 쓰지 않음)
+		first := make(chan readResult, 1)
+		go func() {
+			_, message, err := conn.ReadMessage()
+			first <- readResult{message: message, err: err}
+		}()
+
+		timer := time.NewTimer(time.Duration(config.WsAuthTimeout) * time.Second)
+		select {
+		case result := <-first:
+			timer.Stop()
+			if result.err != nil {
+				return nil, ErrAuthRequired
+			}
+
+			var msg Message
+			if err := json.Unmarshal(result.message, &msg); err == nil && msg.Type == "auth" {
+				token = msg.Token
+			} else {
+				connection.pending = result.message
+			}
+		case <-timer.C:
+			// 아무것도 보내지 않는 익명 시청자(목록 구독 등)는 익명 연결로 진행
+			// 늦게 도착한 첫 메시지는 핸들러에서 일반 메시지로 처리된다 (auth 메시지는 제외).
+			if role == "broadcaster" || !config.AllowAnonymousViewer {
+				return nil, ErrAuthRequired
+			}
+			connection.firstRead = first
+		}
+	}
+
+	if token != "" {
+		_, user, err := auth.Authenticate(token)
+		if err != nil {
+			fmt.Printf("❌ 토큰 검증 실패 (%s): %v\n", role, err)
+			return nil, ErrAuthInvalid
+		}
+
+		connection.UserID = fmt.Sprintf("%d", user.Id)
+		connection.Name = user.Name
+		connection.Authenticated = true
+		connection.start()
+		return connection, nil
+	}
+
+	if role == "broadcaster" || !config.AllowAnonymousViewer {
+		return nil, ErrAuthRequired
+	}
+
+	connection.UserID = fmt.Sprintf("anonymous_%d", time.Now().UnixNano())
+	connection.Name = conn.Query("user_name")
+	connection.start()
+	return connection, nil
+}
+
+// 인증 결과 전송 (방송자에게는 ICE 설정도 함께 전달)
+func (wsService *WebSocketService) sendAuthenticated(connection *Connection) {
+	data := map[string]interface{}{
+		"user_id":       connection.UserID,
+		"user_name":     connection.Name,
+		"role":          connection.Role,
+		"authenticated": connection.Authenticated,
+	}
+	if connection.Role == "broadcaster" {
+		addICEConfiguration(data, connection.UserID)
+	}
+
+	wsService.sendToConnection(connection, &Message{
+		Type: "authenticated",
+		Data: data,
+	})
+}
@@ -0,0 +1,277 @@
+package services
+
+import (
+	"fmt"
+	"time"
+	"toysgo/config"
+)
+
+// 최대 시청자 수 정규화 (0 이하는 제한 없음, 서버 상한을 넘으면 상한으로)
+func normalizeMaxViewers(value int) int {
+	if value < 0 {
+		value = 0
+	}
+	if limit := config.MaxViewersLimit; limit > 0 && (value == 0 || value > limit) {
+		value = limit
+	}
+	return value
+}
+
+// 메시지 data 필드에서 최대 시청자 수 추출 (전달되지 않았으면 false)
+func maxViewersFromMessage(msg *Message) (int, bool) {
+	data, ok := msg.Data.(map[string]interface{})
+	if !ok {
+		return 0, false
+	}
+
+	switch v := data["max_viewers"].(type) {
+	case float64:
+		return int(v), true
+	case int:
+		return v, true
+	}
+	return 0, false
+}
+
+// 스트림을 받고 있는 시청자 수 (WHEP 시청자 포함, 호출자가 잠금을 보유해야 함)
+func (wsService *WebSocketService) countAdmitted(broadcasterID string) int {
+	count := 0
+	if broadcast := wsService.ActiveBroadcasts[broadcasterID]; broadcast != nil {
+		count = len(broadcast.whep)
+	}
+	for _, viewer := range wsService.Viewers {
+		if viewer.BroadcasterID == broadcasterID && viewer.admitted {
+			count++
+		}
+	}
+	return count
+}
+
+// 빈 자리가 있는지 확인 (호출자가 잠금을 보유해야 함)
+func (wsService *WebSocketService) hasCapacity(broadcast *BroadcastInfo) bool {
+	return broadcast.MaxViewers <= 0 || wsService.countAdmitted(broadcast.BroadcasterID) < broadcast.MaxViewers
+}
+
+// 대기열에 추가 (이미 있으면 위치만 다시 알림, 호출자가 잠금을 보유해야 함)
+func (wsService *WebSocketService) enqueueViewer(viewerID string, viewer *ViewerInfo, broadcast *BroadcastInfo) {
+	viewer.BroadcasterID = ""
+	viewer.requested = broadcast.BroadcasterID
+	viewer.admitted = false
+
+	queued := false
+	for _, id := range broadcast.waiting {
+		if id == viewerID {
+			queued = true
+			break
+		}
+	}
+	if !queued {
+		broadcast.waiting = append(broadcast.waiting, viewerID)
+		fmt.Printf("⏳ 대기열 추가: %s -> 방송 %s (%d번째)\n", viewerID, broadcast.BroadcasterID, len(broadcast.waiting))
+	}
+
+	wsService.notifyQueue(broadcast)
+}
+
+// 대기열에서 제거 (호출자가 잠금을 보유해야 함)
+func (wsService *WebSocketService) dequeueViewer(broadcasterID string, viewerID string) {
+	broadcast := wsService.ActiveBroadcasts[broadcasterID]
+	if broadcast == nil {
+		return
+	}
+
+	for i, id := range broadcast.waiting {
+		if id == viewerID {
+			broadcast.waiting = append(broadcast.waiting[:i], broadcast.waiting[i+1:]...)
+			wsService.notifyQueue(broadcast)
+			return
+		}
+	}
+}
+
+// 대기 중인 시청자 모두에게 현재 순번 전송 (호출자가 잠금을 보유해야 함)
+func (wsService *WebSocketService) notifyQueue(broadcast *BroadcastInfo) {
+	broadcast.WaitingCount = len(broadcast.waiting)
+
+	for i, viewerID := range broadcast.waiting {
+		viewer := wsService.Viewers[viewerID]
+		if viewer == nil {
+			continue
+		}
+		wsService.sendToConnection(viewer.Connection, &Message{
+			Type:          "queue_update",
+			BroadcasterID: broadcast.BroadcasterID,
+			Data: map[string]interface{}{
+				"position":    i + 1,
+				"size":        len(broadcast.waiting),
+				"max_viewers": broadcast.MaxViewers,
+			},
+		})
+	}
+
+	if broadcaster := wsService.Broadcasters[broadcast.BroadcasterID]; broadcaster != nil {
+		wsService.sendToConnection(broadcaster, &Message{
+			Type:  "queue_size_update",
+			Count: len(broadcast.waiting),
+		})
+	}
+}
+
+// 빈 자리만큼 대기열 앞에서부터 입장 (호출자가 잠금을 보유해야 함)
+func (wsService *WebSocketService) admitWaiting(broadcasterID string) {
+	broadcast := wsService.ActiveBroadcasts[broadcasterID]
+	if broadcast == nil || len(broadcast.waiting) == 0 {
+		return
+	}
+
+	admitted := 0
+	for len(broadcast.waiting) > 0 && wsService.hasCapacity(broadcast) {
+		viewerID := broadcast.waiting[0]
+		broadcast.waiting = broadcast.waiting[1:]
+
+		viewer := wsService.Viewers[viewerID]
+		if viewer == nil || viewer.requested != broadcasterID {
+			continue
+		}
+
+		// 기다리는 동안 차단되었거나 비공개로 바뀐 경우
+		if wsService.isBanned(broadcasterID, viewerID) {
+			viewer.requested = ""
+			wsService.sendBanned(viewer.Connection, broadcasterID)
+			continue
+		}
+		if code := wsService.checkAccess(broadcast, viewer, "", ""); code != "" {
+			wsService.sendAccessDenied(viewer.Connection, broadcast, code)
+			continue
+		}
+
+		fmt.Printf("🚪 대기열 입장: %s -> 방송 %s\n", viewerID, broadcasterID)
+		wsService.sendToConnection(viewer.Connection, &Message{
+			Type:          "queue_admitted",
+			BroadcasterID: broadcasterID,
+		})
+		wsService.admitViewer(viewerID, viewer, broadcast)
+		admitted++
+	}
+
+	if admitted > 0 || broadcast.WaitingCount != len(broadcast.waiting) {
+		wsService.notifyQueue(broadcast)
+	}
+}
+
+// 시청자에게 자리를 배정하고 방송자에게 Offer 요청 (호출자가 잠금을 보유해야 함)
+// 방송자가 재접속 중이면 자리만 잡아 두고, 재개될 때 Offer 요청이 다시 전송된다.
+func (wsService *WebSocketService) admitViewer(viewerID string, viewer *ViewerInfo, broadcast *BroadcastInfo) {
+	broadcasterID := broadcast.BroadcasterID
+
+	viewer.requested = ""
+	viewer.BroadcasterID = broadcasterID
+	viewer.admitted = true
+
+	broadcaster := wsService.Broadcasters[broadcasterID]
+	if broadcast.Status == BroadcastStatusReconnecting || (broadcaster == nil && !broadcast.isWHIP()) {
+		wsService.sendToConnection(viewer.Connection, &Message{
+			Type:          "broadcaster_reconnecting",
+			BroadcasterID: broadcasterID,
+			Data: map[string]interface{}{
+				"deadline": broadcast.reconnectDeadline.Format(time.RFC3339),
+			},
+		})
+		return
+	}
+
+	// 같은 시청자의 재요청이 두 번 세어지지 않도록 다시 계산
+	broadcast.ViewerCount = wsService.countViewers(broadcasterID)
+
+	// SFU 방송은 서버가 직접 Offer를 만들어 보냄
+	if broadcast.Mode == BroadcastModeSFU {
+		go wsService.subscribeViewer(broadcasterID, viewerID, viewer)
+		wsService.updateViewerCount(broadcasterID, broadcast.ViewerCount)
+		return
+	}
+
+	wsService.sendToConnection(broadcaster, &Message{
+		Type:       "offer_request",
+		ViewerID:   viewerID,
+		ViewerName: viewer.Connection.Name,
+	})
+	wsService.PendingOffers[viewerID] = broadcasterID
+	wsService.updateViewerCount(broadcasterID, broadcast.ViewerCount)
+}
+
+// 시청자가 빠진 뒤 자리 정리 (대기열 제거 후 빈 자리 채움, 호출자가 잠금을 보유해야 함)
+func (wsService *WebSocketService) releaseViewerSlot(viewerID string, viewer *ViewerInfo, broadcasterID string) {
+	if viewer.requested != "" {
+		wsService.dequeueViewer(viewer.requested, viewerID)
+	}
+	if viewer.admitted {
+		viewer.admitted = false
+		if wsService.usesSFU(broadcasterID) {
+			wsService.webrtc.Unsubscribe(broadcasterID, viewerID)
+		}
+		wsService.admitWaiting(broadcasterID)
+	}
+}
+
+// 다른 방송으로 옮기는 시청자의 이전 대기열과 자리 반납 (호출자가 잠금을 보유해야 함)
+// 이전 방송의 시청자 수를 다시 계산하고 방송자에게 퇴장을 알린다.
+func (wsService *WebSocketService) leaveOtherBroadcast(viewerID string, viewer *ViewerInfo, broadcasterID string) {
+	if viewer.requested != "" && viewer.requested != broadcasterID {
+		wsService.dequeueViewer(viewer.requested, viewerID)
+		viewer.requested = ""
+	}
+
+	previousID := viewer.BroadcasterID
+	if previousID == "" || previousID == broadcasterID {
+		return
+	}
+
+	wsService.recordViewerLeave(viewer)
+	viewer.BroadcasterID = ""
+	delete(wsService.PendingOffers, viewerID)
+	if viewer.admitted {
+		viewer.admitted = false
+		if wsService.usesSFU(previousID) {
+			wsService.webrtc.Unsubscribe(previousID, viewerID)
+		}
+		wsService.admitWaiting(previousID)
+	}
+
+	previous := wsService.ActiveBroadcasts[previousID]
+	if previous == nil {
+		return
+	}
+	previous.ViewerCount = wsService.countViewers(previousID)
+	if broadcaster := wsService.Broadcasters[previousID]; broadcaster != nil {
+		wsService.sendToConnection(broadcaster, &Message{
+			Type:       "viewer_left",
+			ViewerID:   viewerID,
+			ViewerName: viewer.Connection.Name,
+			Count:      previous.ViewerCount,
+		})
+	}
+	wsService.updateViewerCount(previousID, previous.ViewerCount)
+}
+
+// 방송 종료 시 대기 중인 시청자 정리 (호출자가 잠금을 보유해야 함)
+func (wsService *WebSocketService) closeQueue(broadcast *BroadcastInfo, endMsg *Message) {
+	for _, viewerID := range broadcast.waiting {
+		viewer := wsService.Viewers[viewerID]
+		if viewer == nil || viewer.requested != broadcast.BroadcasterID {
+			continue
+		}
+		viewer.requested = ""
+		wsService.sendToConnection(viewer.Connection, endMsg)
+	}
+	broadcast.waiting = nil
+	broadcast.WaitingCount = 0
+}
+
+// 최대 시청자 수 변경 (늘어나면 대기열에서 입장, 호출자가 잠금을 보유해야 함)
+func (wsService *WebSocketService) setMaxViewers(broadcast *BroadcastInfo, value int) {
+	broadcast.MaxViewers = normalizeMaxViewers(value)
+	wsService.admitWaiting(broadcast.BroadcasterID)
+	if len(broadcast.waiting) > 0 {
+		wsService.notifyQueue(broadcast)
+	}
+}
@@ -0,0 +1,235 @@
+package services
+
+import (
+	"fmt"
+	"strings"
+	"time"
+	"toysgo/config"
+	"unicode/utf8"
+)
+
+// 채팅 메시지
+type ChatMessage struct {
+	ID            int64     `json:"id"`
+	BroadcasterID string    `json:"broadcaster_id"`
+	UserID        string    `json:"user_id"`
+	UserName      string    `json:"user_name"`
+	Role          string    `json:"role"`
+	Text          string    `json:"text"`
+	Timestamp     time.Time `json:"timestamp"`
+}
+
+// 방송별 채팅방 (방송 정보와 함께 서비스 뮤텍스로 보호됨)
+type chatRoom struct {
+	nextID  int64
+	backlog []ChatMessage
+
+	// 사용자별 최근 전송 시각 (전송 속도 제한)
+	recent map[string][]time.Time
+
+	// 채팅 금지 사용자 (만료 시각, 0이면 해제할 때까지)
+	muted map[string]time.Time
+
+	// 슬로우 모드 (사용자별 최소 전송 간격, 0이면 해제)
+	slowMode time.Duration
+	lastSent map[string]time.Time
+}
+
+func newChatRoom() *chatRoom {
+	return &chatRoom{
+		backlog:  make([]ChatMessage, 0),
+		recent:   make(map[string][]time.Time),
+		muted:    make(map[string]time.Time),
+		lastSent: make(map[string]time.Time),
+	}
+}
+
+// 채팅 금지 (duration이 0 이하이면 해제할 때까지, 만료 시각 반환)
+func (room *chatRoom) mute(userID string, duration time.Duration) time.Time {
+	until := time.Time{}
+	if duration > 0 {
+		until = time.Now().Add(duration)
+	}
+	room.muted[userID] = until
+	return until
+}
+
+// 채팅 금지 해제
+func (room *chatRoom) unmute(userID string) {
+	delete(room.muted, userID)
+}
+
+// 채팅 금지 여부 (만료된 항목은 삭제)
+func (room *chatRoom) isMuted(userID string, now time.Time) bool {
+	until, ok := room.muted[userID]
+	if !ok {
+		return false
+	}
+	if !until.IsZero() && !now.Before(until) {
+		delete(room.muted, userID)
+		return false
+	}
+	return true
+}
+
+// 슬로우 모드 확인 (남은 대기 시간이 있으면 false)
+func (room *chatRoom) allowSlowMode(userID string, now time.Time) (time.Duration, bool) {
+	if room.slowMode <= 0 {
+		return 0, true
+	}
+	if last, ok := room.lastSent[userID]; ok {
+		if wait := room.slowMode - now.Sub(last); wait > 0 {
+			return wait, false
+		}
+	}
+	return 0, true
+}
+
+// 전송 속도 제한 확인 (윈도 안에 허용 개수를 넘으면 false)
+func (room *chatRoom) allow(userID string, now time.Time) bool {
+	limit := config.ChatRateLimit
+	window := time.Duration(config.ChatRateWindow) * time.Second
+	if limit <= 0 || window <= 0 {
+		return true
+	}
+
+	recent := room.recent[userID]
+	kept := recent[:0]
+	for _, t := range recent {
+		if now.Sub(t) < window {
+			kept = append(kept, t)
+		}
+	}
+
+	if len(kept) >= limit {
+		room.recent[userID] = kept
+		return false
+	}
+
+	room.recent[userID] = append(kept, now)
+	return true
+}
+
+// 메시지 추가 (최대 보관 개수를 넘으면 오래된 메시지부터 삭제)
+func (room *chatRoom) append(message ChatMessage) ChatMessage {
+	room.nextID++
+	message.ID = room.nextID
+
+	room.backlog = append(room.backlog, message)
+	if size := config.ChatBacklogSize; size > 0 && len(room.backlog) > size {
+		room.backlog = append([]ChatMessage(nil), room.backlog[len(room.backlog)-size:]...)
+	}
+	return message
+}
+
+// 보관 중인 메시지 복사 (afterID 이후 메시지만)
+func (room *chatRoom) history(afterID int64) []ChatMessage {
+	messages := make([]ChatMessage, 0, len(room.backlog))
+	for _, message := range room.backlog {
+		if message.ID > afterID {
+			messages = append(messages, message)
+		}
+	}
+	return messages
+}
+
+// 메시지 data 필드에서 채팅 내용 추출 ("..." 또는 {"text":"..."})
+func chatTextFromMessage(msg *Message) string {
+	if data, ok := msg.Data.(map[string]interface{}); ok {
+		return toString(data["text"])
+	}
+	return toString(msg.Data)
+}
+
+// 채팅 메시지 처리 (방송자 또는 시청자)
+func (wsService *WebSocketService) handleChatMessage(sender *Connection, broadcasterID string, msg *Message) {
+	wsService.Mutex.Lock()
+	defer wsService.Mutex.Unlock()
+
+	broadcast := wsService.ActiveBroadcasts[broadcasterID]
+	if broadcast == nil || broadcast.chat == nil {
+		wsService.sendChatError(sender, "not_found", "방송을 찾을 수 없습니다.")
+		return
+	}
+
+	text := strings.TrimSpace(chatTextFromMessage(msg))
+	if text == "" {
+		wsService.sendChatError(sender, "empty", "메시지를 입력해 주세요.")
+		return
+	}
+
+	if config.ChatMaxLength > 0 && utf8.RuneCountInString(text) > config.ChatMaxLength {
+		wsService.sendChatError(sender, "too_long", fmt.Sprintf("메시지는 %d자까지 보낼 수 있습니다.", config.ChatMaxLength))
+		return
+	}
+
+	now := time.Now()
+	if broadcast.chat.isMuted(sender.UserID, now) {
+		wsService.sendChatError(sender, "muted", "채팅이 금지되었습니다.")
+		return
+	}
+
+	// 방송자와 매니저는 슬로우 모드 적용 제외
+	if !wsService.canModerate(broadcasterID, sender.UserID) {
+		if wait, ok := broadcast.chat.allowSlowMode(sender.UserID, now); !ok {
+			wsService.sendChatError(sender, "slow_mode", fmt.Sprintf("슬로우 모드입니다. %d초 후에 보낼 수 있습니다.", int(wait.Seconds())+1))
+			return
+		}
+	}
+
+	if !broadcast.chat.allow(sender.UserID, now) {
+		wsService.sendChatError(sender, "rate_limited", "메시지를 너무 빠르게 보내고 있습니다.")
+		return
+	}
+	broadcast.chat.lastSent[sender.UserID] = now
+
+	message := broadcast.chat.append(ChatMessage{
+		BroadcasterID: broadcasterID,
+		UserID:        sender.UserID,
+		UserName:      sender.Name,
+		Role:          sender.Role,
+		Text:          text,
+		Timestamp:     now,
+	})
+
+	wsService.sendToBroadcastAudience(broadcasterID, &Message{
+		Type:          "chat_message",
+		BroadcasterID: broadcasterID,
+		Data:          message,
+	})
+}
+
+// 채팅 오류 전송
+func (wsService *WebSocketService) sendChatError(connection *Connection, code string, reason string) {
+	wsService.sendToConnection(connection, &Message{
+		Type: "chat_error",
+		Data: map[string]interface{}{
+			"code":    code,
+			"message": reason,
+		},
+	})
+}
+
+// 방송자와 해당 방송의 모든 시청자에게 전송 (호출자가 잠금을 보유해야 함)
+func (wsService *WebSocketService) sendToBroadcastAudience(broadcasterID string, msg *Message) {
+	if broadcaster := wsService.Broadcasters[broadcasterID]; broadcaster != nil {
+		wsService.sendToConnection(broadcaster, msg)
+	}
+	for _, viewer := range wsService.Viewers {
+		if viewer.BroadcasterID == broadcasterID {
+			wsService.sendToConnection(viewer.Connection, msg)
+		}
+	}
+}
+
+// API용 채팅 기록 반환
+func (wsService *WebSocketService) GetChatHistory(broadcasterID string, afterID int64) ([]ChatMessage, bool) {
+	wsService.Mutex.RLock()
+	defer wsService.Mutex.RUnlock()
+
+	broadcast := wsService.ActiveBroadcasts[broadcasterID]
+	if broadcast == nil || broadcast.chat == nil || broadcast.Visibility == BroadcastVisibilityPrivate {
+		return nil, false
+	}
+	return broadcast.chat.history(afterID), true
+}
@@ -0,0 +1,262 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync/atomic"
+	"time"
+	"toysgo/config"
+
+	"github.com/gofiber/websocket/v2"
+)
+
+// 느린 소비자 처리 정책
+const (
+	SlowConsumerDrop       = "drop"       // 큐가 가득 차면 새 메시지 폐기
+	SlowConsumerDisconnect = "disconnect" // 큐가 가득 차면 연결 종료
+)
+
+// 송신 큐 통계 (서비스 전체)
+type sendStats struct {
+	dropped         uint64
+	slowDisconnects uint64
+	writeErrors     uint64
+}
+
+// 연결 생성 (송신 큐 포함)
+func newConnection(conn *websocket.Conn, role string, stats *sendStats) *Connection {
+	size := config.WsSendQueueSize
+	if size <= 0 {
+		size = 64
+	}
+
+	return &Connection{
+		Conn:    conn,
+		Role:    role,
+		send:    make(chan []byte, size),
+		closing: make(chan struct{}),
+		done:    make(chan struct{}),
+		stats:   stats,
+	}
+}
+
+// 송신 고루틴 시작 및 하트비트 설정
+func (c *Connection) start() {
+	c.touch()
+	c.extendReadDeadline()
+	c.Conn.SetPongHandler(func(string) error {
+		c.touch()
+		c.extendReadDeadline()
+		return nil
+	})
+
+	go c.writePump()
+}
+
+// 마지막 수신 시각 갱신
+func (c *Connection) touch() {
+	atomic.StoreInt64(&c.lastSeen, time.Now().UnixNano())
+}
+
+// 마지막 수신 시각
+func (c *Connection) LastSeen() time.Time {
+	return time.Unix(0, atomic.LoadInt64(&c.lastSeen))
+}
+
+// 유휴 시간 초과 여부
+func (c *Connection) IsIdle(now time.Time) bool {
+	timeout := time.Duration(config.WsIdleTimeout) * time.Second
+	return timeout > 0 && now.Sub(c.LastSeen()) > timeout
+}
+
+// 읽기 데드라인 연장 (유휴 시간 안에 아무것도 수신하지 못하면 읽기 루프가 종료됨)
+func (c *Connection) extendReadDeadline() {
+	timeout := time.Duration(config.WsIdleTimeout) * time.Second
+	if timeout > 0 {
+		c.Conn.SetReadDeadline(time.Now().Add(timeout))
+	}
+}
+
+// 단일 송신 고루틴: 웹소켓 연결에 쓰는 유일한 경로
+func (c *Connection) writePump() {
+	defer close(c.done)
+	defer c.Conn.Close()
+
+	interval := time.Duration(config.WsPingInterval) * time.Second
+	if interval <= 0 {
+		interval = 25 * time.Second
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case data := <-c.send:
+			if !c.write(data) {
+				return
+			}
+		case <-ticker.C:
+			deadline := time.Now().Add(time.Duration(config.WsWriteTimeout) * time.Second)
+			if err := c.Conn.WriteControl(websocket.PingMessage, nil, deadline); err != nil {
+				fmt.Printf("❌ Ping 전송 실패 (%s): %v\n", c.UserID, err)
+				return
+			}
+		case <-c.closing:
+			// 남은 메시지를 보낸 뒤 종료
+			for {
+				select {
+				case data := <-c.send:
+					if !c.write(data) {
+						return
+					}
+				default:
+					return
+				}
+			}
+		}
+	}
+}
+
+func (c *Connection) write(data []byte) bool {
+	timeout := time.Duration(config.WsWriteTimeout) * time.Second
+	if timeout > 0 {
+		c.Conn.SetWriteDeadline(time.Now().Add(timeout))
+	}
+
+	if err := c.Conn.WriteMessage(websocket.TextMessage, data); err != nil {
+		fmt.Printf("❌ 메시지 전송 실패 (%s): %v\n", c.UserID, err)
+		if c.stats != nil {
+			atomic.AddUint64(&c.stats.writeErrors, 1)
+		}
+		return false
+	}
+	return true
+}
+
+// 송신 큐에 메시지 추가 (블로킹하지 않음, 웹소켓이 없는 연결은 항상 false)
+func (c *Connection) Send(data []byte) bool {
+	if c.send == nil {
+		return false
+	}
+
+	select {
+	case <-c.closing:
+		return false
+	default:
+	}
+
+	select {
+	case c.send <- data:
+		return true
+	default:
+	}
+
+	atomic.AddUint64(&c.dropped, 1)
+	if c.stats != nil {
+		atomic.AddUint64(&c.stats.dropped, 1)
+	}
+
+	if config.WsSlowConsumerPolicy == SlowConsumerDisconnect {
+		c.slowOnce.Do(func() {
+			fmt.Printf("🐢 느린 소비자 연결 종료: %s (%s)\n", c.UserID, c.Role)
+			if c.stats != nil {
+				atomic.AddUint64(&c.stats.slowDisconnects, 1)
+			}
+			c.Abort()
+		})
+	} else {
+		fmt.Printf("🐢 송신 큐 가득 참, 메시지 폐기: %s (%s)\n", c.UserID, c.Role)
+	}
+
+	return false
+}
+
+// 남은 메시지를 전송한 뒤 연결 종료 (기다리지 않음)
+func (c *Connection) Shutdown() {
+	c.closeOnce.Do(func() {
+		if c.closing != nil {
+			close(c.closing)
+		}
+		if c.onClose != nil {
+			c.onClose()
+		}
+	})
+}
+
+// 남은 메시지를 버리고 즉시 연결 종료
+// 송신 고루틴이 쓰기 중이거나 읽기 루프가 대기 중이어도 소켓을 닫아 바로 깨운다.
+func (c *Connection) Abort() {
+	if c.Conn != nil {
+		c.Conn.Close()
+	}
+	c.Shutdown()
+}
+
+// 연결 종료 후 송신 고루틴 종료까지 대기
+func (c *Connection) Close() {
+	c.Shutdown()
+	if c.done != nil {
+		<-c.done
+	}
+}
+
+// 송신 큐에 쌓인 메시지 수
+func (c *Connection) QueueDepth() int {
+	return len(c.send)
+}
+
+// 폐기된 메시지 수
+func (c *Connection) Dropped() uint64 {
+	return atomic.LoadUint64(&c.dropped)
+}
+
+// 인증 단계에서 시작한 첫 메시지 읽기 결과
+type readResult struct {
+	message []byte
+	err     error
+}
+
+// 다음 메시지 읽기 (인증 단계에서 읽어 둔 메시지가 있으면 먼저 반환)
+func (c *Connection) ReadMessage() ([]byte, error) {
+	if c.pending != nil {
+		message := c.pending
+		c.pending = nil
+		return message, nil
+	}
+
+	var message []byte
+	var err error
+	if c.firstRead != nil {
+		result := <-c.firstRead
+		c.firstRead = nil
+		message, err = result.message, result.err
+		if err == nil {
+			if late, ok := lateAuth(message); ok {
+				if late {
+					// 이미 익명으로 등록된 연결은 인증하지 않음 (오류를 보내고 연결 종료)
+					data, _ := json.Marshal(&Message{Type: "error", Data: ErrAuthLate.Error()})
+					c.Send(data)
+					return nil, ErrAuthLate
+				}
+				// 빈 토큰은 익명 연결 의사 표시이므로 건너뜀
+				return c.ReadMessage()
+			}
+		}
+	} else {
+		_, message, err = c.Conn.ReadMessage()
+	}
+	if err == nil {
+		c.touch()
+		c.extendReadDeadline()
+	}
+	return message, err
+}
+
+// 인증 대기 시간이 지난 뒤 도착한 첫 메시지가 auth 메시지인지 (late는 토큰이 있는 경우)
+func lateAuth(message []byte) (late bool, ok bool) {
+	var msg Message
+	if err := json.Unmarshal(message, &msg); err != nil || msg.Type != "auth" {
+		return false, false
+	}
+	return msg.Token != "", true
+}
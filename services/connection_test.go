@@ -0,0 +1,53 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+func TestLateAuth(t *testing.T) {
+	tests := []struct {
+		name    string
+		message string
+		late    bool
+		ok      bool
+	}{
+		{"auth with token", `{"type":"auth","token":"abc"}`, true, true},
+		{"auth without token", `{"type":"auth","token":""}`, false, true},
+		{"other message", `{"type":"viewer_join"}`, false, false},
+		{"invalid json", `auth`, false, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			late, ok := lateAuth([]byte(tt.message))
+			if late != tt.late || ok != tt.ok {
+				t.Fatalf("lateAuth = (%v, %v), want (%v, %v)", late, ok, tt.late, tt.ok)
+			}
+		})
+	}
+}
+
+// 익명으로 진행한 뒤 도착한 토큰은 인증하지 않고 오류를 보낸 뒤 읽기를 끝냄
+func TestReadMessageLateAuth(t *testing.T) {
+	first := make(chan readResult, 1)
+	first <- readResult{message: []byte(`{"type":"auth","token":"abc"}`)}
+
+	connection := newConnection(nil, "viewer", nil)
+	connection.firstRead = first
+
+	if _, err := connection.ReadMessage(); !errors.Is(err, ErrAuthLate) {
+		t.Fatalf("err = %v, want %v", err, ErrAuthLate)
+	}
+
+	select {
+	case data := <-connection.send:
+		var msg Message
+		if err := json.Unmarshal(data, &msg); err != nil || msg.Type != "error" {
+			t.Fatalf("sent %s, want an error message", data)
+		}
+	default:
+		t.Fatalf("no error message queued")
+	}
+}
@@ -0,0 +1,349 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"time"
+
+	"github.com/pion/webrtc/v3"
+)
+
+// SFU 연결마다 여는 이벤트 채널 이름 (오버레이, 투표, 타임드 메타데이터 등)
+const eventChannelLabel = "events"
+
+// 이벤트 하나의 최대 크기 (data를 JSON으로 인코딩한 바이트 수)
+const maxStreamEventSize = 16 * 1024
+
+// 이벤트 주제 (영문 소문자, 숫자, ., _, -)
+var streamEventTopicPattern = regexp.MustCompile(`^[a-z0-9._-]{1,32}$`)
+
+var (
+	ErrStreamEventNotFound  = errors.New("방송을 찾을 수 없습니다")
+	ErrStreamEventForbidden = errors.New("이벤트를 보낼 권한이 없습니다")
+	ErrStreamEventTopic     = errors.New("이벤트 주제가 올바르지 않습니다")
+	ErrStreamEventTooLarge  = errors.New("이벤트가 너무 큽니다")
+)
+
+// 방송 이벤트 (방송자 또는 매니저가 시청자에게 보냄)
+// rtp_timestamp는 이벤트를 보낸 시점에 해당 시청자가 받는 영상의 RTP 타임스탬프로,
+// 시청자는 수신 중인 영상 타임스탬프와 비교해 화면에 맞춰 표시할 수 있다. (SFU 시청자만)
+type StreamEvent struct {
+	ID           int64       `json:"id"`
+	Topic        string      `json:"topic"`
+	Data         interface{} `json:"data,omitempty"`
+	SenderID     string      `json:"sender_id"`
+	Timestamp    time.Time   `json:"timestamp"`
+	RTPTimestamp *uint32     `json:"rtp_timestamp,omitempty"`
+}
+
+// 시청자 이벤트 채널 생성 (서버가 Offer를 만드는 경우, 순서 없이 신뢰 전송)
+func (w *WebRTCService) createEventChannel(broadcasterID string, viewerID string, pc *webrtc.PeerConnection) error {
+	ordered := false
+	dc, err := pc.CreateDataChannel(eventChannelLabel, &webrtc.DataChannelInit{Ordered: &ordered})
+	if err != nil {
+		return fmt.Errorf("failed to create data channel: %v", err)
+	}
+
+	w.mu.Lock()
+	session := w.sessions[broadcasterID]
+	w.mu.Unlock()
+	if session != nil {
+		session.mu.Lock()
+		if subscriber := session.subscribers[viewerID]; subscriber != nil && subscriber.pc == pc {
+			subscriber.events = dc
+		}
+		session.mu.Unlock()
+	}
+
+	w.attachEventChannel(broadcasterID, viewerID, dc)
+	return nil
+}
+
+// 이벤트 채널 수신 처리 연결
+func (w *WebRTCService) attachEventChannel(broadcasterID string, viewerID string, dc *webrtc.DataChannel) {
+	dc.OnOpen(func() {
+		fmt.Printf("📨 이벤트 채널 열림 (%s, %s)\n", broadcasterID, viewerID)
+	})
+	dc.OnMessage(func(msg webrtc.DataChannelMessage) {
+		w.mu.Lock()
+		onDataMessage := w.onDataMessage
+		w.mu.Unlock()
+
+		if onDataMessage != nil {
+			onDataMessage(broadcasterID, viewerID, msg.Data)
+		}
+	})
+}
+
+// 열린 이벤트 채널로 메시지 전송 (viewer_id가 비어 있으면 방송자, 채널이 없거나 닫혀 있으면 false)
+func (w *WebRTCService) SendData(broadcasterID string, viewerID string, msg *Message) bool {
+	w.mu.Lock()
+	session := w.sessions[broadcasterID]
+	w.mu.Unlock()
+	if session == nil {
+		return false
+	}
+
+	session.mu.RLock()
+	dc := session.events
+	if viewerID != "" {
+		dc = nil
+		if subscriber := session.subscribers[viewerID]; subscriber != nil {
+			dc = subscriber.events
+		}
+	}
+	session.mu.RUnlock()
+
+	return sendOnChannel(dc, msg)
+}
+
+// 시청자들에게 이벤트 전송 후 이벤트 채널로 보내지 못한 시청자 반환
+// 시청자마다 받고 있는 영상 기준의 rtp_timestamp를 넣어 보낸다.
+func (w *WebRTCService) SendEvent(broadcasterID string, viewerIDs []string, event StreamEvent) []string {
+	w.mu.Lock()
+	session := w.sessions[broadcasterID]
+	w.mu.Unlock()
+	if session == nil {
+		return viewerIDs
+	}
+
+	undelivered := make([]string, 0)
+	for _, viewerID := range viewerIDs {
+		session.mu.RLock()
+		subscriber := session.subscribers[viewerID]
+		session.mu.RUnlock()
+		if subscriber == nil {
+			undelivered = append(undelivered, viewerID)
+			continue
+		}
+
+		session.mu.RLock()
+		dc := subscriber.events
+		session.mu.RUnlock()
+
+		viewerEvent := event
+		if timestamp, ok := subscriber.video.mediaTimestamp(); ok {
+			viewerEvent.RTPTimestamp = &timestamp
+		}
+		if !sendOnChannel(dc, &Message{Type: "stream_event", BroadcasterID: broadcasterID, Data: viewerEvent}) {
+			undelivered = append(undelivered, viewerID)
+		}
+	}
+	return undelivered
+}
+
+func sendOnChannel(dc *webrtc.DataChannel, msg *Message) bool {
+	if dc == nil || dc.ReadyState() != webrtc.DataChannelStateOpen {
+		return false
+	}
+
+	data, err := json.Marshal(msg)
+	if err != nil {
+		fmt.Printf("❌ JSON 마샬링 오류: %v\n", err)
+		return false
+	}
+	return dc.SendText(string(data)) == nil
+}
+
+// 메시지 data에서 이벤트 주제 목록 추출 ({"topics":["poll"]}, 비어 있으면 모든 주제)
+func eventTopicsFromMessage(msg *Message) map[string]bool {
+	data, ok := msg.Data.(map[string]interface{})
+	if !ok {
+		return nil
+	}
+	list, ok := data["topics"].([]interface{})
+	if !ok || len(list) == 0 {
+		return nil
+	}
+
+	topics := make(map[string]bool, len(list))
+	for _, topic := range list {
+		if name := toString(topic); streamEventTopicPattern.MatchString(name) {
+			topics[name] = true
+		}
+	}
+	return topics
+}
+
+// 이벤트 발행 (방송자 또는 매니저)
+// SFU 시청자는 이벤트 채널로, 채널이 없는 시청자는 WebSocket으로 받는다. 전송 경로별 시청자 수를 함께 반환한다.
+func (wsService *WebSocketService) PublishStreamEvent(broadcasterID string, senderID string, topic string, data interface{}) (*StreamEvent, map[string]int, error) {
+	if !streamEventTopicPattern.MatchString(topic) {
+		return nil, nil, ErrStreamEventTopic
+	}
+	if raw, err := json.Marshal(data); err != nil || len(raw) > maxStreamEventSize {
+		return nil, nil, ErrStreamEventTooLarge
+	}
+
+	wsService.Mutex.Lock()
+	broadcast := wsService.ActiveBroadcasts[broadcasterID]
+	if broadcast == nil {
+		wsService.Mutex.Unlock()
+		return nil, nil, ErrStreamEventNotFound
+	}
+	if !wsService.canModerate(broadcasterID, senderID) {
+		wsService.Mutex.Unlock()
+		return nil, nil, ErrStreamEventForbidden
+	}
+
+	broadcast.eventSeq++
+	event := StreamEvent{
+		ID:        broadcast.eventSeq,
+		Topic:     topic,
+		Data:      data,
+		SenderID:  senderID,
+		Timestamp: time.Now(),
+	}
+
+	// 주제를 구독한 시청자 (WHEP 시청자는 WebSocket이 없으므로 이벤트 채널로만 받음)
+	viewerIDs := make([]string, 0)
+	fallback := make(map[string]*Connection)
+	for viewerID, viewer := range wsService.Viewers {
+		if viewer.BroadcasterID == broadcasterID && viewer.wantsEvent(topic) {
+			viewerIDs = append(viewerIDs, viewerID)
+			fallback[viewerID] = viewer.Connection
+		}
+	}
+	for viewerID, viewer := range broadcast.whep {
+		if viewer.wantsEvent(topic) {
+			viewerIDs = append(viewerIDs, viewerID)
+		}
+	}
+	sfu := broadcast.Mode == BroadcastModeSFU
+	wsService.Mutex.Unlock()
+
+	undelivered := viewerIDs
+	if sfu {
+		undelivered = wsService.webrtc.SendEvent(broadcasterID, viewerIDs, event)
+	}
+
+	websocketCount := 0
+	message := &Message{Type: "stream_event", BroadcasterID: broadcasterID, Data: event}
+	for _, viewerID := range undelivered {
+		if connection := fallback[viewerID]; connection != nil {
+			wsService.sendToConnection(connection, message)
+			websocketCount++
+		}
+	}
+
+	delivery := map[string]int{
+		"datachannel": len(viewerIDs) - len(undelivered),
+		"websocket":   websocketCount,
+	}
+	fmt.Printf("📣 방송 이벤트 (%s, %s #%d): 이벤트 채널 %d명, WebSocket %d명\n",
+		broadcasterID, topic, event.ID, delivery["datachannel"], delivery["websocket"])
+	return &event, delivery, nil
+}
+
+// 이벤트 주제 구독 여부 (구독 목록이 없으면 모든 주제)
+func (viewer *ViewerInfo) wantsEvent(topic string) bool {
+	return viewer.eventTopics == nil || viewer.eventTopics[topic]
+}
+
+// 이벤트 발행 메시지 처리 ({"type":"stream_event","data":{"topic":"poll","data":{...}}})
+// 결과는 reply로 보낸다. (WebSocket 또는 이벤트 채널)
+func (wsService *WebSocketService) handleStreamEvent(broadcasterID string, senderID string, msg *Message, reply func(*Message)) {
+	data, _ := msg.Data.(map[string]interface{})
+	event, delivery, err := wsService.PublishStreamEvent(broadcasterID, senderID, toString(data["topic"]), data["data"])
+	if err != nil {
+		fmt.Printf("❌ 방송 이벤트 실패 (%s, %s): %v\n", broadcasterID, senderID, err)
+		reply(&Message{
+			Type:          "stream_event_error",
+			BroadcasterID: broadcasterID,
+			Data: map[string]interface{}{
+				"topic": toString(data["topic"]),
+				"error": err.Error(),
+			},
+		})
+		return
+	}
+
+	reply(&Message{
+		Type:          "stream_event_sent",
+		BroadcasterID: broadcasterID,
+		Data: map[string]interface{}{
+			"event":    event,
+			"delivery": delivery,
+		},
+	})
+}
+
+// 시청자 이벤트 주제 구독 변경 (subscribe_events)
+func (wsService *WebSocketService) subscribeEvents(broadcasterID string, viewerID string, msg *Message) map[string]bool {
+	topics := eventTopicsFromMessage(msg)
+
+	wsService.Mutex.Lock()
+	defer wsService.Mutex.Unlock()
+
+	viewer := wsService.Viewers[viewerID]
+	if viewer == nil {
+		if broadcast := wsService.ActiveBroadcasts[broadcasterID]; broadcast != nil {
+			viewer = broadcast.whep[viewerID]
+		}
+	}
+	if viewer != nil {
+		viewer.eventTopics = topics
+	}
+	return topics
+}
+
+// 구독 변경 응답 메시지
+func eventSubscriptionMessage(broadcasterID string, topics map[string]bool) *Message {
+	list := make([]string, 0, len(topics))
+	for topic := range topics {
+		list = append(list, topic)
+	}
+	return &Message{
+		Type:          "events_subscribed",
+		BroadcasterID: broadcasterID,
+		Data: map[string]interface{}{
+			"topics": list,
+			"all":    topics == nil,
+		},
+	}
+}
+
+// 이벤트 채널 메시지 처리 (방송자는 stream_event, 시청자는 subscribe_events, 공통으로 stats_report와 ping)
+func (wsService *WebSocketService) handleDataMessage(broadcasterID string, viewerID string, data []byte) {
+	var msg Message
+	if err := json.Unmarshal(data, &msg); err != nil {
+		fmt.Printf("❌ 이벤트 채널 JSON 파싱 오류 (%s, %s): %v\n", broadcasterID, viewerID, err)
+		return
+	}
+
+	reply := func(response *Message) {
+		wsService.webrtc.SendData(broadcasterID, viewerID, response)
+	}
+
+	switch msg.Type {
+	case "stream_event":
+		if viewerID == "" {
+			wsService.handleStreamEvent(broadcasterID, broadcasterID, &msg, reply)
+			return
+		}
+		// 매니저 시청자는 이벤트 채널로도 발행 가능
+		wsService.Mutex.RLock()
+		senderID := ""
+		if viewer := wsService.Viewers[viewerID]; viewer != nil {
+			senderID = viewer.Connection.UserID
+		}
+		wsService.Mutex.RUnlock()
+		wsService.handleStreamEvent(broadcasterID, senderID, &msg, reply)
+	case "stats_report":
+		if viewerID == "" {
+			wsService.handleStatsReport(broadcasterID, broadcasterID, "broadcaster", &msg)
+		} else {
+			wsService.handleStatsReport(broadcasterID, viewerID, "viewer", &msg)
+		}
+	case "subscribe_events":
+		if viewerID != "" {
+			reply(eventSubscriptionMessage(broadcasterID, wsService.subscribeEvents(broadcasterID, viewerID, &msg)))
+		}
+	case "ping":
+		reply(&Message{Type: "pong", Timestamp: time.Now().Format(time.RFC3339)})
+	default:
+		fmt.Printf("🔄 알 수 없는 이벤트 채널 메시지 (%s, %s): %s\n", broadcasterID, viewerID, msg.Type)
+	}
+}
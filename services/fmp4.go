@@ -0,0 +1,342 @@
+package services
+
+import (
+	"encoding/binary"
+	"errors"
+)
+
+// HLS용 fMP4 박스 작성
+// 초기화 세그먼트(ftyp+moov)와 미디어 세그먼트(moof+mdat)만 만든다.
+
+// 트랙 정보 (초기화 세그먼트용)
+type fmp4Track struct {
+	id        uint32
+	timescale uint32
+	video     bool
+
+	// 영상 (H264)
+	sps    []byte
+	pps    []byte
+	width  int
+	height int
+
+	// 음성 (Opus)
+	channels uint16
+}
+
+// 세그먼트에 들어갈 트랙별 샘플
+type fmp4Fragment struct {
+	trackID    uint32
+	decodeTime uint64
+	samples    []hlsSample
+}
+
+var errInvalidSPS = errors.New("invalid SPS")
+
+func mp4Box(boxType string, payloads ...[]byte) []byte {
+	size := 8
+	for _, payload := range payloads {
+		size += len(payload)
+	}
+
+	box := make([]byte, 0, size)
+	box = binary.BigEndian.AppendUint32(box, uint32(size))
+	box = append(box, boxType...)
+	for _, payload := range payloads {
+		box = append(box, payload...)
+	}
+	return box
+}
+
+func mp4FullBox(boxType string, version byte, flags uint32, payloads ...[]byte) []byte {
+	header := []byte{version, byte(flags >> 16), byte(flags >> 8), byte(flags)}
+	return mp4Box(boxType, append([][]byte{header}, payloads...)...)
+}
+
+func be16(v uint16) []byte { return binary.BigEndian.AppendUint16(nil, v) }
+func be32(v uint32) []byte { return binary.BigEndian.AppendUint32(nil, v) }
+func be64(v uint64) []byte { return binary.BigEndian.AppendUint64(nil, v) }
+
+// 단위 행렬 (tkhd, mvhd)
+var mp4Matrix = []byte{
+	0x00, 0x01, 0x00, 0x00, 0, 0, 0, 0, 0, 0, 0, 0,
+	0, 0, 0, 0, 0x00, 0x01, 0x00, 0x00, 0, 0, 0, 0,
+	0, 0, 0, 0, 0, 0, 0, 0, 0x40, 0x00, 0x00, 0x00,
+}
+
+// 초기화 세그먼트 (ftyp + moov)
+func fmp4InitSegment(tracks []*fmp4Track) []byte {
+	ftyp := mp4Box("ftyp", []byte("iso5"), be32(0), []byte("iso5iso6mp41"))
+
+	mvhd := mp4FullBox("mvhd", 0, 0,
+		be32(0), be32(0), // creation, modification
+		be32(1000), be32(0), // timescale, duration
+		be32(0x00010000), be16(0x0100), make([]byte, 10), // rate, volume, reserved
+		mp4Matrix, make([]byte, 24), // matrix, pre_defined
+		be32(uint32(len(tracks)+1)), // next_track_ID
+	)
+
+	moov := [][]byte{mvhd}
+	var trex [][]byte
+	for _, track := range tracks {
+		moov = append(moov, fmp4Trak(track))
+		trex = append(trex, mp4FullBox("trex", 0, 0, be32(track.id), be32(1), be32(0), be32(0), be32(0)))
+	}
+	moov = append(moov, mp4Box("mvex", trex...))
+
+	return append(ftyp, mp4Box("moov", moov...)...)
+}
+
+func fmp4Trak(track *fmp4Track) []byte {
+	volume, handler, name := uint16(0x0100), "soun", "SoundHandler"
+	var width, height uint32
+	if track.video {
+		volume, handler, name = 0, "vide", "VideoHandler"
+		width, height = uint32(track.width), uint32(track.height)
+	}
+
+	tkhd := mp4FullBox("tkhd", 0, 3,
+		be32(0), be32(0), be32(track.id), be32(0), be32(0), // creation, modification, track_ID, reserved, duration
+		make([]byte, 8), be16(0), be16(0), be16(volume), be16(0), // reserved, layer, alternate_group, volume, reserved
+		mp4Matrix, be32(width<<16), be32(height<<16),
+	)
+
+	mdhd := mp4FullBox("mdhd", 0, 0,
+		be32(0), be32(0), be32(track.timescale), be32(0),
+		be16(0x55c4), be16(0), // language: und
+	)
+	hdlr := mp4FullBox("hdlr", 0, 0, be32(0), []byte(handler), make([]byte, 12), []byte(name+"\x00"))
+
+	var mediaHeader []byte
+	if track.video {
+		mediaHeader = mp4FullBox("vmhd", 0, 1, make([]byte, 8))
+	} else {
+		mediaHeader = mp4FullBox("smhd", 0, 0, make([]byte, 4))
+	}
+	dinf := mp4Box("dinf", mp4FullBox("dref", 0, 0, be32(1), mp4FullBox("url ", 0, 1)))
+
+	stbl := mp4Box("stbl",
+		mp4FullBox("stsd", 0, 0, be32(1), fmp4SampleEntry(track)),
+		mp4FullBox("stts", 0, 0, be32(0)),
+		mp4FullBox("stsc", 0, 0, be32(0)),
+		mp4FullBox("stsz", 0, 0, be32(0), be32(0)),
+		mp4FullBox("stco", 0, 0, be32(0)),
+	)
+
+	minf := mp4Box("minf", mediaHeader, dinf, stbl)
+	return mp4Box("trak", tkhd, mp4Box("mdia", mdhd, hdlr, minf))
+}
+
+func fmp4SampleEntry(track *fmp4Track) []byte {
+	if track.video {
+		avcC := mp4Box("avcC",
+			[]byte{1, track.sps[1], track.sps[2], track.sps[3], 0xFF, 0xE1},
+			be16(uint16(len(track.sps))), track.sps,
+			[]byte{1}, be16(uint16(len(track.pps))), track.pps,
+		)
+		return mp4Box("avc1",
+			make([]byte, 6), be16(1), // reserved, data_reference_index
+			make([]byte, 16), be16(uint16(track.width)), be16(uint16(track.height)),
+			be32(0x00480000), be32(0x00480000), be32(0), be16(1), // resolution, reserved, frame_count
+			make([]byte, 32), be16(0x0018), be16(0xFFFF), // compressorname, depth, pre_defined
+			avcC,
+		)
+	}
+
+	dOps := mp4Box("dOps",
+		[]byte{0, byte(track.channels)}, be16(312), be32(48000), be16(0), []byte{0},
+	)
+	return mp4Box("Opus",
+		make([]byte, 6), be16(1),
+		make([]byte, 8), be16(track.channels), be16(16), be32(0),
+		be32(48000<<16),
+		dOps,
+	)
+}
+
+// 미디어 세그먼트 (moof + mdat)
+func fmp4MediaSegment(sequence uint32, fragments []fmp4Fragment) []byte {
+	// trun의 data_offset은 moof 크기에 따라 달라지므로 크기를 먼저 계산한 뒤 다시 만든다
+	moof := fmp4Moof(sequence, fragments, 0)
+	moof = fmp4Moof(sequence, fragments, uint32(len(moof)+8))
+
+	var mdat [][]byte
+	for _, fragment := range fragments {
+		for _, sample := range fragment.samples {
+			mdat = append(mdat, sample.data)
+		}
+	}
+	return append(moof, mp4Box("mdat", mdat...)...)
+}
+
+func fmp4Moof(sequence uint32, fragments []fmp4Fragment, dataOffset uint32) []byte {
+	boxes := [][]byte{mp4FullBox("mfhd", 0, 0, be32(sequence))}
+
+	for _, fragment := range fragments {
+		var entries []byte
+		size := uint32(0)
+		for _, sample := range fragment.samples {
+			flags := uint32(0x01010000) // 다른 프레임에 의존, 동기화 지점 아님
+			if sample.keyframe {
+				flags = 0x02000000
+			}
+			entries = binary.BigEndian.AppendUint32(entries, sample.duration)
+			entries = binary.BigEndian.AppendUint32(entries, uint32(len(sample.data)))
+			entries = binary.BigEndian.AppendUint32(entries, flags)
+			size += uint32(len(sample.data))
+		}
+
+		boxes = append(boxes, mp4Box("traf",
+			mp4FullBox("tfhd", 0, 0x020000, be32(fragment.trackID)), // default-base-is-moof
+			mp4FullBox("tfdt", 1, 0, be64(fragment.decodeTime)),
+			mp4FullBox("trun", 0, 0x000701, be32(uint32(len(fragment.samples))), be32(dataOffset), entries),
+		))
+		dataOffset += size
+	}
+
+	return mp4Box("moof", boxes...)
+}
+
+// H264 SPS에서 영상 크기 추출
+func parseSPSResolution(sps []byte) (int, int, error) {
+	if len(sps) < 4 {
+		return 0, 0, errInvalidSPS
+	}
+
+	// 에뮬레이션 방지 바이트(0x000003) 제거
+	rbsp := make([]byte, 0, len(sps))
+	for i := 0; i < len(sps); i++ {
+		if i >= 2 && sps[i] == 3 && sps[i-1] == 0 && sps[i-2] == 0 {
+			continue
+		}
+		rbsp = append(rbsp, sps[i])
+	}
+
+	r := &bitReader{data: rbsp[1:]}
+	profile := r.bits(8)
+	r.bits(16) // constraint flags, level
+	r.ue()     // seq_parameter_set_id
+
+	chromaFormat := uint32(1)
+	switch profile {
+	case 100, 110, 122, 244, 44, 83, 86, 118, 128, 138, 139, 134, 135:
+		chromaFormat = r.ue()
+		if chromaFormat == 3 {
+			r.bits(1) // separate_colour_plane_flag
+		}
+		r.ue()              // bit_depth_luma_minus8
+		r.ue()              // bit_depth_chroma_minus8
+		r.bits(1)           // qpprime_y_zero_transform_bypass_flag
+		if r.bits(1) == 1 { // seq_scaling_matrix_present_flag
+			count := 8
+			if chromaFormat == 3 {
+				count = 12
+			}
+			for i := 0; i < count; i++ {
+				if r.bits(1) == 1 {
+					size := 16
+					if i >= 6 {
+						size = 64
+					}
+					r.skipScalingList(size)
+				}
+			}
+		}
+	}
+
+	r.ue()          // log2_max_frame_num_minus4
+	switch r.ue() { // pic_order_cnt_type
+	case 0:
+		r.ue() // log2_max_pic_order_cnt_lsb_minus4
+	case 1:
+		r.bits(1) // delta_pic_order_always_zero_flag
+		r.se()    // offset_for_non_ref_pic
+		r.se()    // offset_for_top_to_bottom_field
+		for i := r.ue(); i > 0 && r.err == nil; i-- {
+			r.se()
+		}
+	}
+	r.ue()    // max_num_ref_frames
+	r.bits(1) // gaps_in_frame_num_value_allowed_flag
+
+	widthInMbs := r.ue() + 1
+	heightInMapUnits := r.ue() + 1
+	frameMbsOnly := r.bits(1)
+	if frameMbsOnly == 0 {
+		r.bits(1) // mb_adaptive_frame_field_flag
+	}
+	r.bits(1) // direct_8x8_inference_flag
+
+	width := int(widthInMbs) * 16
+	height := int(2-frameMbsOnly) * int(heightInMapUnits) * 16
+	if r.bits(1) == 1 { // frame_cropping_flag
+		left, right, top, bottom := r.ue(), r.ue(), r.ue(), r.ue()
+		cropX, cropY := 1, 2-int(frameMbsOnly)
+		if chromaFormat == 1 || chromaFormat == 2 {
+			cropX = 2
+		}
+		if chromaFormat == 1 {
+			cropY *= 2
+		}
+		width -= cropX * int(left+right)
+		height -= cropY * int(top+bottom)
+	}
+
+	if r.err != nil || width <= 0 || height <= 0 {
+		return 0, 0, errInvalidSPS
+	}
+	return width, height, nil
+}
+
+// Exp-Golomb 비트 읽기
+type bitReader struct {
+	data []byte
+	pos  int
+	err  error
+}
+
+func (r *bitReader) bits(n int) uint32 {
+	var v uint32
+	for i := 0; i < n; i++ {
+		if r.pos >= len(r.data)*8 {
+			r.err = errInvalidSPS
+			return 0
+		}
+		bit := (r.data[r.pos/8] >> (7 - uint(r.pos%8))) & 1
+		v = v<<1 | uint32(bit)
+		r.pos++
+	}
+	return v
+}
+
+func (r *bitReader) ue() uint32 {
+	zeros := 0
+	for r.bits(1) == 0 {
+		if r.err != nil || zeros > 31 {
+			r.err = errInvalidSPS
+			return 0
+		}
+		zeros++
+	}
+	return (1<<uint(zeros) - 1) + r.bits(zeros)
+}
+
+func (r *bitReader) se() int32 {
+	v := r.ue()
+	if v%2 == 1 {
+		return int32((v + 1) / 2)
+	}
+	return -int32(v / 2)
+}
+
+func (r *bitReader) skipScalingList(size int) {
+	last, next := int32(8), int32(8)
+	for i := 0; i < size && r.err == nil; i++ {
+		if next != 0 {
+			next = (last + r.se() + 256) % 256
+		}
+		if next != 0 {
+			last = next
+		}
+	}
+}
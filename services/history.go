@@ -0,0 +1,214 @@
+package services
+
+import (
+	"database/sql"
+	"fmt"
+	"strconv"
+	"time"
+	"toysgo/global"
+	"toysgo/models"
+)
+
+// 방송 기록 저장 큐
+// DB 작업은 서비스 뮤텍스 밖에서 하나의 고루틴이 순서대로 처리한다.
+// (방송 insert가 끝난 뒤 시청 기록 insert가 실행되므로 방송 ID를 그대로 참조할 수 있음)
+type historyRecorder struct {
+	jobs chan func(conn *sql.DB)
+}
+
+func newHistoryRecorder() *historyRecorder {
+	recorder := &historyRecorder{
+		jobs: make(chan func(conn *sql.DB), 1024),
+	}
+	go recorder.run()
+	return recorder
+}
+
+func (r *historyRecorder) run() {
+	conn := models.NewConnection()
+	if conn == nil {
+		fmt.Printf("❌ 방송 기록 DB 연결 실패\n")
+		return
+	}
+	defer conn.Close()
+
+	// 이전 프로세스에서 종료 기록 없이 남은 방송/시청 기록 정리
+	date := global.GetDate(time.Now())
+	if err := models.NewBroadcastManager(conn).CloseLive(date); err != nil {
+		fmt.Printf("❌ 미종료 방송 기록 정리 실패: %v\n", err)
+	}
+	if err := models.NewBroadcastViewerManager(conn).CloseOpen(date); err != nil {
+		fmt.Printf("❌ 미종료 시청 기록 정리 실패: %v\n", err)
+	}
+	if err := models.NewRecordingManager(conn).CloseRecording(date); err != nil {
+		fmt.Printf("❌ 미종료 녹화 기록 정리 실패: %v\n", err)
+	}
+
+	for job := range r.jobs {
+		job(conn)
+	}
+}
+
+func (r *historyRecorder) enqueue(job func(conn *sql.DB)) {
+	select {
+	case r.jobs <- job:
+	default:
+		fmt.Printf("⚠️ 방송 기록 큐가 가득 차 기록을 건너뜀\n")
+	}
+}
+
+// 사용자 ID를 DB ID로 변환 (익명 사용자는 0)
+func userIDToInt(userID string) int64 {
+	id, err := strconv.ParseInt(userID, 10, 64)
+	if err != nil {
+		return 0
+	}
+	return id
+}
+
+// 방송 시작 기록 (호출자가 잠금을 보유해야 함)
+func (wsService *WebSocketService) recordBroadcastStart(broadcast *BroadcastInfo) {
+	record := &models.Broadcast{
+		User:      userIDToInt(broadcast.BroadcasterID),
+		Name:      broadcast.BroadcasterName,
+		Title:     broadcast.Title,
+		Category:   broadcast.Category,
+		Visibility: broadcast.visibility(),
+		Startdate:  global.GetDate(broadcast.StartTime),
+		Status:     models.BroadcastStatusLive,
+	}
+	broadcast.record = record
+
+	wsService.history.enqueue(func(conn *sql.DB) {
+		manager := models.NewBroadcastManager(conn)
+		if err := manager.Insert(record); err != nil {
+			fmt.Printf("❌ 방송 기록 저장 실패: %v\n", err)
+			return
+		}
+		record.Id = manager.GetIdentity()
+	})
+}
+
+// 방송 종료 기록 (호출자가 잠금을 보유해야 함)
+func (wsService *WebSocketService) recordBroadcastEnd(broadcast *BroadcastInfo) {
+	record := broadcast.record
+	if record == nil {
+		return
+	}
+
+	now := time.Now()
+	enddate := global.GetDate(now)
+	duration := int(now.Sub(broadcast.StartTime).Seconds())
+	peak := broadcast.peakViewers
+	total := broadcast.totalViewers
+	title := broadcast.Title
+	category := broadcast.Category
+	visibility := broadcast.visibility()
+
+	wsService.history.enqueue(func(conn *sql.DB) {
+		if record.Id == 0 {
+			return
+		}
+		record.Title = title
+		record.Category = category
+		record.Visibility = visibility
+		record.Enddate = enddate
+		record.Duration = duration
+		record.Peakviewers = peak
+		record.Totalviewers = total
+		record.Status = models.BroadcastStatusEnded
+
+		if err := models.NewBroadcastManager(conn).Update(record); err != nil {
+			fmt.Printf("❌ 방송 종료 기록 실패: %v\n", err)
+		}
+	})
+}
+
+// 방송 중 제목, 카테고리, 공개 범위 변경 기록 (호출자가 잠금을 보유해야 함)
+// 비공개로 바꾼 방송이 기록 목록에 남지 않도록 종료 전에도 반영한다.
+func (wsService *WebSocketService) recordBroadcastUpdate(broadcast *BroadcastInfo) {
+	record := broadcast.record
+	if record == nil {
+		return
+	}
+
+	title := broadcast.Title
+	category := broadcast.Category
+	visibility := broadcast.visibility()
+
+	wsService.history.enqueue(func(conn *sql.DB) {
+		if record.Id == 0 {
+			return
+		}
+		record.Title = title
+		record.Category = category
+		record.Visibility = visibility
+
+		if err := models.NewBroadcastManager(conn).Update(record); err != nil {
+			fmt.Printf("❌ 방송 정보 기록 실패: %v\n", err)
+		}
+	})
+}
+
+// 시청 시작 기록 (호출자가 잠금을 보유해야 함)
+func (wsService *WebSocketService) recordViewerJoin(viewer *ViewerInfo, broadcast *BroadcastInfo) {
+	// 다른 방송을 보던 중이면 이전 기록을 먼저 종료
+	wsService.recordViewerLeave(viewer)
+
+	broadcast.totalViewers++
+	if count := wsService.countViewers(broadcast.BroadcasterID); count > broadcast.peakViewers {
+		broadcast.peakViewers = count
+	}
+
+	broadcastRecord := broadcast.record
+	if broadcastRecord == nil {
+		return
+	}
+
+	record := &models.BroadcastViewer{
+		User:     userIDToInt(viewer.Connection.UserID),
+		Name:     viewer.Connection.Name,
+		Joindate: global.GetDate(viewer.JoinTime),
+	}
+	viewer.attendance = record
+	viewer.attendanceStart = viewer.JoinTime
+
+	wsService.history.enqueue(func(conn *sql.DB) {
+		if broadcastRecord.Id == 0 {
+			return
+		}
+		record.Broadcast = broadcastRecord.Id
+
+		manager := models.NewBroadcastViewerManager(conn)
+		if err := manager.Insert(record); err != nil {
+			fmt.Printf("❌ 시청 기록 저장 실패: %v\n", err)
+			return
+		}
+		record.Id = manager.GetIdentity()
+	})
+}
+
+// 시청 종료 기록 (호출자가 잠금을 보유해야 함)
+func (wsService *WebSocketService) recordViewerLeave(viewer *ViewerInfo) {
+	record := viewer.attendance
+	if record == nil {
+		return
+	}
+	viewer.attendance = nil
+
+	now := time.Now()
+	leavedate := global.GetDate(now)
+	duration := int(now.Sub(viewer.attendanceStart).Seconds())
+
+	wsService.history.enqueue(func(conn *sql.DB) {
+		if record.Id == 0 {
+			return
+		}
+		record.Leavedate = leavedate
+		record.Duration = duration
+
+		if err := models.NewBroadcastViewerManager(conn).Update(record); err != nil {
+			fmt.Printf("❌ 시청 종료 기록 실패: %v\n", err)
+		}
+	})
+}
@@ -0,0 +1,504 @@
+package services
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"strings"
+	"sync"
+	"toysgo/config"
+
+	"github.com/pion/rtp"
+	"github.com/pion/rtp/codecs"
+	"github.com/pion/webrtc/v3"
+	"github.com/pion/webrtc/v3/pkg/media/samplebuilder"
+)
+
+// HLS 출력 (SFU로 들어온 방송을 fMP4 세그먼트와 롤링 재생 목록으로 패키징)
+// ffmpeg 없이 H264와 Opus를 그대로 감싸므로 방송자가 H264로 송출해야 한다.
+// (WebRTC로는 AAC가 들어오지 않으므로 음성은 Opus를 사용)
+
+const (
+	hlsVideoTimescale = 90000
+	hlsAudioTimescale = 48000
+	hlsVideoTrackID   = 1
+	hlsAudioTrackID   = 2
+
+	// 영상 없이 음성만 이만큼 들어오면 음성 전용으로 시작 (약 1초)
+	hlsAudioOnlyWait = 50
+	// 재생 목록에서 빠진 세그먼트를 잠시 더 보관 (늦게 요청하는 플레이어용)
+	hlsKeepSegments = 3
+
+	hlsPlaylistName = "index.m3u8"
+)
+
+// 패키징할 샘플 하나
+type hlsSample struct {
+	data      []byte
+	timestamp uint32
+	duration  uint32
+	keyframe  bool
+}
+
+// 트랙별 패키징 상태
+type hlsTrack struct {
+	timescale uint32
+	builder   *samplebuilder.SampleBuilder
+	ssrc      webrtc.SSRC
+
+	// 길이가 아직 정해지지 않은 마지막 샘플 (다음 샘플의 타임스탬프로 계산)
+	pending *hlsSample
+	// 현재 세그먼트의 샘플과 첫 샘플의 디코드 시각
+	samples    []hlsSample
+	decodeTime uint64
+	// 시작 전에 버린 샘플 수
+	skipped int
+}
+
+// 재생 목록에 올라간 세그먼트
+type hlsSegment struct {
+	sequence         int
+	name             string
+	init             string
+	duration         float64
+	discontinuity    bool
+	discontinuitySeq int
+}
+
+// 방송 하나의 HLS 패키저
+type hlsPackager struct {
+	broadcasterID string
+
+	mu       sync.Mutex
+	video    *hlsTrack
+	audio    *hlsTrack
+	sps      []byte
+	pps      []byte
+	started  bool
+	disabled bool
+
+	initName         string
+	initCount        int
+	discontinuity    bool
+	discontinuitySeq int
+	sequence         int
+	segments         []*hlsSegment
+	expired          []*hlsSegment
+	files            map[string][]byte
+
+	// 첫 세그먼트가 만들어지면 한 번 호출
+	onReady func(broadcasterID string)
+	ready   bool
+}
+
+func newHLSPackager(broadcasterID string, onReady func(broadcasterID string)) *hlsPackager {
+	return &hlsPackager{
+		broadcasterID: broadcasterID,
+		files:         make(map[string][]byte),
+		onReady:       onReady,
+	}
+}
+
+// 재생 목록 URL
+func hlsPlaylistURL(broadcasterID string) string {
+	return "/hls/" + broadcasterID + "/" + hlsPlaylistName
+}
+
+// 방송자 패킷 추가
+func (p *hlsPackager) push(kind webrtc.RTPCodecType, mimeType string, packet *rtp.Packet) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if !p.disabled {
+		p.pushPacket(kind, mimeType, packet)
+	}
+}
+
+// (호출자가 잠금을 보유해야 함)
+func (p *hlsPackager) pushPacket(kind webrtc.RTPCodecType, mimeType string, packet *rtp.Packet) {
+	var track *hlsTrack
+	switch kind {
+	case webrtc.RTPCodecTypeVideo:
+		if mimeType != webrtc.MimeTypeH264 {
+			fmt.Printf("⚠️ HLS는 H264 영상만 지원 (%s): %s\n", p.broadcasterID, mimeType)
+			p.disabled = true
+			return
+		}
+		if p.video == nil {
+			p.restartIfStarted()
+			p.video = &hlsTrack{
+				timescale: hlsVideoTimescale,
+				builder:   samplebuilder.New(512, &codecs.H264Packet{IsAVC: true}, hlsVideoTimescale),
+			}
+		}
+		track = p.video
+	case webrtc.RTPCodecTypeAudio:
+		if mimeType != webrtc.MimeTypeOpus {
+			return
+		}
+		if p.audio == nil {
+			p.restartIfStarted()
+			p.audio = &hlsTrack{
+				timescale: hlsAudioTimescale,
+				builder:   samplebuilder.New(64, &codecs.OpusPacket{}, hlsAudioTimescale),
+			}
+		}
+		track = p.audio
+	default:
+		return
+	}
+
+	// 재송출로 SSRC가 바뀌면 타임스탬프가 이어지지 않으므로 새 초기화 세그먼트부터 다시 시작
+	if ssrc := webrtc.SSRC(packet.SSRC); ssrc != track.ssrc {
+		if track.ssrc != 0 {
+			p.restart()
+			p.pushPacket(kind, mimeType, packet)
+			return
+		}
+		track.ssrc = ssrc
+	}
+
+	track.builder.Push(packet)
+	for sample := track.builder.Pop(); sample != nil; sample = track.builder.Pop() {
+		p.addSample(kind == webrtc.RTPCodecTypeVideo, track, &hlsSample{
+			data:      sample.Data,
+			timestamp: sample.PacketTimestamp,
+		})
+	}
+}
+
+// 완성된 샘플 추가 (호출자가 잠금을 보유해야 함)
+func (p *hlsPackager) addSample(video bool, track *hlsTrack, sample *hlsSample) {
+	if video {
+		sample.keyframe = p.scanNALUs(sample.data)
+	} else {
+		sample.keyframe = true
+	}
+
+	// 직전 샘플의 길이를 확정해 현재 세그먼트에 넣음
+	if previous := track.pending; previous != nil {
+		previous.duration = sample.timestamp - previous.timestamp
+		track.samples = append(track.samples, *previous)
+		track.pending = nil
+	}
+
+	if !p.started {
+		switch {
+		case video && sample.keyframe && p.sps != nil && p.pps != nil:
+			p.start()
+		case !video && p.video == nil:
+			if track.skipped++; track.skipped >= hlsAudioOnlyWait {
+				p.start()
+			}
+		}
+		if !p.started {
+			track.samples = nil
+			return
+		}
+	}
+
+	// 영상은 키프레임에서, 음성 전용이면 아무 샘플에서나 세그먼트를 자름
+	if (video && sample.keyframe) || (!video && p.video == nil) {
+		if p.bufferedDuration() >= float64(config.HLSSegmentDuration) {
+			p.cutSegment()
+		}
+	}
+
+	track.pending = sample
+}
+
+// NAL 단위를 훑어 SPS/PPS를 저장하고 키프레임 여부 반환 (호출자가 잠금을 보유해야 함)
+func (p *hlsPackager) scanNALUs(data []byte) bool {
+	keyframe := false
+	for len(data) >= 4 {
+		size := int(binary.BigEndian.Uint32(data))
+		data = data[4:]
+		if size <= 0 || size > len(data) {
+			break
+		}
+
+		nalu := data[:size]
+		switch nalu[0] & 0x1F {
+		case 5:
+			keyframe = true
+		case 7:
+			p.sps = append([]byte{}, nalu...)
+		case 8:
+			p.pps = append([]byte{}, nalu...)
+		}
+		data = data[size:]
+	}
+	return keyframe
+}
+
+// 첫 세그먼트 시작 (그 전에 들어온 샘플은 버림, 호출자가 잠금을 보유해야 함)
+func (p *hlsPackager) start() {
+	p.started = true
+	for _, track := range []*hlsTrack{p.video, p.audio} {
+		if track != nil {
+			track.pending = nil
+			track.samples = nil
+			track.decodeTime = 0
+		}
+	}
+}
+
+// 현재 세그먼트 길이 (초, 호출자가 잠금을 보유해야 함)
+func (p *hlsPackager) bufferedDuration() float64 {
+	track := p.video
+	if track == nil {
+		track = p.audio
+	}
+	if track == nil {
+		return 0
+	}
+
+	total := uint64(0)
+	for _, sample := range track.samples {
+		total += uint64(sample.duration)
+	}
+	return float64(total) / float64(track.timescale)
+}
+
+// 모인 샘플로 세그먼트를 만들고 재생 목록 갱신 (호출자가 잠금을 보유해야 함)
+func (p *hlsPackager) cutSegment() {
+	duration := p.bufferedDuration()
+	if duration <= 0 {
+		return
+	}
+
+	// 트랙 구성이 정해졌으므로 초기화 세그먼트 생성
+	if p.initName == "" {
+		var tracks []*fmp4Track
+		if p.video != nil {
+			width, height, err := parseSPSResolution(p.sps)
+			if err != nil {
+				fmt.Printf("⚠️ HLS SPS 해석 실패 (%s): %v\n", p.broadcasterID, err)
+			}
+			tracks = append(tracks, &fmp4Track{
+				id:        hlsVideoTrackID,
+				timescale: hlsVideoTimescale,
+				video:     true,
+				sps:       p.sps,
+				pps:       p.pps,
+				width:     width,
+				height:    height,
+			})
+		}
+		if p.audio != nil {
+			tracks = append(tracks, &fmp4Track{
+				id:        hlsAudioTrackID,
+				timescale: hlsAudioTimescale,
+				channels:  2,
+			})
+		}
+
+		p.initName = fmt.Sprintf("init_%d.mp4", p.initCount)
+		p.initCount++
+		p.files[p.initName] = fmp4InitSegment(tracks)
+	}
+
+	var fragments []fmp4Fragment
+	for _, entry := range []struct {
+		id    uint32
+		track *hlsTrack
+	}{{hlsVideoTrackID, p.video}, {hlsAudioTrackID, p.audio}} {
+		track := entry.track
+		if track == nil || len(track.samples) == 0 {
+			continue
+		}
+		fragments = append(fragments, fmp4Fragment{
+			trackID:    entry.id,
+			decodeTime: track.decodeTime,
+			samples:    track.samples,
+		})
+		for _, sample := range track.samples {
+			track.decodeTime += uint64(sample.duration)
+		}
+		track.samples = nil
+	}
+
+	segment := &hlsSegment{
+		sequence:      p.sequence,
+		name:          fmt.Sprintf("segment_%d.m4s", p.sequence),
+		init:          p.initName,
+		duration:      duration,
+		discontinuity: p.discontinuity,
+	}
+	if p.discontinuity {
+		p.discontinuitySeq++
+		p.discontinuity = false
+	}
+	segment.discontinuitySeq = p.discontinuitySeq
+	p.sequence++
+
+	p.files[segment.name] = fmp4MediaSegment(uint32(segment.sequence+1), fragments)
+	p.segments = append(p.segments, segment)
+
+	// 오래된 세그먼트 정리
+	for len(p.segments) > config.HLSPlaylistSize {
+		p.expired = append(p.expired, p.segments[0])
+		p.segments = p.segments[1:]
+	}
+	for len(p.expired) > hlsKeepSegments {
+		delete(p.files, p.expired[0].name)
+		p.expired = p.expired[1:]
+	}
+	p.removeUnusedInits()
+
+	p.files[hlsPlaylistName] = []byte(p.playlist())
+
+	if !p.ready {
+		p.ready = true
+		fmt.Printf("📺 HLS 출력 시작: %s\n", p.broadcasterID)
+		if p.onReady != nil {
+			go p.onReady(p.broadcasterID)
+		}
+	}
+}
+
+// 더 이상 참조하지 않는 초기화 세그먼트 삭제 (호출자가 잠금을 보유해야 함)
+func (p *hlsPackager) removeUnusedInits() {
+	used := map[string]bool{p.initName: true}
+	for _, segment := range append(p.expired, p.segments...) {
+		used[segment.init] = true
+	}
+	for name := range p.files {
+		if strings.HasPrefix(name, "init_") && !used[name] {
+			delete(p.files, name)
+		}
+	}
+}
+
+// 재송출 시 남은 샘플을 세그먼트로 내보내고 트랙 상태 초기화 (호출자가 잠금을 보유해야 함)
+func (p *hlsPackager) restart() {
+	if p.started {
+		for _, track := range []*hlsTrack{p.video, p.audio} {
+			if track != nil && track.pending != nil {
+				// 다음 샘플이 없으므로 직전 샘플 길이로 대신함
+				if count := len(track.samples); count > 0 {
+					track.pending.duration = track.samples[count-1].duration
+				}
+				track.samples = append(track.samples, *track.pending)
+				track.pending = nil
+			}
+		}
+		p.cutSegment()
+	}
+
+	p.video = nil
+	p.audio = nil
+	p.sps = nil
+	p.pps = nil
+	p.started = false
+	p.initName = ""
+	p.discontinuity = len(p.segments) > 0
+}
+
+// 시작한 뒤에 트랙이 새로 생기면 트랙 구성이 달라지므로 다시 시작 (호출자가 잠금을 보유해야 함)
+func (p *hlsPackager) restartIfStarted() {
+	if p.started {
+		p.restart()
+	}
+}
+
+// 롤링 재생 목록 생성 (호출자가 잠금을 보유해야 함)
+func (p *hlsPackager) playlist() string {
+	target := 1.0
+	for _, segment := range p.segments {
+		target = math.Max(target, math.Ceil(segment.duration))
+	}
+
+	var b strings.Builder
+	b.WriteString("#EXTM3U\n")
+	b.WriteString("#EXT-X-VERSION:7\n")
+	fmt.Fprintf(&b, "#EXT-X-TARGETDURATION:%d\n", int(target))
+	if len(p.segments) > 0 {
+		fmt.Fprintf(&b, "#EXT-X-MEDIA-SEQUENCE:%d\n", p.segments[0].sequence)
+		fmt.Fprintf(&b, "#EXT-X-DISCONTINUITY-SEQUENCE:%d\n", p.segments[0].discontinuitySeq)
+	}
+
+	init := ""
+	for i, segment := range p.segments {
+		if i > 0 && segment.discontinuity {
+			b.WriteString("#EXT-X-DISCONTINUITY\n")
+		}
+		if segment.init != init {
+			init = segment.init
+			fmt.Fprintf(&b, "#EXT-X-MAP:URI=\"%s\"\n", init)
+		}
+		fmt.Fprintf(&b, "#EXTINF:%.3f,\n%s\n", segment.duration, segment.name)
+	}
+	return b.String()
+}
+
+// 파일 내용 조회
+func (p *hlsPackager) file(name string) ([]byte, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	data, ok := p.files[name]
+	return data, ok
+}
+
+// HLS 파일 조회 (재생 목록, 초기화 세그먼트, 미디어 세그먼트)
+func (w *WebRTCService) HLSFile(broadcasterID string, name string) ([]byte, bool) {
+	w.mu.Lock()
+	session := w.sessions[broadcasterID]
+	w.mu.Unlock()
+	if session == nil {
+		return nil, false
+	}
+
+	_, hls := session.outputs()
+	if hls == nil {
+		return nil, false
+	}
+	return hls.file(name)
+}
+
+// 공개 범위에 맞게 HLS 주소 갱신 (비공개 방송은 접근 확인을 거치지 않는 HLS를 노출하지 않음)
+func (broadcast *BroadcastInfo) refreshHLSURL() {
+	if broadcast.hlsReady && broadcast.Visibility != BroadcastVisibilityPrivate {
+		broadcast.HLSURL = hlsPlaylistURL(broadcast.BroadcasterID)
+	} else {
+		broadcast.HLSURL = ""
+	}
+}
+
+// 첫 HLS 세그먼트 준비 알림 처리
+func (wsService *WebSocketService) handleHLSReady(broadcasterID string) {
+	wsService.Mutex.Lock()
+	defer wsService.Mutex.Unlock()
+
+	broadcast := wsService.ActiveBroadcasts[broadcasterID]
+	if broadcast == nil || broadcast.Mode != BroadcastModeSFU {
+		return
+	}
+	broadcast.hlsReady = true
+	broadcast.refreshHLSURL()
+	if broadcast.HLSURL == "" {
+		return
+	}
+
+	updatedMsg := &Message{
+		Type:          "broadcast_updated",
+		BroadcasterID: broadcasterID,
+		Broadcast:     broadcast,
+	}
+	wsService.sendToBroadcastAudience(broadcasterID, updatedMsg)
+	wsService.broadcastToListSubscribers(updatedMsg)
+}
+
+// HLS 파일 조회 (API용, 비공개 방송은 제공하지 않음)
+func (wsService *WebSocketService) GetHLSFile(broadcasterID string, name string) ([]byte, bool) {
+	wsService.Mutex.RLock()
+	broadcast := wsService.ActiveBroadcasts[broadcasterID]
+	available := broadcast != nil && broadcast.HLSURL != ""
+	wsService.Mutex.RUnlock()
+	if !available {
+		return nil, false
+	}
+
+	return wsService.webrtc.HLSFile(broadcasterID, name)
+}
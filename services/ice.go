@@ -0,0 +1,93 @@
+package services
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/base64"
+	"fmt"
+	"time"
+	"toysgo/config"
+
+	"github.com/pion/webrtc/v3"
+)
+
+// 클라이언트에 전달하는 ICE 서버 (RTCIceServer 형식)
+type ICEServer struct {
+	URLs       []string `json:"urls"`
+	Username   string   `json:"username,omitempty"`
+	Credential string   `json:"credential,omitempty"`
+}
+
+// ICE 서버 목록과 TURN 자격 증명 만료 시각
+// TURN 공유 비밀키가 있으면 사용자별 임시 자격 증명을 발급하고, 없으면 만료 시각은 0이다.
+func ICEServers(userID string) ([]ICEServer, time.Time) {
+	servers := make([]ICEServer, 0, len(config.ICEServers)+1)
+	for _, server := range config.ICEServers {
+		if len(server.URLs) == 0 {
+			continue
+		}
+		servers = append(servers, ICEServer{
+			URLs:       server.URLs,
+			Username:   server.Username,
+			Credential: server.Credential,
+		})
+	}
+
+	var expires time.Time
+	if len(config.TURNURLs) > 0 && config.TURNSecret != "" {
+		var username, credential string
+		username, credential, expires = turnCredentials(userID, time.Duration(config.TURNCredentialTTL)*time.Second)
+		servers = append(servers, ICEServer{
+			URLs:       config.TURNURLs,
+			Username:   username,
+			Credential: credential,
+		})
+	}
+
+	return servers, expires
+}
+
+// TURN REST API 자격 증명 (username은 "만료시각:사용자", credential은 HMAC-SHA1의 base64)
+func turnCredentials(userID string, ttl time.Duration) (string, string, time.Time) {
+	if ttl <= 0 {
+		ttl = 24 * time.Hour
+	}
+	expires := time.Now().Add(ttl)
+	username := fmt.Sprintf("%d:%s", expires.Unix(), userID)
+	return username, turnCredential(username), expires
+}
+
+// TURN 임시 자격 증명의 password (username의 HMAC-SHA1)
+func turnCredential(username string) string {
+	mac := hmac.New(sha1.New, []byte(config.TURNSecret))
+	mac.Write([]byte(username))
+	return base64.StdEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// 서버 PeerConnection용 ICE 서버 목록 (클라이언트와 같은 서버 사용)
+func webrtcICEServers() []webrtc.ICEServer {
+	servers, _ := ICEServers("server")
+
+	iceServers := make([]webrtc.ICEServer, 0, len(servers))
+	for _, server := range servers {
+		iceServer := webrtc.ICEServer{URLs: server.URLs}
+		if server.Username != "" {
+			iceServer.Username = server.Username
+			iceServer.Credential = server.Credential
+			iceServer.CredentialType = webrtc.ICECredentialTypePassword
+		}
+		iceServers = append(iceServers, iceServer)
+	}
+	return iceServers
+}
+
+// 메시지 data에 ICE 설정 추가 (ice_servers, 임시 자격 증명이면 ice_expires_at)
+func addICEConfiguration(data map[string]interface{}, userID string) map[string]interface{} {
+	servers, expires := ICEServers(userID)
+
+	data["ice_servers"] = servers
+	if !expires.IsZero() {
+		data["ice_expires_at"] = expires.Format(time.RFC3339)
+	}
+	return data
+}
@@ -0,0 +1,217 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strings"
+	"unicode/utf8"
+)
+
+// 메타데이터 길이 제한
+const (
+	maxTitleLength       = 100
+	maxDescriptionLength = 2000
+	maxCategoryLength    = 50
+	maxTagCount          = 10
+	maxTagLength         = 30
+	maxLanguageLength    = 16
+	maxThumbnailLength   = 500
+)
+
+// 방송 메타데이터
+type BroadcastMetadata struct {
+	Title        string   `json:"title"`
+	Description  string   `json:"description"`
+	Category     string   `json:"category"`
+	Tags         []string `json:"tags"`
+	Language     string   `json:"language"`
+	ThumbnailURL string   `json:"thumbnail_url"`
+}
+
+// 부분 수정용 메타데이터 (전달된 필드만 변경)
+type broadcastMetadataUpdate struct {
+	Title        *string   `json:"title"`
+	Description  *string   `json:"description"`
+	Category     *string   `json:"category"`
+	Tags         *[]string `json:"tags"`
+	Language     *string   `json:"language"`
+	ThumbnailURL *string   `json:"thumbnail_url"`
+}
+
+// 목록 필터
+type BroadcastFilter struct {
+	Category string
+	Tag      string
+	Language string
+}
+
+// 메시지 data 필드에서 메타데이터 수정 내용 추출
+func parseMetadataUpdate(data interface{}) (*broadcastMetadataUpdate, error) {
+	update := &broadcastMetadataUpdate{}
+	if data == nil {
+		return update, nil
+	}
+
+	raw, err := json.Marshal(data)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(raw, update); err != nil {
+		return nil, fmt.Errorf("메타데이터 형식이 올바르지 않습니다")
+	}
+	return update, nil
+}
+
+func truncate(value string, limit int) string {
+	value = strings.TrimSpace(value)
+	if utf8.RuneCountInString(value) <= limit {
+		return value
+	}
+	return string([]rune(value)[:limit])
+}
+
+// 태그 정규화 (소문자, 공백 제거, 중복 제거)
+func normalizeTags(tags []string) []string {
+	result := make([]string, 0, len(tags))
+	seen := make(map[string]bool)
+	for _, tag := range tags {
+		tag = strings.ToLower(truncate(strings.TrimPrefix(strings.TrimSpace(tag), "#"), maxTagLength))
+		if tag == "" || seen[tag] {
+			continue
+		}
+		seen[tag] = true
+		result = append(result, tag)
+		if len(result) >= maxTagCount {
+			break
+		}
+	}
+	return result
+}
+
+// 메타데이터 적용
+func (m *BroadcastMetadata) apply(update *broadcastMetadataUpdate) error {
+	if update.ThumbnailURL != nil {
+		thumbnail := strings.TrimSpace(*update.ThumbnailURL)
+		if thumbnail != "" {
+			parsed, err := url.Parse(thumbnail)
+			if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || len(thumbnail) > maxThumbnailLength {
+				return fmt.Errorf("썸네일 URL이 올바르지 않습니다")
+			}
+		}
+		m.ThumbnailURL = thumbnail
+	}
+	if update.Title != nil {
+		m.Title = truncate(*update.Title, maxTitleLength)
+	}
+	if update.Description != nil {
+		m.Description = truncate(*update.Description, maxDescriptionLength)
+	}
+	if update.Category != nil {
+		m.Category = strings.ToLower(truncate(*update.Category, maxCategoryLength))
+	}
+	if update.Tags != nil {
+		m.Tags = normalizeTags(*update.Tags)
+	}
+	if update.Language != nil {
+		m.Language = strings.ToLower(truncate(*update.Language, maxLanguageLength))
+	}
+	if m.Tags == nil {
+		m.Tags = []string{}
+	}
+	return nil
+}
+
+// 필터 조건 일치 여부
+func (f BroadcastFilter) matches(broadcast *BroadcastInfo) bool {
+	if f.Category != "" && !strings.EqualFold(broadcast.Category, f.Category) {
+		return false
+	}
+	if f.Language != "" && !strings.EqualFold(broadcast.Language, f.Language) {
+		return false
+	}
+	if f.Tag != "" {
+		tag := strings.ToLower(strings.TrimPrefix(f.Tag, "#"))
+		for _, t := range broadcast.Tags {
+			if t == tag {
+				return true
+			}
+		}
+		return false
+	}
+	return true
+}
+
+// 방송 정보 수정 (update_broadcast)
+func (wsService *WebSocketService) updateBroadcast(broadcasterID string, msg *Message) {
+	wsService.Mutex.Lock()
+	defer wsService.Mutex.Unlock()
+
+	broadcaster := wsService.Broadcasters[broadcasterID]
+	broadcast := wsService.ActiveBroadcasts[broadcasterID]
+	if broadcaster == nil || broadcast == nil {
+		fmt.Printf("❌ 수정할 방송을 찾을 수 없음: %s\n", broadcasterID)
+		return
+	}
+
+	// 검증이 모두 끝난 뒤에 반영 (일부만 수정되지 않도록)
+	metadata := broadcast.BroadcastMetadata
+	wasListed := broadcast.isListed()
+	update, err := parseMetadataUpdate(msg.Data)
+	if err == nil {
+		err = metadata.apply(update)
+	}
+	if err == nil {
+		var accessUpdate *broadcastAccessUpdate
+		if accessUpdate, err = parseAccessUpdate(msg.Data); err == nil {
+			err = broadcast.applyAccess(accessUpdate)
+		}
+	}
+	if err != nil {
+		wsService.sendToConnection(broadcaster, &Message{
+			Type: "error",
+			Data: err.Error(),
+		})
+		return
+	}
+	broadcast.BroadcastMetadata = metadata
+	broadcast.refreshHLSURL()
+	if maxViewers, ok := maxViewersFromMessage(msg); ok {
+		wsService.setMaxViewers(broadcast, maxViewers)
+	}
+
+	fmt.Printf("📝 방송 정보 수정: %s (%s, %s)\n", broadcasterID, broadcast.Title, broadcast.Visibility)
+	wsService.recordBroadcastUpdate(broadcast)
+
+	// 비공개로 바뀌면 접근 권한이 없는 시청자를 분리
+	wsService.detachUngrantedViewers(broadcast)
+
+	updatedMsg := &Message{
+		Type:          "broadcast_updated",
+		BroadcasterID: broadcasterID,
+		Broadcast:     broadcast,
+	}
+
+	wsService.sendToConnection(broadcaster, updatedMsg)
+	for _, viewer := range wsService.Viewers {
+		if viewer.BroadcasterID == broadcasterID {
+			wsService.sendToConnection(viewer.Connection, updatedMsg)
+		}
+	}
+
+	// 공개 범위가 바뀌면 목록 구독자에게는 방송 시작/종료로 알림
+	switch {
+	case wasListed && !broadcast.isListed():
+		wsService.sendToListSubscribers(&Message{
+			Type:          "broadcast_ended",
+			BroadcasterID: broadcasterID,
+		})
+	case !wasListed && broadcast.isListed():
+		wsService.sendToListSubscribers(&Message{
+			Type:      "broadcast_started",
+			Broadcast: broadcast,
+		})
+	default:
+		wsService.broadcastToListSubscribers(updatedMsg)
+	}
+}
@@ -0,0 +1,447 @@
+package services
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+	"toysgo/models"
+)
+
+// 방송자별 차단 목록과 매니저 목록 (서비스 뮤텍스로 보호됨)
+// 익명 사용자 차단은 DB에 저장하지 않고 서버가 실행되는 동안만 유지된다.
+type moderationList struct {
+	banned     map[string]bool
+	moderators map[string]bool
+}
+
+func newModerationList() *moderationList {
+	return &moderationList{
+		banned:     make(map[string]bool),
+		moderators: make(map[string]bool),
+	}
+}
+
+// 메시지에서 대상 사용자 ID 추출 (viewer_id 또는 data.viewer_id)
+func moderationTargetFromMessage(msg *Message) string {
+	if target := toString(msg.ViewerID); target != "" {
+		return target
+	}
+	if data, ok := msg.Data.(map[string]interface{}); ok {
+		if target := toString(data["viewer_id"]); target != "" {
+			return target
+		}
+		return toString(data["user_id"])
+	}
+	return ""
+}
+
+// 메시지 data 필드에서 값 추출
+func moderationParam(msg *Message, key string) interface{} {
+	if data, ok := msg.Data.(map[string]interface{}); ok {
+		return data[key]
+	}
+	return nil
+}
+
+// 메시지 data 필드에서 초 단위 값 추출
+func moderationSeconds(msg *Message, key string) int {
+	switch v := moderationParam(msg, key).(type) {
+	case float64:
+		return int(v)
+	case int:
+		return v
+	}
+	if key == "seconds" {
+		if v, ok := msg.Data.(float64); ok {
+			return int(v)
+		}
+	}
+	return 0
+}
+
+// 방송자의 차단/매니저 목록을 DB에서 읽어 캐시 (이미 읽었으면 생략)
+// 인증된 방송자가 방송을 등록하기 직전에만 호출하며, 목록은 방송이 끝나면 지운다.
+// DB 조회는 서비스 뮤텍스 밖에서 실행된다.
+func (wsService *WebSocketService) loadModeration(broadcasterID string) {
+	if broadcasterID == "" {
+		return
+	}
+
+	wsService.Mutex.RLock()
+	loaded := wsService.moderation[broadcasterID] != nil
+	wsService.Mutex.RUnlock()
+	if loaded {
+		return
+	}
+
+	list := newModerationList()
+	if id := userIDToInt(broadcasterID); id != 0 {
+		conn := models.NewConnection()
+		if conn != nil {
+			for _, item := range *models.NewBroadcastBanManager(conn).GetByBroadcaster(id) {
+				list.banned[fmt.Sprintf("%d", item.User)] = true
+			}
+			for _, item := range *models.NewBroadcastModeratorManager(conn).GetByBroadcaster(id) {
+				list.moderators[fmt.Sprintf("%d", item.User)] = true
+			}
+			conn.Close()
+		}
+	}
+
+	wsService.Mutex.Lock()
+	if wsService.moderation[broadcasterID] == nil {
+		wsService.moderation[broadcasterID] = list
+	}
+	wsService.Mutex.Unlock()
+}
+
+// 차단 여부 (호출자가 잠금을 보유해야 함)
+func (wsService *WebSocketService) isBanned(broadcasterID string, userID string) bool {
+	list := wsService.moderation[broadcasterID]
+	return list != nil && list.banned[userID]
+}
+
+// 매니저 여부 (호출자가 잠금을 보유해야 함)
+func (wsService *WebSocketService) isModerator(broadcasterID string, userID string) bool {
+	list := wsService.moderation[broadcasterID]
+	return list != nil && list.moderators[userID]
+}
+
+// 방송자 본인 또는 매니저인지 확인 (호출자가 잠금을 보유해야 함)
+func (wsService *WebSocketService) canModerate(broadcasterID string, userID string) bool {
+	return userID == broadcasterID || wsService.isModerator(broadcasterID, userID)
+}
+
+// 관리 명령 처리 (kick_viewer, ban_viewer, unban_viewer, mute_viewer, unmute_viewer, slow_mode, add_moderator, remove_moderator)
+func (wsService *WebSocketService) handleModeration(actor *Connection, broadcasterID string, msg *Message) {
+	wsService.Mutex.Lock()
+	defer wsService.Mutex.Unlock()
+
+	if broadcasterID == "" || !wsService.canModerate(broadcasterID, actor.UserID) {
+		wsService.sendModerationError(actor, msg.Type, "forbidden", "권한이 없습니다.")
+		return
+	}
+
+	// 방송 중이 아니면 목록을 캐시하지 않음 (변경 사항은 DB에만 기록되어 다음 방송 시작 시 읽힘)
+	list := wsService.moderation[broadcasterID]
+	cached := list != nil
+	if !cached {
+		list = newModerationList()
+	}
+
+	if msg.Type == "slow_mode" {
+		wsService.setSlowMode(actor, broadcasterID, moderationSeconds(msg, "seconds"))
+		return
+	}
+
+	target := moderationTargetFromMessage(msg)
+	if target == "" {
+		wsService.sendModerationError(actor, msg.Type, "invalid_target", "대상 사용자를 지정해 주세요.")
+		return
+	}
+
+	// 방송자는 대상이 될 수 없고, 매니저는 다른 매니저를 관리할 수 없음
+	if target == broadcasterID || target == actor.UserID ||
+		(actor.UserID != broadcasterID && list.moderators[target]) {
+		wsService.sendModerationError(actor, msg.Type, "forbidden", "해당 사용자는 관리할 수 없습니다.")
+		return
+	}
+
+	reason := toString(moderationParam(msg, "reason"))
+
+	switch msg.Type {
+	case "kick_viewer":
+		if !wsService.kickViewer(broadcasterID, target, "kicked", reason) {
+			wsService.sendModerationError(actor, msg.Type, "not_found", "시청자를 찾을 수 없습니다.")
+			return
+		}
+		fmt.Printf("👢 시청자 강퇴: %s <- 방송 %s (by %s)\n", target, broadcasterID, actor.UserID)
+
+	case "ban_viewer":
+		list.banned[target] = true
+		delete(list.moderators, target)
+		wsService.recordBan(broadcasterID, target, actor.UserID, reason)
+		wsService.kickViewer(broadcasterID, target, "banned", reason)
+		fmt.Printf("🚫 시청자 차단: %s <- 방송 %s (by %s)\n", target, broadcasterID, actor.UserID)
+
+	case "unban_viewer":
+		// 방송 중이 아니면 메모리 목록이 비어 있으므로 저장된 차단 기록에서 확인 (결과는 기록 처리 후 전송)
+		if !cached {
+			wsService.unbanStored(actor, broadcasterID, target, reason)
+			return
+		}
+		if !list.banned[target] {
+			wsService.sendModerationError(actor, msg.Type, "not_found", "차단된 사용자가 아닙니다.")
+			return
+		}
+		delete(list.banned, target)
+		wsService.recordUnban(broadcasterID, target)
+		fmt.Printf("✅ 시청자 차단 해제: %s <- 방송 %s (by %s)\n", target, broadcasterID, actor.UserID)
+
+	case "mute_viewer", "unmute_viewer":
+		broadcast := wsService.ActiveBroadcasts[broadcasterID]
+		if broadcast == nil || broadcast.chat == nil {
+			wsService.sendModerationError(actor, msg.Type, "not_found", "방송을 찾을 수 없습니다.")
+			return
+		}
+
+		data := map[string]interface{}{}
+		if msg.Type == "mute_viewer" {
+			until := broadcast.chat.mute(target, time.Duration(moderationSeconds(msg, "duration"))*time.Second)
+			if !until.IsZero() {
+				data["until"] = until.Format(time.RFC3339)
+			}
+			fmt.Printf("🔇 채팅 금지: %s <- 방송 %s (by %s)\n", target, broadcasterID, actor.UserID)
+		} else {
+			broadcast.chat.unmute(target)
+			fmt.Printf("🔊 채팅 금지 해제: %s <- 방송 %s (by %s)\n", target, broadcasterID, actor.UserID)
+		}
+
+		if viewer := wsService.Viewers[target]; viewer != nil && viewer.BroadcasterID == broadcasterID {
+			eventType := "muted"
+			if msg.Type == "unmute_viewer" {
+				eventType = "unmuted"
+			}
+			wsService.sendToConnection(viewer.Connection, &Message{
+				Type:          eventType,
+				BroadcasterID: broadcasterID,
+				Data:          data,
+			})
+		}
+
+	case "add_moderator", "remove_moderator":
+		if actor.UserID != broadcasterID {
+			wsService.sendModerationError(actor, msg.Type, "forbidden", "방송자만 매니저를 지정할 수 있습니다.")
+			return
+		}
+
+		if msg.Type == "add_moderator" {
+			if list.banned[target] {
+				wsService.sendModerationError(actor, msg.Type, "forbidden", "차단된 사용자는 매니저로 지정할 수 없습니다.")
+				return
+			}
+			list.moderators[target] = true
+			wsService.recordModerator(broadcasterID, target, true)
+		} else {
+			delete(list.moderators, target)
+			wsService.recordModerator(broadcasterID, target, false)
+		}
+
+		if viewer := wsService.Viewers[target]; viewer != nil {
+			wsService.sendToConnection(viewer.Connection, &Message{
+				Type:          "moderator_updated",
+				BroadcasterID: broadcasterID,
+				Data: map[string]interface{}{
+					"moderator": msg.Type == "add_moderator",
+				},
+			})
+		}
+		fmt.Printf("🛡️ 매니저 변경: %s <- 방송 %s (%s)\n", target, broadcasterID, msg.Type)
+	}
+
+	wsService.sendModerationResult(actor, broadcasterID, target, msg.Type, reason)
+}
+
+// 관리 명령 결과 전송
+func (wsService *WebSocketService) sendModerationResult(connection *Connection, broadcasterID string, target string, action string, reason string) {
+	wsService.sendToConnection(connection, &Message{
+		Type:          "moderation_result",
+		BroadcasterID: broadcasterID,
+		ViewerID:      target,
+		Data: map[string]interface{}{
+			"action": action,
+			"reason": reason,
+		},
+	})
+}
+
+// 슬로우 모드 설정 (0이면 해제, 호출자가 잠금을 보유해야 함)
+func (wsService *WebSocketService) setSlowMode(actor *Connection, broadcasterID string, seconds int) {
+	broadcast := wsService.ActiveBroadcasts[broadcasterID]
+	if broadcast == nil || broadcast.chat == nil {
+		wsService.sendModerationError(actor, "slow_mode", "not_found", "방송을 찾을 수 없습니다.")
+		return
+	}
+	if seconds < 0 {
+		seconds = 0
+	}
+
+	broadcast.chat.slowMode = time.Duration(seconds) * time.Second
+	fmt.Printf("🐌 슬로우 모드: 방송 %s, %d초 (by %s)\n", broadcasterID, seconds, actor.UserID)
+
+	wsService.sendToBroadcastAudience(broadcasterID, &Message{
+		Type:          "slow_mode",
+		BroadcasterID: broadcasterID,
+		Data: map[string]interface{}{
+			"seconds": seconds,
+		},
+	})
+}
+
+// 시청자를 방송에서 내보냄 (호출자가 잠금을 보유해야 함)
+// 시청자에게 사유를 보낸 뒤 연결을 닫는다. WHEP 시청 세션도 함께 끊으며, 해당 방송의 시청자가 아니면 false를 반환한다.
+func (wsService *WebSocketService) kickViewer(broadcasterID string, viewerID string, eventType string, reason string) bool {
+	kicked := wsService.kickWHEPViewer(broadcasterID, viewerID)
+
+	viewer := wsService.Viewers[viewerID]
+	if viewer == nil || (viewer.BroadcasterID != broadcasterID && viewer.requested != broadcasterID) {
+		return kicked
+	}
+
+	wsService.sendToConnection(viewer.Connection, &Message{
+		Type:          eventType,
+		BroadcasterID: broadcasterID,
+		Data: map[string]interface{}{
+			"reason": reason,
+		},
+	})
+
+	wsService.recordViewerLeave(viewer)
+	viewer.Connection.Shutdown()
+	delete(wsService.Viewers, viewerID)
+	delete(wsService.PendingOffers, viewerID)
+	wsService.releaseViewerSlot(viewerID, viewer, broadcasterID)
+
+	if broadcast := wsService.ActiveBroadcasts[broadcasterID]; broadcast != nil {
+		broadcast.ViewerCount = wsService.countViewers(broadcasterID)
+
+		if broadcaster := wsService.Broadcasters[broadcasterID]; broadcaster != nil {
+			wsService.sendToConnection(broadcaster, &Message{
+				Type:       "viewer_left",
+				ViewerID:   viewerID,
+				ViewerName: viewer.Connection.Name,
+				Count:      broadcast.ViewerCount,
+			})
+		}
+		wsService.updateViewerCount(broadcasterID, broadcast.ViewerCount)
+	}
+	return true
+}
+
+// 방송 시작 전부터 기다리던 시청자 중 차단된 사용자를 내보냄 (호출자가 잠금을 보유해야 함)
+func (wsService *WebSocketService) kickBannedViewers(broadcasterID string) {
+	for viewerID, viewer := range wsService.Viewers {
+		if viewer.BroadcasterID != broadcasterID && viewer.requested != broadcasterID {
+			continue
+		}
+		if wsService.isBanned(broadcasterID, viewerID) {
+			fmt.Printf("🚫 차단된 시청자 정리: %s -> 방송 %s\n", viewerID, broadcasterID)
+			wsService.kickViewer(broadcasterID, viewerID, "banned", "")
+		}
+	}
+}
+
+// 차단 알림 전송
+func (wsService *WebSocketService) sendBanned(connection *Connection, broadcasterID string) {
+	wsService.sendToConnection(connection, &Message{
+		Type:          "banned",
+		BroadcasterID: broadcasterID,
+		Data:          "이 방송에서 차단되었습니다.",
+	})
+}
+
+// 관리 명령 오류 전송
+func (wsService *WebSocketService) sendModerationError(connection *Connection, action string, code string, reason string) {
+	wsService.sendToConnection(connection, &Message{
+		Type: "moderation_error",
+		Data: map[string]interface{}{
+			"action":  action,
+			"code":    code,
+			"message": reason,
+		},
+	})
+}
+
+// 차단 기록 저장 (로그인 사용자만)
+func (wsService *WebSocketService) recordBan(broadcasterID string, userID string, moderatorID string, reason string) {
+	broadcaster := userIDToInt(broadcasterID)
+	user := userIDToInt(userID)
+	if broadcaster == 0 || user == 0 {
+		return
+	}
+
+	item := &models.BroadcastBan{
+		Broadcaster: broadcaster,
+		User:        user,
+		Reason:      truncate(reason, 200),
+		Moderator:   userIDToInt(moderatorID),
+	}
+
+	wsService.history.enqueue(func(conn *sql.DB) {
+		manager := models.NewBroadcastBanManager(conn)
+		if err := manager.DeleteByUser(broadcaster, user); err != nil {
+			fmt.Printf("❌ 차단 기록 정리 실패: %v\n", err)
+		}
+		if err := manager.Insert(item); err != nil {
+			fmt.Printf("❌ 차단 기록 저장 실패: %v\n", err)
+		}
+	})
+}
+
+// 차단 기록 삭제
+func (wsService *WebSocketService) recordUnban(broadcasterID string, userID string) {
+	broadcaster := userIDToInt(broadcasterID)
+	user := userIDToInt(userID)
+	if broadcaster == 0 || user == 0 {
+		return
+	}
+
+	wsService.history.enqueue(func(conn *sql.DB) {
+		if err := models.NewBroadcastBanManager(conn).DeleteByUser(broadcaster, user); err != nil {
+			fmt.Printf("❌ 차단 해제 기록 실패: %v\n", err)
+		}
+	})
+}
+
+// 방송 중이 아닐 때 저장된 차단 기록 해제 (기록이 없으면 not_found)
+func (wsService *WebSocketService) unbanStored(actor *Connection, broadcasterID string, target string, reason string) {
+	broadcaster := userIDToInt(broadcasterID)
+	user := userIDToInt(target)
+	if broadcaster == 0 || user == 0 {
+		wsService.sendModerationError(actor, "unban_viewer", "not_found", "차단된 사용자가 아닙니다.")
+		return
+	}
+
+	moderatorID := actor.UserID
+	wsService.history.enqueue(func(conn *sql.DB) {
+		manager := models.NewBroadcastBanManager(conn)
+		count := manager.Count([]interface{}{
+			models.Where{Column: "broadcaster", Value: broadcaster, Compare: "="},
+			models.Where{Column: "user", Value: user, Compare: "="},
+		})
+		if count == 0 {
+			wsService.sendModerationError(actor, "unban_viewer", "not_found", "차단된 사용자가 아닙니다.")
+			return
+		}
+
+		if err := manager.DeleteByUser(broadcaster, user); err != nil {
+			fmt.Printf("❌ 차단 해제 기록 실패: %v\n", err)
+			wsService.sendModerationError(actor, "unban_viewer", "failed", "차단 해제에 실패했습니다.")
+			return
+		}
+		fmt.Printf("✅ 시청자 차단 해제: %s <- 방송 %s (by %s)\n", target, broadcasterID, moderatorID)
+		wsService.sendModerationResult(actor, broadcasterID, target, "unban_viewer", reason)
+	})
+}
+
+// 매니저 지정/해제 기록
+func (wsService *WebSocketService) recordModerator(broadcasterID string, userID string, appoint bool) {
+	broadcaster := userIDToInt(broadcasterID)
+	user := userIDToInt(userID)
+	if broadcaster == 0 || user == 0 {
+		return
+	}
+
+	wsService.history.enqueue(func(conn *sql.DB) {
+		manager := models.NewBroadcastModeratorManager(conn)
+		if err := manager.DeleteByUser(broadcaster, user); err != nil {
+			fmt.Printf("❌ 매니저 기록 정리 실패: %v\n", err)
+		}
+		if !appoint {
+			return
+		}
+		if err := manager.Insert(&models.BroadcastModerator{Broadcaster: broadcaster, User: user}); err != nil {
+			fmt.Printf("❌ 매니저 기록 저장 실패: %v\n", err)
+		}
+	})
+}
@@ -0,0 +1,119 @@
+package services
+
+import (
+	"fmt"
+	"time"
+	"toysgo/config"
+)
+
+// 유휴 연결 정리 루프
+func (wsService *WebSocketService) runReaper() {
+	interval := time.Duration(config.WsReapInterval) * time.Second
+	if interval <= 0 {
+		interval = 30 * time.Second
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		wsService.reap()
+	}
+}
+
+// 응답 없는 연결과 유령 방송 정리, 시청자 수 보정
+func (wsService *WebSocketService) reap() {
+	now := time.Now()
+
+	staleBroadcasters := make(map[string]*Connection)
+	staleViewers := make(map[string]*ViewerInfo)
+	staleSubscribers := make([]*Connection, 0)
+	ghostBroadcasts := make([]string, 0)
+
+	wsService.Mutex.RLock()
+	for id, broadcaster := range wsService.Broadcasters {
+		if broadcaster.IsIdle(now) {
+			staleBroadcasters[id] = broadcaster
+		}
+	}
+	for id, viewer := range wsService.Viewers {
+		if viewer.Connection.IsIdle(now) {
+			staleViewers[id] = viewer
+		}
+	}
+	for subscriber := range wsService.ListSubscribers {
+		if subscriber.IsIdle(now) {
+			staleSubscribers = append(staleSubscribers, subscriber)
+		}
+	}
+	for id, broadcast := range wsService.ActiveBroadcasts {
+		if wsService.Broadcasters[id] == nil && broadcast.Status != BroadcastStatusReconnecting && !broadcast.isWHIP() {
+			ghostBroadcasts = append(ghostBroadcasts, id)
+		}
+	}
+	wsService.Mutex.RUnlock()
+
+	for id, broadcaster := range staleBroadcasters {
+		fmt.Printf("💀 응답 없는 방송자 정리: %s (마지막 수신 %s)\n", id, broadcaster.LastSeen().Format("15:04:05"))
+		broadcaster.Abort()
+		wsService.removeBroadcaster(id, broadcaster)
+	}
+
+	for id, viewer := range staleViewers {
+		fmt.Printf("💀 응답 없는 시청자 정리: %s (마지막 수신 %s)\n", id, viewer.Connection.LastSeen().Format("15:04:05"))
+		viewer.Connection.Abort()
+		wsService.removeViewerInfo(id, viewer)
+	}
+
+	if len(staleSubscribers) > 0 {
+		wsService.Mutex.Lock()
+		for _, subscriber := range staleSubscribers {
+			fmt.Printf("💀 응답 없는 목록 구독자 정리: %s\n", subscriber.UserID)
+			subscriber.Abort()
+			delete(wsService.ListSubscribers, subscriber)
+		}
+		wsService.Mutex.Unlock()
+	}
+
+	for _, id := range ghostBroadcasts {
+		wsService.Mutex.RLock()
+		broadcast := wsService.ActiveBroadcasts[id]
+		ghost := broadcast != nil && wsService.Broadcasters[id] == nil && broadcast.Status != BroadcastStatusReconnecting && !broadcast.isWHIP()
+		wsService.Mutex.RUnlock()
+		if ghost {
+			fmt.Printf("💀 방송자 없는 방송 정리: %s\n", id)
+			wsService.stopBroadcast(id)
+		}
+	}
+
+	wsService.reconcileViewerCounts()
+}
+
+// 기록된 시청자 수를 실제 연결된 시청자 수로 보정
+func (wsService *WebSocketService) reconcileViewerCounts() {
+	wsService.Mutex.Lock()
+	defer wsService.Mutex.Unlock()
+
+	for id, broadcast := range wsService.ActiveBroadcasts {
+		actual := wsService.countViewers(id)
+		if broadcast.ViewerCount != actual {
+			fmt.Printf("🔧 시청자 수 보정: %s %d -> %d\n", id, broadcast.ViewerCount, actual)
+			broadcast.ViewerCount = actual
+			wsService.updateViewerCount(id, actual)
+		}
+	}
+}
+
+// 방송에 연결된 시청자 수 (WHEP 시청자 포함, 호출자가 잠금을 보유해야 함)
+func (wsService *WebSocketService) countViewers(broadcasterID string) int {
+	count := 0
+	if broadcast := wsService.ActiveBroadcasts[broadcasterID]; broadcast != nil {
+		count = len(broadcast.whep)
+	}
+	for _, viewer := range wsService.Viewers {
+		if viewer.BroadcasterID == broadcasterID {
+			count++
+		}
+	}
+	return count
+}
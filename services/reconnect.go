@@ -0,0 +1,172 @@
+package services
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"fmt"
+	"time"
+	"toysgo/config"
+)
+
+// 방송 상태
+const (
+	BroadcastStatusLive         = "live"
+	BroadcastStatusReconnecting = "reconnecting"
+)
+
+// 재접속 토큰 생성
+func newResumeToken() string {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return fmt.Sprintf("%d", time.Now().UnixNano())
+	}
+	return hex.EncodeToString(buf)
+}
+
+// 메시지에서 재접속 토큰 추출 ({"type":"resume_broadcast","data":{"resume_token":"..."}})
+func resumeTokenFromMessage(msg *Message) string {
+	if data, ok := msg.Data.(map[string]interface{}); ok {
+		return toString(data["resume_token"])
+	}
+	return toString(msg.Data)
+}
+
+// 방송자에게 세션 정보 전송 (호출자가 잠금을 보유해야 함)
+func (wsService *WebSocketService) sendBroadcastSession(broadcaster *Connection, broadcast *BroadcastInfo) {
+	wsService.sendToConnection(broadcaster, &Message{
+		Type:      "broadcast_session",
+		Broadcast: broadcast,
+		Data: map[string]interface{}{
+			"resume_token":    broadcast.resumeToken,
+			"reconnect_grace": config.BroadcasterReconnectGrace,
+		},
+	})
+}
+
+// 방송자 연결이 끊겼을 때 방송을 재접속 대기 상태로 전환
+// 유예 시간이 설정되지 않았거나 진행 중인 방송이 없으면 false를 반환한다.
+func (wsService *WebSocketService) suspendBroadcast(broadcasterID string, connection *Connection) bool {
+	grace := time.Duration(config.BroadcasterReconnectGrace) * time.Second
+	if grace <= 0 {
+		return false
+	}
+
+	wsService.Mutex.Lock()
+	defer wsService.Mutex.Unlock()
+
+	broadcast := wsService.ActiveBroadcasts[broadcasterID]
+	if broadcast == nil || wsService.Broadcasters[broadcasterID] != connection {
+		return false
+	}
+
+	if broadcast.reconnectTimer != nil {
+		broadcast.reconnectTimer.Stop()
+	}
+
+	broadcast.Status = BroadcastStatusReconnecting
+	broadcast.reconnectDeadline = time.Now().Add(grace)
+	broadcast.reconnectTimer = time.AfterFunc(grace, func() {
+		wsService.expireReconnect(broadcasterID)
+	})
+
+	fmt.Printf("⏳ 방송자 재접속 대기: %s (%d초)\n", broadcasterID, config.BroadcasterReconnectGrace)
+
+	// 재협상이 필요하므로 대기 중인 Offer는 폐기
+	reconnectingMsg := &Message{
+		Type:          "broadcaster_reconnecting",
+		BroadcasterID: broadcasterID,
+		Data: map[string]interface{}{
+			"grace":    config.BroadcasterReconnectGrace,
+			"deadline": broadcast.reconnectDeadline.Format(time.RFC3339),
+		},
+	}
+	for viewerID, viewer := range wsService.Viewers {
+		if viewer.BroadcasterID == broadcasterID {
+			delete(wsService.PendingOffers, viewerID)
+			wsService.sendToConnection(viewer.Connection, reconnectingMsg)
+		}
+	}
+
+	wsService.broadcastToListSubscribers(reconnectingMsg)
+	return true
+}
+
+// 유예 시간이 지나도록 재개되지 않은 방송 종료
+func (wsService *WebSocketService) expireReconnect(broadcasterID string) {
+	wsService.Mutex.RLock()
+	broadcast := wsService.ActiveBroadcasts[broadcasterID]
+	expired := broadcast != nil &&
+		broadcast.Status == BroadcastStatusReconnecting &&
+		!time.Now().Before(broadcast.reconnectDeadline)
+	wsService.Mutex.RUnlock()
+
+	if expired {
+		fmt.Printf("⌛ 방송자 재접속 유예 만료: %s\n", broadcasterID)
+		wsService.stopBroadcast(broadcasterID)
+	}
+}
+
+// 재접속한 방송자를 기존 방송에 다시 연결하고 시청자들과 재협상
+func (wsService *WebSocketService) resumeBroadcast(broadcasterID string, resumeToken string) {
+	wsService.Mutex.Lock()
+	defer wsService.Mutex.Unlock()
+
+	broadcaster := wsService.Broadcasters[broadcasterID]
+	if broadcaster == nil {
+		return
+	}
+
+	broadcast := wsService.ActiveBroadcasts[broadcasterID]
+	if broadcast == nil || resumeToken == "" ||
+		subtle.ConstantTimeCompare([]byte(resumeToken), []byte(broadcast.resumeToken)) != 1 {
+		fmt.Printf("❌ 방송 재개 실패: %s\n", broadcasterID)
+		wsService.sendToConnection(broadcaster, &Message{
+			Type: "resume_failed",
+			Data: "재개할 방송이 없거나 토큰이 일치하지 않습니다.",
+		})
+		return
+	}
+
+	if broadcast.reconnectTimer != nil {
+		broadcast.reconnectTimer.Stop()
+		broadcast.reconnectTimer = nil
+	}
+	broadcast.Status = BroadcastStatusLive
+	broadcast.reconnectDeadline = time.Time{}
+	broadcast.BroadcasterName = broadcaster.Name
+
+	fmt.Printf("🔁 방송 재개: %s\n", broadcasterID)
+
+	wsService.sendBroadcastSession(broadcaster, broadcast)
+
+	// 기존 시청자마다 새 Offer 요청
+	resumedMsg := &Message{
+		Type:          "broadcaster_resumed",
+		BroadcasterID: broadcasterID,
+		Broadcast:     broadcast,
+	}
+	renegotiated := 0
+	for viewerID, viewer := range wsService.Viewers {
+		if viewer.BroadcasterID != broadcasterID {
+			continue
+		}
+
+		wsService.sendToConnection(viewer.Connection, resumedMsg)
+
+		// SFU 방송은 시청자 연결이 서버에 남아 있으므로 방송자가 다시 송출하기만 하면 됨
+		if !viewer.admitted || broadcast.Mode == BroadcastModeSFU {
+			continue
+		}
+		wsService.sendToConnection(broadcaster, &Message{
+			Type:       "offer_request",
+			ViewerID:   viewerID,
+			ViewerName: viewer.Connection.Name,
+		})
+		wsService.PendingOffers[viewerID] = broadcasterID
+		renegotiated++
+	}
+	fmt.Printf("🔁 재협상 요청: %s, 시청자 %d명\n", broadcasterID, renegotiated)
+
+	wsService.broadcastToListSubscribers(resumedMsg)
+}
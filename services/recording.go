@@ -0,0 +1,506 @@
+package services
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+	"toysgo/config"
+	"toysgo/global"
+	"toysgo/models"
+
+	"github.com/pion/rtp"
+	"github.com/pion/rtp/codecs"
+	"github.com/pion/webrtc/v3"
+	"github.com/pion/webrtc/v3/pkg/media"
+	"github.com/pion/webrtc/v3/pkg/media/h264writer"
+	"github.com/pion/webrtc/v3/pkg/media/ivfwriter"
+	"github.com/pion/webrtc/v3/pkg/media/oggwriter"
+)
+
+// 순서 정렬을 위해 잡아 두는 패킷 수 (이보다 오래 빠진 패킷은 손실로 처리)
+const recordingJitterPackets = 128
+
+var (
+	ErrRecordingUnavailable = errors.New("SFU 모드로 진행 중인 방송만 녹화할 수 있습니다")
+	ErrRecordingActive      = errors.New("이미 녹화 중입니다")
+	ErrRecordingNotFound    = errors.New("녹화 중이 아닙니다")
+)
+
+// 파일 이름에 쓸 수 없는 문자
+var recordingNamePattern = regexp.MustCompile(`[^A-Za-z0-9_-]`)
+
+// 녹화 결과
+type RecordingResult struct {
+	Audiofile  string
+	Videofile  string
+	Videocodec string
+	Size       int64
+	Duration   time.Duration
+}
+
+// 방송 하나의 녹화 (트랙별 파일)
+type sfuRecorder struct {
+	mu      sync.Mutex
+	name    string // UploadPath 기준 상대 경로 (확장자 제외)
+	started time.Time
+	tracks  map[webrtc.RTPCodecType]*trackRecorder
+	closed  bool
+}
+
+// 트랙 하나의 녹화 파일
+type trackRecorder struct {
+	file     string
+	mimeType string
+	writer   media.Writer
+	buffer   *jitterBuffer
+	ssrc     webrtc.SSRC
+	keyframe bool
+}
+
+// 순서가 뒤바뀐 RTP 패킷을 시퀀스 번호 순으로 정렬
+type jitterBuffer struct {
+	packets map[uint16]*rtp.Packet
+	next    uint16
+	started bool
+}
+
+func newJitterBuffer() *jitterBuffer {
+	return &jitterBuffer{packets: make(map[uint16]*rtp.Packet)}
+}
+
+// 패킷 추가 후 순서대로 꺼낼 수 있는 패킷 반환
+func (b *jitterBuffer) push(packet *rtp.Packet) []*rtp.Packet {
+	if !b.started {
+		b.next = packet.SequenceNumber
+		b.started = true
+	}
+
+	// 이미 지나간 패킷은 버림
+	if int16(packet.SequenceNumber-b.next) < 0 {
+		return nil
+	}
+	b.packets[packet.SequenceNumber] = packet
+
+	var ready []*rtp.Packet
+	for len(b.packets) > 0 {
+		if next, ok := b.packets[b.next]; ok {
+			ready = append(ready, next)
+			delete(b.packets, b.next)
+			b.next++
+			continue
+		}
+		if len(b.packets) < recordingJitterPackets {
+			break
+		}
+		// 버퍼가 가득 찰 때까지 오지 않은 패킷은 건너뜀
+		b.next++
+	}
+	return ready
+}
+
+// 남은 패킷을 시퀀스 번호 순으로 모두 반환
+func (b *jitterBuffer) flush() []*rtp.Packet {
+	var ready []*rtp.Packet
+	for len(b.packets) > 0 {
+		if next, ok := b.packets[b.next]; ok {
+			ready = append(ready, next)
+			delete(b.packets, b.next)
+		}
+		b.next++
+	}
+	return ready
+}
+
+// 녹화 파일 기본 이름 (UploadPath 기준 상대 경로)
+func recordingBaseName(broadcasterID string, now time.Time) string {
+	dir := recordingNamePattern.ReplaceAllString(broadcasterID, "_")
+	return filepath.ToSlash(filepath.Join("recordings", dir, now.Format("20060102-150405")))
+}
+
+// 녹화 파일의 실제 경로 (UploadPath 밖을 가리키면 빈 문자열)
+func RecordingFilePath(file string) string {
+	if file == "" {
+		return ""
+	}
+
+	root, err := filepath.Abs(config.UploadPath)
+	if err != nil {
+		return ""
+	}
+	path := filepath.Join(root, filepath.FromSlash(file))
+	if !strings.HasPrefix(path, root+string(filepath.Separator)) {
+		return ""
+	}
+	return path
+}
+
+// 영상 패킷이 키프레임의 시작인지 확인
+func isKeyframePacket(mimeType string, payload []byte) bool {
+	switch mimeType {
+	case webrtc.MimeTypeVP8:
+		vp8 := codecs.VP8Packet{}
+		if _, err := vp8.Unmarshal(payload); err != nil || len(vp8.Payload) == 0 {
+			return false
+		}
+		return vp8.S == 1 && vp8.PID == 0 && vp8.Payload[0]&0x01 == 0
+	case webrtc.MimeTypeH264:
+		// SPS로 시작하는 패킷 (단일 NAL 또는 STAP-A의 첫 NAL)
+		if len(payload) < 1 {
+			return false
+		}
+		naluType := payload[0] & 0x1F
+		if naluType == 24 && len(payload) > 3 {
+			return payload[3]&0x1F == 7
+		}
+		return naluType == 7
+	}
+	return true
+}
+
+// 코덱에 맞는 파일 생성
+func (r *sfuRecorder) openTrack(kind webrtc.RTPCodecType, mimeType string) *trackRecorder {
+	track := &trackRecorder{
+		mimeType: mimeType,
+		buffer:   newJitterBuffer(),
+		keyframe: kind != webrtc.RTPCodecTypeVideo,
+	}
+
+	var err error
+	switch mimeType {
+	case webrtc.MimeTypeOpus:
+		track.file = r.name + ".ogg"
+		track.writer, err = oggwriter.New(RecordingFilePath(track.file), 48000, 2)
+	case webrtc.MimeTypeVP8:
+		track.file = r.name + ".ivf"
+		track.writer, err = ivfwriter.New(RecordingFilePath(track.file), ivfwriter.WithCodec(mimeType))
+	case webrtc.MimeTypeH264:
+		track.file = r.name + ".h264"
+		track.writer, err = h264writer.New(RecordingFilePath(track.file))
+	default:
+		fmt.Printf("⚠️ 녹화할 수 없는 코덱: %s\n", mimeType)
+		return track
+	}
+
+	if err != nil {
+		fmt.Printf("❌ 녹화 파일 생성 실패 (%s): %v\n", track.file, err)
+		track.file = ""
+		track.writer = nil
+	}
+	return track
+}
+
+// 방송자 패킷 기록
+func (r *sfuRecorder) write(kind webrtc.RTPCodecType, mimeType string, packet *rtp.Packet) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.closed {
+		return
+	}
+
+	track := r.tracks[kind]
+	if track == nil {
+		track = r.openTrack(kind, mimeType)
+		r.tracks[kind] = track
+	}
+	if track.writer == nil || track.mimeType != mimeType {
+		return
+	}
+
+	// 재송출 등으로 SSRC가 바뀌면 시퀀스 번호가 이어지지 않으므로 버퍼를 비우고 다시 시작
+	if ssrc := webrtc.SSRC(packet.SSRC); ssrc != track.ssrc {
+		if track.ssrc != 0 {
+			track.writeOrdered(track.buffer.flush())
+			track.buffer = newJitterBuffer()
+			if kind == webrtc.RTPCodecTypeVideo {
+				track.keyframe = false
+			}
+		}
+		track.ssrc = ssrc
+	}
+
+	track.writeOrdered(track.buffer.push(packet))
+}
+
+// 정렬된 패킷 기록 (영상은 키프레임부터)
+func (t *trackRecorder) writeOrdered(packets []*rtp.Packet) {
+	for _, packet := range packets {
+		if !t.keyframe {
+			if !isKeyframePacket(t.mimeType, packet.Payload) {
+				continue
+			}
+			t.keyframe = true
+		}
+		if err := t.writer.WriteRTP(packet); err != nil {
+			fmt.Printf("❌ 녹화 기록 실패 (%s): %v\n", t.file, err)
+		}
+	}
+}
+
+// 녹화 종료 (남은 패킷 기록 후 파일 닫기)
+func (r *sfuRecorder) close() *RecordingResult {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	result := &RecordingResult{Duration: time.Since(r.started)}
+	if r.closed {
+		return result
+	}
+	r.closed = true
+
+	for kind, track := range r.tracks {
+		if track.writer == nil {
+			continue
+		}
+		track.writeOrdered(track.buffer.flush())
+		if err := track.writer.Close(); err != nil {
+			fmt.Printf("❌ 녹화 파일 닫기 실패 (%s): %v\n", track.file, err)
+		}
+
+		if info, err := os.Stat(RecordingFilePath(track.file)); err == nil {
+			result.Size += info.Size()
+		}
+		if kind == webrtc.RTPCodecTypeAudio {
+			result.Audiofile = track.file
+		} else {
+			result.Videofile = track.file
+			result.Videocodec = track.mimeType
+		}
+	}
+	return result
+}
+
+// 녹화 시작 (name은 UploadPath 기준 상대 경로, 확장자는 코덱에 따라 붙음)
+func (w *WebRTCService) StartRecording(broadcasterID string, name string) error {
+	w.mu.Lock()
+	session := w.sessions[broadcasterID]
+	w.mu.Unlock()
+	if session == nil {
+		return ErrSFUSessionNotFound
+	}
+
+	if err := os.MkdirAll(filepath.Dir(RecordingFilePath(name)), 0755); err != nil {
+		return err
+	}
+
+	session.mu.Lock()
+	if session.recorder != nil {
+		session.mu.Unlock()
+		return ErrRecordingActive
+	}
+	session.recorder = &sfuRecorder{
+		name:    name,
+		started: time.Now(),
+		tracks:  make(map[webrtc.RTPCodecType]*trackRecorder),
+	}
+	session.mu.Unlock()
+
+	// 영상 파일이 키프레임부터 시작하도록 바로 요청
+	session.requestKeyframe()
+
+	fmt.Printf("⏺️ 녹화 시작: %s (%s)\n", broadcasterID, name)
+	return nil
+}
+
+// 녹화 분리 (이후 패킷은 기록되지 않음, 파일은 호출자가 close로 닫음)
+func (w *WebRTCService) detachRecorder(broadcasterID string) (*sfuRecorder, error) {
+	w.mu.Lock()
+	session := w.sessions[broadcasterID]
+	w.mu.Unlock()
+	if session == nil {
+		return nil, ErrSFUSessionNotFound
+	}
+
+	session.mu.Lock()
+	recorder := session.recorder
+	session.recorder = nil
+	session.mu.Unlock()
+	if recorder == nil {
+		return nil, ErrRecordingNotFound
+	}
+	return recorder, nil
+}
+
+// 녹화 시작 (방송자 메시지 또는 REST)
+func (wsService *WebSocketService) StartRecording(broadcasterID string) (*models.Recording, error) {
+	wsService.Mutex.Lock()
+	defer wsService.Mutex.Unlock()
+
+	broadcast := wsService.ActiveBroadcasts[broadcasterID]
+	if broadcast == nil || broadcast.Mode != BroadcastModeSFU {
+		return nil, ErrRecordingUnavailable
+	}
+	if broadcast.recording != nil {
+		return nil, ErrRecordingActive
+	}
+
+	now := time.Now()
+	if err := wsService.webrtc.StartRecording(broadcasterID, recordingBaseName(broadcasterID, now)); err != nil {
+		if err == ErrSFUSessionNotFound {
+			err = ErrRecordingUnavailable
+		}
+		return nil, err
+	}
+
+	record := &models.Recording{
+		User:      userIDToInt(broadcasterID),
+		Title:     broadcast.Title,
+		Status:    models.RecordingStatusRecording,
+		Startdate: global.GetDate(now),
+	}
+	snapshot := *record
+	broadcast.recording = record
+	broadcast.Recording = true
+
+	broadcastRecord := broadcast.record
+	wsService.history.enqueue(func(conn *sql.DB) {
+		if broadcastRecord != nil {
+			record.Broadcast = broadcastRecord.Id
+		}
+
+		manager := models.NewRecordingManager(conn)
+		if err := manager.Insert(record); err != nil {
+			fmt.Printf("❌ 녹화 기록 저장 실패: %v\n", err)
+			return
+		}
+		record.Id = manager.GetIdentity()
+	})
+
+	wsService.sendToBroadcastAudience(broadcasterID, &Message{
+		Type:          "recording_status",
+		BroadcasterID: broadcasterID,
+		Data: map[string]interface{}{
+			"recording": true,
+			"startdate": snapshot.Startdate,
+		},
+	})
+	return &snapshot, nil
+}
+
+// 녹화 종료 (방송자 메시지 또는 REST)
+func (wsService *WebSocketService) StopRecording(broadcasterID string) (*models.Recording, error) {
+	wsService.Mutex.Lock()
+	broadcast := wsService.ActiveBroadcasts[broadcasterID]
+	if broadcast == nil || broadcast.recording == nil {
+		wsService.Mutex.Unlock()
+		return nil, ErrRecordingNotFound
+	}
+	stop := wsService.finishRecording(broadcast)
+	wsService.Mutex.Unlock()
+
+	return wsService.completeRecording(stop), nil
+}
+
+// 잠금 안에서 분리한 녹화 (파일 정리는 completeRecording으로 잠금 밖에서 실행)
+type recordingStop struct {
+	broadcasterID string
+	record        *models.Recording
+	recorder      *sfuRecorder
+}
+
+// 녹화를 방송에서 분리 (호출자가 잠금을 보유해야 함)
+// 녹화 중이 아니면 nil을 반환하며, 반환값은 잠금을 푼 뒤 completeRecording에 넘긴다.
+func (wsService *WebSocketService) finishRecording(broadcast *BroadcastInfo) *recordingStop {
+	record := broadcast.recording
+	if record == nil {
+		return nil
+	}
+	broadcast.recording = nil
+	broadcast.Recording = false
+
+	recorder, _ := wsService.webrtc.detachRecorder(broadcast.BroadcasterID)
+	return &recordingStop{
+		broadcasterID: broadcast.BroadcasterID,
+		record:        record,
+		recorder:      recorder,
+	}
+}
+
+// 녹화 파일을 닫고 기록 갱신 (잠금을 보유하지 않은 상태에서 호출)
+func (wsService *WebSocketService) completeRecording(stop *recordingStop) *models.Recording {
+	if stop == nil {
+		return nil
+	}
+	record := stop.record
+
+	result := &RecordingResult{}
+	if stop.recorder != nil {
+		result = stop.recorder.close()
+		fmt.Printf("⏹️ 녹화 종료: %s (%s, %d bytes)\n", stop.broadcasterID, result.Duration.Round(time.Second), result.Size)
+	}
+
+	snapshot := models.Recording{
+		User:       record.User,
+		Title:      record.Title,
+		Audiofile:  result.Audiofile,
+		Videofile:  result.Videofile,
+		Videocodec: result.Videocodec,
+		Size:       result.Size,
+		Duration:   int(result.Duration.Seconds()),
+		Status:     models.RecordingStatusCompleted,
+		Startdate:  record.Startdate,
+		Enddate:    global.GetDate(time.Now()),
+	}
+
+	wsService.history.enqueue(func(conn *sql.DB) {
+		if record.Id == 0 {
+			return
+		}
+		record.Audiofile = snapshot.Audiofile
+		record.Videofile = snapshot.Videofile
+		record.Videocodec = snapshot.Videocodec
+		record.Size = snapshot.Size
+		record.Duration = snapshot.Duration
+		record.Status = snapshot.Status
+		record.Enddate = snapshot.Enddate
+
+		if err := models.NewRecordingManager(conn).Update(record); err != nil {
+			fmt.Printf("❌ 녹화 종료 기록 실패: %v\n", err)
+		}
+	})
+
+	wsService.Mutex.RLock()
+	wsService.sendToBroadcastAudience(stop.broadcasterID, &Message{
+		Type:          "recording_status",
+		BroadcasterID: stop.broadcasterID,
+		Data: map[string]interface{}{
+			"recording": false,
+			"duration":  snapshot.Duration,
+			"size":      snapshot.Size,
+		},
+	})
+	wsService.Mutex.RUnlock()
+	return &snapshot
+}
+
+// 방송자의 녹화 시작/종료 메시지 처리
+func (wsService *WebSocketService) handleRecordingMessage(broadcasterID string, msg *Message) {
+	var err error
+	if msg.Type == "start_recording" {
+		_, err = wsService.StartRecording(broadcasterID)
+	} else {
+		_, err = wsService.StopRecording(broadcasterID)
+	}
+	if err == nil {
+		return
+	}
+
+	wsService.Mutex.RLock()
+	broadcaster := wsService.Broadcasters[broadcasterID]
+	wsService.Mutex.RUnlock()
+	if broadcaster != nil {
+		wsService.sendToConnection(broadcaster, &Message{
+			Type: "recording_error",
+			Data: map[string]interface{}{
+				"action":  msg.Type,
+				"message": err.Error(),
+			},
+		})
+	}
+}
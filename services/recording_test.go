@@ -0,0 +1,58 @@
+package services
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/pion/rtp"
+)
+
+func TestJitterBuffer(t *testing.T) {
+	// 버퍼가 가득 찰 때까지 빠진 패킷을 기다리는 상황
+	gap := []uint16{10}
+	for i := 0; i < recordingJitterPackets; i++ {
+		gap = append(gap, uint16(12+i))
+	}
+
+	tests := []struct {
+		name  string
+		input []uint16
+		// push로 바로 나온 패킷과 flush로 나온 나머지
+		ready []uint16
+		flush []uint16
+	}{
+		{"in order", []uint16{1, 2, 3}, []uint16{1, 2, 3}, nil},
+		{"swapped", []uint16{1, 3, 2, 4}, []uint16{1, 2, 3, 4}, nil},
+		{"reversed burst", []uint16{5, 8, 7, 6}, []uint16{5, 6, 7, 8}, nil},
+		{"late packet dropped", []uint16{5, 6, 4, 7}, []uint16{5, 6, 7}, nil},
+		{"duplicate dropped", []uint16{5, 6, 6, 7}, []uint16{5, 6, 7}, nil},
+		{"sequence wraps", []uint16{65534, 0, 65535, 1}, []uint16{65534, 65535, 0, 1}, nil},
+		{"missing packet waits", []uint16{1, 3, 4}, []uint16{1}, []uint16{3, 4}},
+		{"flush keeps order", []uint16{1, 5, 3, 4}, []uint16{1}, []uint16{3, 4, 5}},
+		{"gap skipped when full", gap, gap, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			buffer := newJitterBuffer()
+
+			var ready []uint16
+			for _, seq := range tt.input {
+				for _, packet := range buffer.push(&rtp.Packet{Header: rtp.Header{SequenceNumber: seq}}) {
+					ready = append(ready, packet.SequenceNumber)
+				}
+			}
+			if !reflect.DeepEqual(ready, tt.ready) {
+				t.Fatalf("push = %v, want %v", ready, tt.ready)
+			}
+
+			var flushed []uint16
+			for _, packet := range buffer.flush() {
+				flushed = append(flushed, packet.SequenceNumber)
+			}
+			if !reflect.DeepEqual(flushed, tt.flush) {
+				t.Fatalf("flush = %v, want %v", flushed, tt.flush)
+			}
+		})
+	}
+}
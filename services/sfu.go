@@ -0,0 +1,788 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+	"time"
+	"toysgo/config"
+
+	"github.com/pion/rtcp"
+	"github.com/pion/webrtc/v3"
+)
+
+// 방송 전송 방식
+const (
+	BroadcastModeMesh = "mesh" // 방송자 브라우저가 시청자마다 PeerConnection을 맺음
+	BroadcastModeSFU  = "sfu"  // 방송자는 서버로 한 번만 송출하고 서버가 시청자에게 전달
+)
+
+// ICE 후보 수집 대기 시간
+const iceGatheringTimeout = 5 * time.Second
+
+var (
+	ErrSFUSessionNotFound = errors.New("SFU 방송을 찾을 수 없습니다")
+	ErrSFUPeerNotFound    = errors.New("SFU 연결을 찾을 수 없습니다")
+)
+
+// 방송 하나의 SFU 세션
+// 방송자가 다시 송출해도 시청자 쪽 트랙은 그대로 유지되어 재협상 없이 이어진다.
+type sfuSession struct {
+	broadcasterID string
+
+	mu          sync.RWMutex
+	publisher   *webrtc.PeerConnection
+	tracks      map[webrtc.RTPCodecType]*webrtc.TrackLocalStaticRTP
+	subscribers map[string]*sfuSubscriber
+
+	// 영상은 시청자마다 레이어를 골라 전달하므로 트랙을 공유하지 않음
+	// (simulcast가 아니면 rid가 빈 문자열인 레이어 하나)
+	videoCodec webrtc.RTPCodecCapability
+	layers     map[string]*sfuLayer
+	// 녹화와 HLS에 사용하는 레이어
+	primaryLayer string
+
+	// 서버 녹화 (녹화 중이 아니면 nil)
+	recorder *sfuRecorder
+	// HLS 출력 (사용하지 않으면 nil)
+	hls *hlsPackager
+
+	// 방송자가 연 이벤트 채널 (없으면 nil)
+	events *webrtc.DataChannel
+
+	// 방송자 음성 SSRC (수신 통계 조회용, 영상은 레이어별 SSRC 사용)
+	audioSSRC webrtc.SSRC
+}
+
+// 시청자 한 명의 서버 측 PeerConnection
+type sfuSubscriber struct {
+	pc      *webrtc.PeerConnection
+	senders map[webrtc.RTPCodecType]*webrtc.RTPSender
+	video   *sfuVideoForwarder
+	// 이벤트 채널 (열리기 전이거나 협상하지 않았으면 nil)
+	events *webrtc.DataChannel
+}
+
+func publisherPeerID(broadcasterID string) string {
+	return "sfu:" + broadcasterID
+}
+
+func subscriberPeerID(broadcasterID string, viewerID string) string {
+	return "sfu:" + broadcasterID + ":" + viewerID
+}
+
+// 수신 트랙 처리 (SFU 방송자의 트랙이면 전달, 아니면 onTrack 훅 호출)
+func (w *WebRTCService) handleTrack(peerID string, track *webrtc.TrackRemote, receiver *webrtc.RTPReceiver) {
+	w.mu.Lock()
+	var session *sfuSession
+	for _, s := range w.sessions {
+		if publisherPeerID(s.broadcasterID) == peerID {
+			session = s
+			break
+		}
+	}
+	onTrack := w.onTrack
+	w.mu.Unlock()
+
+	if session != nil {
+		session.forward(track)
+		return
+	}
+	if onTrack != nil {
+		onTrack(track, receiver)
+	}
+}
+
+// 방송자 송출 시작 (방송자의 Offer를 받아 Answer 반환)
+func (w *WebRTCService) Publish(broadcasterID string, offer string) (string, error) {
+	peerID := publisherPeerID(broadcasterID)
+	w.ClosePeerConnection(peerID)
+
+	w.mu.Lock()
+	session := w.sessions[broadcasterID]
+	if session == nil {
+		session = &sfuSession{
+			broadcasterID: broadcasterID,
+			tracks:        make(map[webrtc.RTPCodecType]*webrtc.TrackLocalStaticRTP),
+			subscribers:   make(map[string]*sfuSubscriber),
+			layers:        make(map[string]*sfuLayer),
+		}
+		w.sessions[broadcasterID] = session
+	}
+	onHLSReady := w.onHLSReady
+	w.mu.Unlock()
+
+	// 다시 송출하면 레이어 구성이 바뀔 수 있으므로 새로 수집
+	session.mu.Lock()
+	session.layers = make(map[string]*sfuLayer)
+	session.primaryLayer = ""
+	session.events = nil
+	session.mu.Unlock()
+
+	// HLS는 H264만 패키징하므로 H264를 우선 협상
+	preferredVideo := ""
+	if config.HLSEnabled {
+		preferredVideo = webrtc.MimeTypeH264
+		session.mu.Lock()
+		if session.hls == nil || session.hls.disabled {
+			session.hls = newHLSPackager(broadcasterID, onHLSReady)
+		}
+		session.mu.Unlock()
+	}
+
+	pc, err := w.CreatePeerConnection(peerID)
+	if err != nil {
+		return "", err
+	}
+
+	session.mu.Lock()
+	session.publisher = pc
+	session.mu.Unlock()
+
+	pc.OnConnectionStateChange(func(state webrtc.PeerConnectionState) {
+		fmt.Printf("📡 SFU 송출 연결 상태 (%s): %s\n", broadcasterID, state.String())
+	})
+
+	// 방송자가 Offer에 이벤트 채널을 포함하면 등록
+	pc.OnDataChannel(func(dc *webrtc.DataChannel) {
+		if dc.Label() != eventChannelLabel {
+			return
+		}
+		session.mu.Lock()
+		if session.publisher == pc {
+			session.events = dc
+		}
+		session.mu.Unlock()
+		w.attachEventChannel(broadcasterID, "", dc)
+	})
+
+	answer, err := answerOffer(pc, offer, preferredVideo)
+	if err != nil {
+		w.ClosePeerConnection(peerID)
+		return "", err
+	}
+
+	fmt.Printf("📡 SFU 송출 시작: %s\n", broadcasterID)
+	return answer, nil
+}
+
+// 방송 종료 시 SFU 세션 정리 (송출, 시청자 연결 모두 종료)
+func (w *WebRTCService) Unpublish(broadcasterID string) {
+	w.mu.Lock()
+	session := w.sessions[broadcasterID]
+	delete(w.sessions, broadcasterID)
+	w.mu.Unlock()
+	if session == nil {
+		return
+	}
+
+	session.mu.Lock()
+	viewerIDs := make([]string, 0, len(session.subscribers))
+	for viewerID := range session.subscribers {
+		viewerIDs = append(viewerIDs, viewerID)
+	}
+	session.subscribers = make(map[string]*sfuSubscriber)
+	recorder := session.recorder
+	session.recorder = nil
+	session.hls = nil
+	session.mu.Unlock()
+
+	if recorder != nil {
+		recorder.close()
+	}
+
+	peers := []*webrtc.PeerConnection{w.detachPeer(publisherPeerID(broadcasterID))}
+	for _, viewerID := range viewerIDs {
+		peers = append(peers, w.detachPeer(subscriberPeerID(broadcasterID, viewerID)))
+	}
+
+	go func() {
+		for _, pc := range peers {
+			if pc != nil {
+				pc.Close()
+			}
+		}
+		fmt.Printf("📡 SFU 세션 종료: %s (시청자 %d명)\n", broadcasterID, len(viewerIDs))
+	}()
+}
+
+// 연결 목록에서 제거 (닫는 것은 호출자가 처리)
+// 같은 ID로 새 연결이 바로 등록되어도 나중에 닫히지 않도록 먼저 떼어 낸다.
+func (w *WebRTCService) detachPeer(peerID string) *webrtc.PeerConnection {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	pc := w.peers[peerID]
+	w.removePeer(peerID)
+	return pc
+}
+
+// 시청자 연결 생성 (서버가 만든 Offer 반환)
+func (w *WebRTCService) Subscribe(broadcasterID string, viewerID string) (string, error) {
+	pc, err := w.newSubscriber(broadcasterID, viewerID)
+	if err != nil {
+		return "", err
+	}
+
+	// 서버가 Offer를 만들 때는 이벤트 채널도 서버가 연다
+	if err := w.createEventChannel(broadcasterID, viewerID, pc); err != nil {
+		w.Unsubscribe(broadcasterID, viewerID)
+		return "", err
+	}
+
+	offer, err := pc.CreateOffer(nil)
+	if err != nil {
+		w.Unsubscribe(broadcasterID, viewerID)
+		return "", fmt.Errorf("failed to create offer: %v", err)
+	}
+
+	gatherComplete := webrtc.GatheringCompletePromise(pc)
+	if err := pc.SetLocalDescription(offer); err != nil {
+		w.Unsubscribe(broadcasterID, viewerID)
+		return "", fmt.Errorf("failed to set local description: %v", err)
+	}
+	waitGathering(gatherComplete)
+
+	return pc.LocalDescription().SDP, nil
+}
+
+// 시청자가 보낸 Offer로 연결 생성 (WHEP, Answer 반환)
+func (w *WebRTCService) SubscribeWithOffer(broadcasterID string, viewerID string, offer string) (string, error) {
+	pc, err := w.newSubscriber(broadcasterID, viewerID)
+	if err != nil {
+		return "", err
+	}
+
+	answer, err := answerOffer(pc, offer, "")
+	if err != nil {
+		w.Unsubscribe(broadcasterID, viewerID)
+		return "", err
+	}
+	return answer, nil
+}
+
+// 시청자용 송신 전용 연결을 만들어 세션에 등록
+func (w *WebRTCService) newSubscriber(broadcasterID string, viewerID string) (*webrtc.PeerConnection, error) {
+	w.mu.Lock()
+	session := w.sessions[broadcasterID]
+	w.mu.Unlock()
+	if session == nil {
+		return nil, ErrSFUSessionNotFound
+	}
+
+	peerID := subscriberPeerID(broadcasterID, viewerID)
+	w.ClosePeerConnection(peerID)
+
+	pc, estimator, getter, err := w.newPeerConnection()
+	if err != nil {
+		return nil, err
+	}
+
+	subscriber := &sfuSubscriber{
+		pc:      pc,
+		senders: make(map[webrtc.RTPCodecType]*webrtc.RTPSender),
+		video:   &sfuVideoForwarder{auto: true},
+	}
+
+	// 방송자 트랙이 아직 없으면 빈 송신 트랜시버를 만들어 두고, 트랙이 들어오면 교체
+	session.mu.Lock()
+	if session.videoCodec.MimeType != "" {
+		subscriber.video.track, err = newSFUVideoTrack(session)
+	}
+	for _, kind := range []webrtc.RTPCodecType{webrtc.RTPCodecTypeAudio, webrtc.RTPCodecTypeVideo} {
+		if err != nil {
+			break
+		}
+		track := session.tracks[kind]
+		if kind == webrtc.RTPCodecTypeVideo {
+			track = subscriber.video.track
+		}
+
+		var transceiver *webrtc.RTPTransceiver
+		if track != nil {
+			transceiver, err = pc.AddTransceiverFromTrack(track, webrtc.RTPTransceiverInit{Direction: webrtc.RTPTransceiverDirectionSendonly})
+		} else {
+			transceiver, err = pc.AddTransceiverFromKind(kind, webrtc.RTPTransceiverInit{Direction: webrtc.RTPTransceiverDirectionSendonly})
+		}
+		if err == nil {
+			subscriber.senders[kind] = transceiver.Sender()
+		}
+	}
+	if err == nil {
+		session.subscribers[viewerID] = subscriber
+		session.selectInitialLayer(subscriber.video)
+	}
+	session.mu.Unlock()
+
+	if err != nil {
+		pc.Close()
+		return nil, fmt.Errorf("failed to add transceiver: %v", err)
+	}
+
+	w.mu.Lock()
+	w.addPeer(peerID, pc, getter)
+	w.mu.Unlock()
+
+	// 시청자 쪽 PLI/FIR은 방송자에게 키프레임 요청으로, REMB는 레이어 선택에 사용
+	for _, sender := range subscriber.senders {
+		go session.readSubscriberRTCP(subscriber, sender)
+	}
+	if estimator != nil {
+		estimator.OnTargetBitrateChange(func(bitrate int) {
+			session.updateEstimate(subscriber, bitrate)
+		})
+	}
+
+	// WHEP처럼 시청자가 Offer를 만들면 시청자가 연 이벤트 채널 사용
+	pc.OnDataChannel(func(dc *webrtc.DataChannel) {
+		if dc.Label() != eventChannelLabel {
+			return
+		}
+		session.mu.Lock()
+		subscriber.events = dc
+		session.mu.Unlock()
+		w.attachEventChannel(broadcasterID, viewerID, dc)
+	})
+
+	pc.OnConnectionStateChange(func(state webrtc.PeerConnectionState) {
+		fmt.Printf("📡 SFU 시청 연결 상태 (%s -> %s): %s\n", broadcasterID, viewerID, state.String())
+		switch state {
+		case webrtc.PeerConnectionStateConnected:
+			session.requestLayerKeyframe(subscriber.video.wantedLayer())
+		case webrtc.PeerConnectionStateFailed:
+			// 연결이 끊긴 시청자는 전달 대상에서 제외
+			if w.closePeer(peerID, pc) {
+				session.mu.Lock()
+				if session.subscribers[viewerID] == subscriber {
+					delete(session.subscribers, viewerID)
+				}
+				session.mu.Unlock()
+				w.peerClosed(peerID)
+			}
+		}
+	})
+
+	return pc, nil
+}
+
+// 시청자의 Answer 적용
+func (w *WebRTCService) SetSubscriberAnswer(broadcasterID string, viewerID string, answer string) error {
+	w.mu.Lock()
+	pc := w.peers[subscriberPeerID(broadcasterID, viewerID)]
+	w.mu.Unlock()
+	if pc == nil {
+		return ErrSFUPeerNotFound
+	}
+
+	return pc.SetRemoteDescription(webrtc.SessionDescription{
+		Type: webrtc.SDPTypeAnswer,
+		SDP:  answer,
+	})
+}
+
+// SFU 연결에 ICE 후보 추가 (viewerID가 비어 있으면 방송자 송출 연결)
+func (w *WebRTCService) AddSFUCandidate(broadcasterID string, viewerID string, candidate webrtc.ICECandidateInit) error {
+	peerID := publisherPeerID(broadcasterID)
+	if viewerID != "" {
+		peerID = subscriberPeerID(broadcasterID, viewerID)
+	}
+
+	w.mu.Lock()
+	pc := w.peers[peerID]
+	w.mu.Unlock()
+	if pc == nil {
+		return ErrSFUPeerNotFound
+	}
+
+	return pc.AddICECandidate(candidate)
+}
+
+// 시청자 연결 종료
+func (w *WebRTCService) Unsubscribe(broadcasterID string, viewerID string) {
+	w.mu.Lock()
+	session := w.sessions[broadcasterID]
+	w.mu.Unlock()
+
+	if session != nil {
+		session.mu.Lock()
+		delete(session.subscribers, viewerID)
+		session.mu.Unlock()
+	}
+
+	if pc := w.detachPeer(subscriberPeerID(broadcasterID, viewerID)); pc != nil {
+		go pc.Close()
+	}
+}
+
+// SFU 현황 (API용)
+func (w *WebRTCService) GetSFUStats() map[string]interface{} {
+	w.mu.Lock()
+	sessions := make([]*sfuSession, 0, len(w.sessions))
+	for _, session := range w.sessions {
+		sessions = append(sessions, session)
+	}
+	peers := len(w.peers)
+	w.mu.Unlock()
+
+	subscribers := make(map[string]int)
+	layers := make(map[string][]map[string]interface{})
+	for _, session := range sessions {
+		session.mu.RLock()
+		subscribers[session.broadcasterID] = len(session.subscribers)
+		layers[session.broadcasterID] = session.layerInfo()
+		session.mu.RUnlock()
+	}
+
+	return map[string]interface{}{
+		"sessions":    len(sessions),
+		"peers":       peers,
+		"subscribers": subscribers,
+		"layers":      layers,
+	}
+}
+
+// 방송자 트랙을 공유 트랙으로 전달 (영상은 레이어별로 시청자에게 전달)
+func (s *sfuSession) forward(remote *webrtc.TrackRemote) {
+	kind := remote.Kind()
+	if kind == webrtc.RTPCodecTypeVideo {
+		s.forwardVideo(remote)
+		return
+	}
+
+	s.mu.Lock()
+	s.audioSSRC = remote.SSRC()
+	local := s.tracks[kind]
+	if local == nil || local.Codec().MimeType != remote.Codec().MimeType {
+		track, err := webrtc.NewTrackLocalStaticRTP(remote.Codec().RTPCodecCapability, kind.String(), "sfu-"+s.broadcasterID)
+		if err != nil {
+			s.mu.Unlock()
+			fmt.Printf("❌ SFU 트랙 생성 실패 (%s): %v\n", s.broadcasterID, err)
+			return
+		}
+		local = track
+		s.tracks[kind] = local
+
+		// 이미 연결된 시청자의 송신 트랙 교체
+		for viewerID, subscriber := range s.subscribers {
+			if sender := subscriber.senders[kind]; sender != nil {
+				if err := sender.ReplaceTrack(local); err != nil {
+					fmt.Printf("❌ SFU 트랙 교체 실패 (%s -> %s): %v\n", s.broadcasterID, viewerID, err)
+				}
+			}
+		}
+	}
+	s.mu.Unlock()
+
+	mimeType := remote.Codec().MimeType
+	fmt.Printf("📡 SFU 트랙 수신 (%s): %s %s\n", s.broadcasterID, kind.String(), mimeType)
+
+	for {
+		packet, _, err := remote.ReadRTP()
+		if err != nil {
+			fmt.Printf("📡 SFU 트랙 종료 (%s): %s\n", s.broadcasterID, kind.String())
+			return
+		}
+		recorder, hls := s.outputs()
+		if recorder != nil {
+			recorder.write(kind, mimeType, packet)
+		}
+		if hls != nil {
+			hls.push(kind, mimeType, packet)
+		}
+		if err := local.WriteRTP(packet); err != nil && !errors.Is(err, io.ErrClosedPipe) {
+			fmt.Printf("❌ SFU 전달 실패 (%s): %v\n", s.broadcasterID, err)
+			return
+		}
+	}
+}
+
+// 방송 출력 (녹화, HLS) 조회
+func (s *sfuSession) outputs() (*sfuRecorder, *hlsPackager) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.recorder, s.hls
+}
+
+// 방송자에게 모든 레이어의 키프레임 요청 (PLI)
+func (s *sfuSession) requestKeyframe() {
+	s.mu.RLock()
+	publisher := s.publisher
+	packets := make([]rtcp.Packet, 0, len(s.layers))
+	for _, layer := range s.layers {
+		packets = append(packets, &rtcp.PictureLossIndication{MediaSSRC: uint32(layer.ssrc)})
+	}
+	s.mu.RUnlock()
+
+	if publisher == nil || len(packets) == 0 {
+		return
+	}
+	publisher.WriteRTCP(packets)
+}
+
+// 방송자에게 한 레이어의 키프레임 요청 (PLI)
+func (s *sfuSession) requestLayerKeyframe(rid string) {
+	s.mu.RLock()
+	publisher := s.publisher
+	layer := s.layers[rid]
+	s.mu.RUnlock()
+
+	if publisher == nil || layer == nil {
+		return
+	}
+	publisher.WriteRTCP([]rtcp.Packet{&rtcp.PictureLossIndication{MediaSSRC: uint32(layer.ssrc)}})
+}
+
+// 시청자 RTCP 읽기 (PLI/FIR이면 보고 있는 레이어의 키프레임 요청, REMB면 대역 추정 갱신)
+func (s *sfuSession) readSubscriberRTCP(subscriber *sfuSubscriber, sender *webrtc.RTPSender) {
+	for {
+		packets, _, err := sender.ReadRTCP()
+		if err != nil {
+			return
+		}
+		for _, packet := range packets {
+			switch p := packet.(type) {
+			case *rtcp.PictureLossIndication, *rtcp.FullIntraRequest:
+				s.requestLayerKeyframe(subscriber.video.wantedLayer())
+			case *rtcp.ReceiverEstimatedMaximumBitrate:
+				s.updateEstimate(subscriber, int(p.Bitrate))
+			}
+		}
+	}
+}
+
+// Offer에 대한 Answer 생성 (ICE 후보 수집이 끝난 SDP 반환)
+// preferredVideo가 있으면 상대가 지원하는 경우 해당 영상 코덱을 우선 선택한다.
+func answerOffer(pc *webrtc.PeerConnection, offer string, preferredVideo string) (string, error) {
+	err := pc.SetRemoteDescription(webrtc.SessionDescription{
+		Type: webrtc.SDPTypeOffer,
+		SDP:  offer,
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to set remote description: %v", err)
+	}
+
+	if preferredVideo != "" {
+		preferVideoCodec(pc, preferredVideo)
+	}
+
+	answer, err := pc.CreateAnswer(nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to create answer: %v", err)
+	}
+
+	gatherComplete := webrtc.GatheringCompletePromise(pc)
+	if err := pc.SetLocalDescription(answer); err != nil {
+		return "", fmt.Errorf("failed to set local description: %v", err)
+	}
+	waitGathering(gatherComplete)
+
+	return pc.LocalDescription().SDP, nil
+}
+
+// 영상 트랜시버의 코덱 우선순위에서 지정한 코덱을 앞으로 이동
+func preferVideoCodec(pc *webrtc.PeerConnection, mimeType string) {
+	for _, transceiver := range pc.GetTransceivers() {
+		if transceiver.Kind() != webrtc.RTPCodecTypeVideo || transceiver.Receiver() == nil {
+			continue
+		}
+
+		codecs := transceiver.Receiver().GetParameters().Codecs
+		sort.SliceStable(codecs, func(i, j int) bool {
+			return codecs[i].MimeType == mimeType && codecs[j].MimeType != mimeType
+		})
+		if err := transceiver.SetCodecPreferences(codecs); err != nil {
+			fmt.Printf("⚠️ 코덱 우선순위 설정 실패: %v\n", err)
+		}
+	}
+}
+
+// ICE 후보 수집 완료 대기 (시간이 지나면 수집된 후보까지만 사용)
+func waitGathering(gatherComplete <-chan struct{}) {
+	select {
+	case <-gatherComplete:
+	case <-time.After(iceGatheringTimeout):
+	}
+}
+
+// 메시지에서 SDP 추출 ("..." 또는 {"sdp":"..."})
+func sdpFromMessage(msg *Message) string {
+	if data, ok := msg.Data.(map[string]interface{}); ok {
+		return toString(data["sdp"])
+	}
+	return toString(msg.Data)
+}
+
+// 메시지에서 ICE 후보 추출 ("..." 또는 {"candidate":"...","sdpMid":"...","sdpMLineIndex":0})
+func candidateFromMessage(msg *Message) (webrtc.ICECandidateInit, bool) {
+	data, ok := msg.Data.(map[string]interface{})
+	if !ok {
+		candidate := toString(msg.Data)
+		return webrtc.ICECandidateInit{Candidate: candidate}, candidate != ""
+	}
+
+	// {"candidate":{"candidate":"..."}} 형태도 허용
+	if nested, ok := data["candidate"].(map[string]interface{}); ok {
+		data = nested
+	}
+
+	init := webrtc.ICECandidateInit{Candidate: toString(data["candidate"])}
+	if mid, ok := data["sdpMid"].(string); ok {
+		init.SDPMid = &mid
+	}
+	if index, ok := data["sdpMLineIndex"].(float64); ok {
+		lineIndex := uint16(index)
+		init.SDPMLineIndex = &lineIndex
+	}
+	return init, init.Candidate != ""
+}
+
+// 메시지 data 필드에서 전송 방식 추출 (없으면 서버 기본값)
+func broadcastModeFromMessage(msg *Message) (string, error) {
+	mode := config.BroadcastMode
+	if data, ok := msg.Data.(map[string]interface{}); ok {
+		if value := toString(data["mode"]); value != "" {
+			mode = value
+		}
+	}
+	if mode != BroadcastModeMesh && mode != BroadcastModeSFU {
+		return "", fmt.Errorf("전송 방식은 mesh 또는 sfu 중 하나여야 합니다")
+	}
+	return mode, nil
+}
+
+// SFU 방송 여부 (호출자가 잠금을 보유해야 함)
+func (wsService *WebSocketService) usesSFU(broadcasterID string) bool {
+	broadcast := wsService.ActiveBroadcasts[broadcasterID]
+	return broadcast != nil && broadcast.Mode == BroadcastModeSFU
+}
+
+// 방송자 송출 처리 (publish)
+func (wsService *WebSocketService) publishStream(broadcasterID string, msg *Message) {
+	wsService.Mutex.RLock()
+	broadcaster := wsService.Broadcasters[broadcasterID]
+	sfu := wsService.usesSFU(broadcasterID)
+	wsService.Mutex.RUnlock()
+	if broadcaster == nil {
+		return
+	}
+
+	if !sfu {
+		wsService.sendToConnection(broadcaster, &Message{
+			Type: "error",
+			Data: "SFU 모드로 시작한 방송만 송출할 수 있습니다.",
+		})
+		return
+	}
+
+	offer := sdpFromMessage(msg)
+	if offer == "" {
+		wsService.sendToConnection(broadcaster, &Message{
+			Type: "error",
+			Data: "SDP가 없습니다.",
+		})
+		return
+	}
+
+	answer, err := wsService.webrtc.Publish(broadcasterID, offer)
+	if err != nil {
+		fmt.Printf("❌ SFU 송출 실패 (%s): %v\n", broadcasterID, err)
+		wsService.sendToConnection(broadcaster, &Message{
+			Type: "error",
+			Data: "송출 연결을 만들지 못했습니다.",
+		})
+		return
+	}
+
+	wsService.sendToConnection(broadcaster, &Message{
+		Type:          "publish_answer",
+		BroadcasterID: broadcasterID,
+		Data: map[string]interface{}{
+			"type": "answer",
+			"sdp":  answer,
+		},
+	})
+}
+
+// 시청자용 서버 연결을 만들고 Offer 전송 (ICE 수집을 기다리므로 잠금 밖에서 실행)
+func (wsService *WebSocketService) subscribeViewer(broadcasterID string, viewerID string, viewer *ViewerInfo) {
+	offer, err := wsService.webrtc.Subscribe(broadcasterID, viewerID)
+	if err != nil {
+		fmt.Printf("❌ SFU 시청 연결 실패 (%s -> %s): %v\n", broadcasterID, viewerID, err)
+		wsService.sendToConnection(viewer.Connection, &Message{
+			Type: "error",
+			Data: "시청 연결을 만들지 못했습니다.",
+		})
+		return
+	}
+
+	// 연결을 만드는 동안 나갔으면 정리
+	wsService.Mutex.RLock()
+	current := wsService.Viewers[viewerID] == viewer && viewer.admitted && viewer.BroadcasterID == broadcasterID
+	wsService.Mutex.RUnlock()
+	if !current {
+		wsService.webrtc.Unsubscribe(broadcasterID, viewerID)
+		return
+	}
+
+	wsService.sendToConnection(viewer.Connection, &Message{
+		Type:          "offer",
+		BroadcasterID: broadcasterID,
+		Data: map[string]interface{}{
+			"type": "offer",
+			"sdp":  offer,
+			"mode": BroadcastModeSFU,
+		},
+	})
+}
+
+// 시청자의 Answer/ICE 후보를 서버 연결에 적용 (SFU 방송이 아니면 false)
+func (wsService *WebSocketService) handleSFUViewerSignal(viewerID string, msg *Message) bool {
+	wsService.Mutex.RLock()
+	viewer := wsService.Viewers[viewerID]
+	broadcasterID := ""
+	if viewer != nil {
+		broadcasterID = viewer.BroadcasterID
+	}
+	sfu := wsService.usesSFU(broadcasterID)
+	wsService.Mutex.RUnlock()
+	if !sfu {
+		return false
+	}
+
+	var err error
+	switch msg.Type {
+	case "answer":
+		err = wsService.webrtc.SetSubscriberAnswer(broadcasterID, viewerID, sdpFromMessage(msg))
+	case "candidate":
+		if candidate, ok := candidateFromMessage(msg); ok {
+			err = wsService.webrtc.AddSFUCandidate(broadcasterID, viewerID, candidate)
+		}
+	}
+	if err != nil {
+		fmt.Printf("❌ SFU 시청자 시그널 처리 실패 (%s, %s): %v\n", viewerID, msg.Type, err)
+	}
+	return true
+}
+
+// 방송자의 송출 연결 ICE 후보 적용 (SFU 방송이 아니거나 특정 시청자 대상이면 false)
+func (wsService *WebSocketService) handleSFUBroadcasterCandidate(broadcasterID string, msg *Message) bool {
+	if toString(msg.ViewerID) != "" {
+		return false
+	}
+
+	wsService.Mutex.RLock()
+	sfu := wsService.usesSFU(broadcasterID)
+	wsService.Mutex.RUnlock()
+	if !sfu {
+		return false
+	}
+
+	if candidate, ok := candidateFromMessage(msg); ok {
+		if err := wsService.webrtc.AddSFUCandidate(broadcasterID, "", candidate); err != nil {
+			fmt.Printf("❌ SFU 송출 ICE 후보 처리 실패 (%s): %v\n", broadcasterID, err)
+		}
+	}
+	return true
+}
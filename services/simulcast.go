@@ -0,0 +1,496 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/pion/rtp"
+	"github.com/pion/webrtc/v3"
+)
+
+// 레이어 선택
+const (
+	// 레이어 전송률 측정 주기
+	layerMeasureInterval = time.Second
+	// 이 시간 동안 패킷이 없으면 방송자가 보내지 않는 레이어로 간주
+	layerInactiveTimeout = 2 * time.Second
+	// 자동 선택 시 상위 레이어로 올리기 전 최소 유지 시간 (하위로는 바로 내림)
+	layerUpgradeHold = 3 * time.Second
+	// 대역 추정이 레이어 전송률보다 이 비율만큼 여유가 있어야 선택
+	layerBitrateHeadroom = 1.15
+)
+
+// 시청자가 요청하는 화질 (simulcast rid를 직접 지정할 수도 있음)
+const (
+	QualityAuto   = "auto"
+	QualityHigh   = "high"
+	QualityMedium = "medium"
+	QualityLow    = "low"
+)
+
+var ErrLayerNotFound = errors.New("선택할 수 있는 화질이 없습니다")
+
+// 방송자가 보내는 영상 레이어 하나 (simulcast rid별 수신 스트림, 세션 잠금으로 보호됨)
+type sfuLayer struct {
+	rid        string
+	ssrc       webrtc.SSRC
+	bitrate    int
+	lastPacket time.Time
+}
+
+// 시청자 한 명에게 보내는 영상 (레이어를 바꿔도 시퀀스 번호와 타임스탬프가 이어지도록 보정)
+type sfuVideoForwarder struct {
+	mu    sync.Mutex
+	track *webrtc.TrackLocalStaticRTP
+
+	// 전달 중인 레이어와 전환할 레이어 (전환할 레이어의 키프레임이 오면 바뀜)
+	current string
+	target  string
+
+	// 대역 추정에 따른 자동 선택 여부와 최근 추정값 (bps, 0이면 아직 없음)
+	auto       bool
+	estimate   int
+	lastSwitch time.Time
+
+	started   bool
+	lastSeq   uint16
+	lastTS    uint32
+	lastWrite time.Time
+	clockRate uint32
+	seqOffset uint16
+	tsOffset  uint32
+}
+
+// 시청자별 영상 트랙 생성 (호출자가 잠금을 보유해야 함)
+func newSFUVideoTrack(s *sfuSession) (*webrtc.TrackLocalStaticRTP, error) {
+	return webrtc.NewTrackLocalStaticRTP(s.videoCodec, "video", "sfu-"+s.broadcasterID)
+}
+
+// 레이어 이름 순위 (알려진 이름이 아니면 0)
+func layerRank(rid string) int {
+	switch rid {
+	case "f", "high", "hi", "2":
+		return 3
+	case "h", "mid", "medium", "1":
+		return 2
+	case "q", "low", "lo", "0":
+		return 1
+	}
+	return 0
+}
+
+// 방송자 영상 레이어를 받아 시청자별로 전달
+func (s *sfuSession) forwardVideo(remote *webrtc.TrackRemote) {
+	rid := remote.RID()
+	codec := remote.Codec().RTPCodecCapability
+
+	s.mu.Lock()
+	if s.videoCodec.MimeType != codec.MimeType {
+		s.videoCodec = codec
+
+		// 이미 연결된 시청자의 영상 트랙 교체
+		for viewerID, subscriber := range s.subscribers {
+			track, err := newSFUVideoTrack(s)
+			if err == nil {
+				err = subscriber.senders[webrtc.RTPCodecTypeVideo].ReplaceTrack(track)
+			}
+			if err != nil {
+				fmt.Printf("❌ SFU 트랙 교체 실패 (%s -> %s): %v\n", s.broadcasterID, viewerID, err)
+				continue
+			}
+			subscriber.video.setTrack(track)
+		}
+	}
+
+	layer := s.layers[rid]
+	if layer == nil {
+		layer = &sfuLayer{rid: rid}
+		s.layers[rid] = layer
+	}
+	layer.ssrc = remote.SSRC()
+	layer.lastPacket = time.Now()
+	if s.primaryLayer == "" || (layerRank(rid) > layerRank(s.primaryLayer) && s.layers[s.primaryLayer] != nil) {
+		s.primaryLayer = rid
+	}
+	for _, subscriber := range s.subscribers {
+		s.selectInitialLayer(subscriber.video)
+	}
+	s.mu.Unlock()
+
+	mimeType := codec.MimeType
+	if rid != "" {
+		fmt.Printf("📡 SFU 트랙 수신 (%s): video %s, 레이어 %s\n", s.broadcasterID, mimeType, rid)
+	} else {
+		fmt.Printf("📡 SFU 트랙 수신 (%s): video %s\n", s.broadcasterID, mimeType)
+	}
+	s.requestLayerKeyframe(rid)
+
+	bytes := 0
+	windowStart := time.Now()
+	for {
+		packet, _, err := remote.ReadRTP()
+		if err != nil {
+			fmt.Printf("📡 SFU 트랙 종료 (%s): video %s\n", s.broadcasterID, rid)
+			return
+		}
+
+		// 레이어 전송률 측정 후 자동 선택 시청자의 레이어 재평가
+		bytes += len(packet.Payload)
+		if elapsed := time.Since(windowStart); elapsed >= layerMeasureInterval {
+			s.mu.Lock()
+			layer.bitrate = int(float64(bytes*8) / elapsed.Seconds())
+			layer.lastPacket = time.Now()
+			s.mu.Unlock()
+			bytes = 0
+			windowStart = time.Now()
+			s.adaptLayers()
+		}
+
+		s.mu.RLock()
+		primary := s.primaryLayer == rid
+		recorder, hls := s.recorder, s.hls
+		s.mu.RUnlock()
+		if primary {
+			if recorder != nil {
+				recorder.write(webrtc.RTPCodecTypeVideo, mimeType, packet)
+			}
+			if hls != nil {
+				hls.push(webrtc.RTPCodecTypeVideo, mimeType, packet)
+			}
+		}
+
+		keyframe := isKeyframePacket(mimeType, packet.Payload)
+		s.mu.RLock()
+		for _, subscriber := range s.subscribers {
+			subscriber.video.write(rid, packet, keyframe, codec.ClockRate)
+		}
+		s.mu.RUnlock()
+	}
+}
+
+// 레이어를 높은 화질부터 정렬 (이름 순위, 같으면 전송률 순, 호출자가 잠금을 보유해야 함)
+// active가 true면 최근 패킷이 들어온 레이어만 포함한다.
+func (s *sfuSession) orderedLayers(active bool) []*sfuLayer {
+	now := time.Now()
+	layers := make([]*sfuLayer, 0, len(s.layers))
+	for _, layer := range s.layers {
+		if active && now.Sub(layer.lastPacket) > layerInactiveTimeout {
+			continue
+		}
+		layers = append(layers, layer)
+	}
+	sort.Slice(layers, func(i, j int) bool {
+		if ri, rj := layerRank(layers[i].rid), layerRank(layers[j].rid); ri != rj {
+			return ri > rj
+		}
+		return layers[i].bitrate > layers[j].bitrate
+	})
+	return layers
+}
+
+// 레이어 현황 (API용, 호출자가 잠금을 보유해야 함)
+func (s *sfuSession) layerInfo() []map[string]interface{} {
+	layers := s.orderedLayers(false)
+	info := make([]map[string]interface{}, 0, len(layers))
+	for _, layer := range layers {
+		info = append(info, map[string]interface{}{
+			"rid":     layer.rid,
+			"bitrate": layer.bitrate,
+			"active":  time.Since(layer.lastPacket) <= layerInactiveTimeout,
+			"primary": layer.rid == s.primaryLayer,
+		})
+	}
+	return info
+}
+
+// 대역 추정에 맞는 레이어 (여유가 있는 가장 높은 레이어, 없으면 가장 낮은 레이어, 호출자가 잠금을 보유해야 함)
+func (s *sfuSession) layerForEstimate(estimate int) string {
+	layers := s.orderedLayers(true)
+	if len(layers) == 0 {
+		return ""
+	}
+	if estimate <= 0 {
+		return layers[0].rid
+	}
+	for _, layer := range layers {
+		if float64(layer.bitrate)*layerBitrateHeadroom <= float64(estimate) {
+			return layer.rid
+		}
+	}
+	return layers[len(layers)-1].rid
+}
+
+// 전환할 레이어가 없거나 사라졌으면 새로 지정 (호출자가 세션 잠금을 보유해야 함)
+func (s *sfuSession) selectInitialLayer(video *sfuVideoForwarder) {
+	video.mu.Lock()
+	defer video.mu.Unlock()
+
+	if video.target != "" && s.layers[video.target] != nil {
+		return
+	}
+	video.target = s.layerForEstimate(video.estimate)
+}
+
+// 대역 추정 갱신 (REMB 또는 TWCC 기반 추정)
+func (s *sfuSession) updateEstimate(subscriber *sfuSubscriber, bitrate int) {
+	subscriber.video.mu.Lock()
+	subscriber.video.estimate = bitrate
+	subscriber.video.mu.Unlock()
+
+	s.adaptLayer(subscriber.video)
+}
+
+// 자동 선택 시청자 모두의 레이어 재평가
+func (s *sfuSession) adaptLayers() {
+	s.mu.RLock()
+	videos := make([]*sfuVideoForwarder, 0, len(s.subscribers))
+	for _, subscriber := range s.subscribers {
+		videos = append(videos, subscriber.video)
+	}
+	s.mu.RUnlock()
+
+	for _, video := range videos {
+		s.adaptLayer(video)
+	}
+}
+
+// 대역 추정과 레이어 상태에 맞춰 전환할 레이어 변경 (바뀌면 키프레임 요청)
+func (s *sfuSession) adaptLayer(video *sfuVideoForwarder) {
+	s.mu.RLock()
+	video.mu.Lock()
+	if !video.auto {
+		video.mu.Unlock()
+		s.mu.RUnlock()
+		return
+	}
+
+	next := s.layerForEstimate(video.estimate)
+	switch {
+	case next == "" || next == video.target:
+		next = ""
+	case s.layers[video.target] != nil && time.Since(s.layers[video.target].lastPacket) <= layerInactiveTimeout &&
+		layerIsHigher(s, next, video.target) && time.Since(video.lastSwitch) < layerUpgradeHold:
+		// 방금 바꿨으면 상위 레이어로는 잠시 뒤에 올림
+		next = ""
+	}
+	if next != "" {
+		video.target = next
+		video.lastSwitch = time.Now()
+	}
+	video.mu.Unlock()
+	s.mu.RUnlock()
+
+	if next != "" {
+		fmt.Printf("🎚️ SFU 레이어 전환 (%s): %s (추정 %d bps)\n", s.broadcasterID, next, video.estimate)
+		s.requestLayerKeyframe(next)
+	}
+}
+
+// a가 b보다 높은 화질인지 (호출자가 잠금을 보유해야 함)
+func layerIsHigher(s *sfuSession, a string, b string) bool {
+	for _, layer := range s.orderedLayers(false) {
+		switch layer.rid {
+		case a:
+			return true
+		case b:
+			return false
+		}
+	}
+	return false
+}
+
+// 시청자 화질 선택 (auto, high, medium, low 또는 rid), 선택된 레이어 반환
+func (w *WebRTCService) SelectLayer(broadcasterID string, viewerID string, quality string) (string, []map[string]interface{}, error) {
+	w.mu.Lock()
+	session := w.sessions[broadcasterID]
+	w.mu.Unlock()
+	if session == nil {
+		return "", nil, ErrSFUSessionNotFound
+	}
+
+	session.mu.RLock()
+	subscriber := session.subscribers[viewerID]
+	if subscriber == nil {
+		session.mu.RUnlock()
+		return "", nil, ErrSFUPeerNotFound
+	}
+
+	layers := session.orderedLayers(true)
+	rid := ""
+	switch quality {
+	case QualityAuto:
+		rid = session.layerForEstimate(subscriber.video.currentEstimate())
+	case QualityHigh:
+		if len(layers) > 0 {
+			rid = layers[0].rid
+		}
+	case QualityMedium:
+		if len(layers) > 0 {
+			rid = layers[len(layers)/2].rid
+		}
+	case QualityLow:
+		if len(layers) > 0 {
+			rid = layers[len(layers)-1].rid
+		}
+	default:
+		if session.layers[quality] != nil {
+			rid = quality
+		}
+	}
+	info := session.layerInfo()
+
+	found := session.layers[rid] != nil
+	if found {
+		subscriber.video.mu.Lock()
+		subscriber.video.auto = quality == QualityAuto
+		subscriber.video.target = rid
+		subscriber.video.lastSwitch = time.Now()
+		subscriber.video.mu.Unlock()
+	}
+	session.mu.RUnlock()
+
+	if !found {
+		return "", info, ErrLayerNotFound
+	}
+
+	session.requestLayerKeyframe(rid)
+	return rid, info, nil
+}
+
+// 시청자 메시지에서 화질 추출 ({"type":"select_quality","data":{"quality":"low"}} 또는 "low")
+func qualityFromMessage(msg *Message) string {
+	if data, ok := msg.Data.(map[string]interface{}); ok {
+		return toString(data["quality"])
+	}
+	return toString(msg.Data)
+}
+
+// 시청자 화질 선택 처리 (select_quality)
+func (wsService *WebSocketService) handleSelectQuality(viewerID string, msg *Message) {
+	wsService.Mutex.RLock()
+	viewer := wsService.Viewers[viewerID]
+	broadcasterID := ""
+	if viewer != nil {
+		broadcasterID = viewer.BroadcasterID
+	}
+	sfu := wsService.usesSFU(broadcasterID)
+	wsService.Mutex.RUnlock()
+	if viewer == nil {
+		return
+	}
+
+	if !sfu {
+		wsService.sendToConnection(viewer.Connection, &Message{
+			Type: "error",
+			Data: "SFU 방송에서만 화질을 선택할 수 있습니다.",
+		})
+		return
+	}
+
+	quality := qualityFromMessage(msg)
+	if quality == "" {
+		quality = QualityAuto
+	}
+	layer, layers, err := wsService.webrtc.SelectLayer(broadcasterID, viewerID, quality)
+	if err != nil {
+		fmt.Printf("❌ 화질 선택 실패 (%s, %s): %v\n", viewerID, quality, err)
+		wsService.sendToConnection(viewer.Connection, &Message{
+			Type:          "quality_error",
+			BroadcasterID: broadcasterID,
+			Data: map[string]interface{}{
+				"quality": quality,
+				"error":   err.Error(),
+				"layers":  layers,
+			},
+		})
+		return
+	}
+
+	wsService.sendToConnection(viewer.Connection, &Message{
+		Type:          "quality_selected",
+		BroadcasterID: broadcasterID,
+		Data: map[string]interface{}{
+			"quality": quality,
+			"layer":   layer,
+			"layers":  layers,
+		},
+	})
+}
+
+// 영상 트랙 교체 (코덱이 바뀐 경우, 다음 키프레임부터 다시 전달)
+func (f *sfuVideoForwarder) setTrack(track *webrtc.TrackLocalStaticRTP) {
+	f.mu.Lock()
+	f.track = track
+	f.started = false
+	f.mu.Unlock()
+}
+
+// 전환 중이면 전환할 레이어, 아니면 전달 중인 레이어
+func (f *sfuVideoForwarder) wantedLayer() string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.target
+}
+
+// 시청자가 받는 영상의 현재 RTP 타임스탬프 추정 (아직 보낸 영상이 없으면 false)
+func (f *sfuVideoForwarder) mediaTimestamp() (uint32, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if !f.started {
+		return 0, false
+	}
+	return f.lastTS + uint32(time.Since(f.lastWrite).Seconds()*float64(f.clockRate)), true
+}
+
+func (f *sfuVideoForwarder) currentEstimate() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.estimate
+}
+
+// 레이어 패킷 전달 (전환할 레이어의 키프레임부터 전환, 나머지 레이어는 버림)
+func (f *sfuVideoForwarder) write(rid string, packet *rtp.Packet, keyframe bool, clockRate uint32) {
+	f.mu.Lock()
+	track := f.track
+	if track == nil {
+		f.mu.Unlock()
+		return
+	}
+
+	if !f.started || rid != f.current {
+		if rid != f.target || !keyframe {
+			f.mu.Unlock()
+			return
+		}
+
+		// 이전 레이어에서 이어지도록 시퀀스 번호와 타임스탬프 보정
+		if f.started {
+			elapsed := uint32(time.Since(f.lastWrite).Seconds() * float64(clockRate))
+			if elapsed == 0 {
+				elapsed = 1
+			}
+			f.seqOffset = f.lastSeq + 1 - packet.SequenceNumber
+			f.tsOffset = f.lastTS + elapsed - packet.Timestamp
+		} else {
+			f.seqOffset = 0
+			f.tsOffset = 0
+		}
+		f.current = rid
+		f.started = true
+	}
+
+	out := *packet
+	out.SequenceNumber += f.seqOffset
+	out.Timestamp += f.tsOffset
+	f.lastSeq = out.SequenceNumber
+	f.lastTS = out.Timestamp
+	f.lastWrite = time.Now()
+	f.clockRate = clockRate
+	f.mu.Unlock()
+
+	track.WriteRTP(&out)
+}
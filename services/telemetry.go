@@ -0,0 +1,492 @@
+package services
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+	"toysgo/config"
+	"toysgo/global"
+	"toysgo/models"
+
+	"github.com/pion/interceptor/pkg/stats"
+	"github.com/pion/webrtc/v3"
+)
+
+// 클라이언트 품질 보고 최소 간격 (더 자주 보내면 무시)
+const minStatsReportInterval = time.Second
+
+// 품질 등급 기준 (손실률, RTT ms, 지터 ms)
+const (
+	qualityFairLoss   = 0.01
+	qualityPoorLoss   = 0.05
+	qualityFairRTT    = 200
+	qualityPoorRTT    = 400
+	qualityFairJitter = 30
+	qualityPoorJitter = 50
+)
+
+// 품질 등급
+const (
+	QualityGood = "good"
+	QualityFair = "fair"
+	QualityPoor = "poor"
+)
+
+// 연결 하나의 트랙별 품질 측정값
+// rtcp는 서버가 RTCP 보고서로 측정한 값(방송자는 서버 수신, 시청자는 서버 송신 기준), client는 클라이언트 getStats 요약이다.
+type QualitySample struct {
+	PeerID      string    `json:"peer_id"`
+	Role        string    `json:"role"`
+	Source      string    `json:"source"`
+	Kind        string    `json:"kind"`
+	Layer       string    `json:"layer,omitempty"`
+	Bitrate     int64     `json:"bitrate"`
+	PacketsLost int64     `json:"packets_lost"`
+	Loss        float64   `json:"loss"`
+	Jitter      float64   `json:"jitter"`
+	RTT         float64   `json:"rtt"`
+	Framerate   float64   `json:"framerate,omitempty"`
+	Freezes     int       `json:"freezes,omitempty"`
+	Timestamp   time.Time `json:"timestamp"`
+}
+
+// 클라이언트 getStats 요약 (stats_report, 지터와 RTT는 ms, freezes는 지난 보고 이후 멈춤 횟수)
+type clientStatsReport struct {
+	Kind        string  `json:"kind"`
+	Layer       string  `json:"layer"`
+	Bitrate     int64   `json:"bitrate"`
+	PacketsLost int64   `json:"packets_lost"`
+	Loss        float64 `json:"loss"`
+	Jitter      float64 `json:"jitter"`
+	RTT         float64 `json:"rtt"`
+	Framerate   float64 `json:"framerate"`
+	Freezes     int     `json:"freezes"`
+}
+
+// RTP 스트림 하나의 RTCP 통계 (viewer_id가 비어 있으면 방송자 송출)
+type rtpStreamStats struct {
+	broadcasterID string
+	viewerID      string
+	kind          webrtc.RTPCodecType
+	layer         string
+	clockRate     uint32
+	stats         stats.Stats
+}
+
+// SFU 연결의 RTP 스트림 통계 (방송자 수신 스트림과 시청자 송신 스트림)
+func (w *WebRTCService) rtpStats() []rtpStreamStats {
+	w.mu.Lock()
+	sessions := make([]*sfuSession, 0, len(w.sessions))
+	for _, session := range w.sessions {
+		sessions = append(sessions, session)
+	}
+	getters := make(map[string]stats.Getter, len(w.peerStats))
+	for peerID, getter := range w.peerStats {
+		getters[peerID] = getter
+	}
+	w.mu.Unlock()
+
+	result := make([]rtpStreamStats, 0)
+	collect := func(getter stats.Getter, stream rtpStreamStats, ssrc webrtc.SSRC) {
+		if getter == nil || ssrc == 0 {
+			return
+		}
+		// 아직 주고받은 패킷이 없는 스트림은 제외
+		if s := getter.Get(uint32(ssrc)); s != nil && (s.InboundRTPStreamStats.PacketsReceived > 0 || s.OutboundRTPStreamStats.PacketsSent > 0) {
+			stream.stats = *s
+			result = append(result, stream)
+		}
+	}
+
+	for _, session := range sessions {
+		session.mu.RLock()
+		publisher := getters[publisherPeerID(session.broadcasterID)]
+		if track := session.tracks[webrtc.RTPCodecTypeAudio]; track != nil {
+			collect(publisher, rtpStreamStats{
+				broadcasterID: session.broadcasterID,
+				kind:          webrtc.RTPCodecTypeAudio,
+				clockRate:     track.Codec().ClockRate,
+			}, session.audioSSRC)
+		}
+		for _, layer := range session.layers {
+			collect(publisher, rtpStreamStats{
+				broadcasterID: session.broadcasterID,
+				kind:          webrtc.RTPCodecTypeVideo,
+				layer:         layer.rid,
+				clockRate:     session.videoCodec.ClockRate,
+			}, layer.ssrc)
+		}
+
+		for viewerID, subscriber := range session.subscribers {
+			getter := getters[subscriberPeerID(session.broadcasterID, viewerID)]
+			for kind, sender := range subscriber.senders {
+				for _, encoding := range sender.GetParameters().Encodings {
+					collect(getter, rtpStreamStats{
+						broadcasterID: session.broadcasterID,
+						viewerID:      viewerID,
+						kind:          kind,
+					}, encoding.SSRC)
+				}
+			}
+		}
+		session.mu.RUnlock()
+	}
+	return result
+}
+
+// RTCP 누적값 (이전 수집 시점과 비교해 전송률, 손실률 계산)
+type rtpCounter struct {
+	bytes   uint64
+	packets uint64
+	lost    int64
+	at      time.Time
+}
+
+// 방송별 최근 품질
+type qualityStore struct {
+	mu sync.Mutex
+
+	// broadcaster_id -> 측정 키 -> 최근 샘플
+	samples map[string]map[string]QualitySample
+	// 측정 키 -> RTCP 누적값
+	counters map[string]rtpCounter
+	// peer_id -> 마지막 클라이언트 보고 시각
+	lastReport map[string]time.Time
+	// 마지막 DB 저장 시각 (이후 갱신된 샘플만 저장)
+	lastPersist time.Time
+}
+
+func newQualityStore() *qualityStore {
+	return &qualityStore{
+		samples:    make(map[string]map[string]QualitySample),
+		counters:   make(map[string]rtpCounter),
+		lastReport: make(map[string]time.Time),
+	}
+}
+
+func qualityKey(sample QualitySample) string {
+	return sample.PeerID + "|" + sample.Source + "|" + sample.Kind + "|" + sample.Layer
+}
+
+// 샘플 저장 (호출자가 잠금을 보유해야 함)
+func (q *qualityStore) put(broadcasterID string, sample QualitySample) {
+	samples := q.samples[broadcasterID]
+	if samples == nil {
+		samples = make(map[string]QualitySample)
+		q.samples[broadcasterID] = samples
+	}
+	samples[qualityKey(sample)] = sample
+}
+
+// RTCP 통계를 샘플로 변환해 저장 (이전 누적값이 없으면 전송률과 손실률은 0)
+func (q *qualityStore) updateRTCP(streams []rtpStreamStats, now time.Time) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	counters := make(map[string]rtpCounter, len(streams))
+	for _, stream := range streams {
+		sample := QualitySample{
+			PeerID:    stream.viewerID,
+			Role:      "viewer",
+			Source:    models.StreamStatSourceRTCP,
+			Kind:      stream.kind.String(),
+			Layer:     stream.layer,
+			Timestamp: now,
+		}
+
+		var counter rtpCounter
+		if stream.viewerID == "" {
+			// 방송자 -> 서버 (서버가 받은 RTP 기준)
+			inbound := stream.stats.InboundRTPStreamStats
+			sample.PeerID = stream.broadcasterID
+			sample.Role = "broadcaster"
+			sample.PacketsLost = inbound.PacketsLost
+			if stream.clockRate > 0 {
+				sample.Jitter = inbound.Jitter / float64(stream.clockRate) * 1000
+			}
+			sample.RTT = float64(stream.stats.RemoteOutboundRTPStreamStats.RoundTripTime) / float64(time.Millisecond)
+			counter = rtpCounter{bytes: inbound.BytesReceived, packets: inbound.PacketsReceived, lost: inbound.PacketsLost, at: now}
+		} else {
+			// 서버 -> 시청자 (시청자가 보낸 수신 보고서 기준)
+			outbound := stream.stats.OutboundRTPStreamStats
+			remote := stream.stats.RemoteInboundRTPStreamStats
+			sample.PacketsLost = remote.PacketsLost
+			sample.Loss = remote.FractionLost
+			sample.Jitter = remote.Jitter * 1000
+			sample.RTT = float64(remote.RoundTripTime) / float64(time.Millisecond)
+			counter = rtpCounter{bytes: outbound.BytesSent, packets: outbound.PacketsSent, lost: remote.PacketsLost, at: now}
+		}
+
+		key := stream.broadcasterID + "|" + qualityKey(sample)
+		if previous, ok := q.counters[key]; ok && counter.bytes >= previous.bytes {
+			if elapsed := now.Sub(previous.at).Seconds(); elapsed > 0 {
+				sample.Bitrate = int64(float64(counter.bytes-previous.bytes) * 8 / elapsed)
+			}
+			// 방송자 수신 손실률은 구간 손실 / 구간 기대 패킷 수
+			if stream.viewerID == "" && counter.packets >= previous.packets {
+				lost := counter.lost - previous.lost
+				expected := int64(counter.packets-previous.packets) + lost
+				if lost > 0 && expected > 0 {
+					sample.Loss = float64(lost) / float64(expected)
+				}
+			}
+		}
+		counters[key] = counter
+
+		q.put(stream.broadcasterID, sample)
+	}
+	q.counters = counters
+}
+
+// 오래된 샘플과 끝난 방송 정리
+func (q *qualityStore) prune(active map[string]bool, expire time.Time) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	for broadcasterID, samples := range q.samples {
+		if !active[broadcasterID] {
+			delete(q.samples, broadcasterID)
+			continue
+		}
+		for key, sample := range samples {
+			if sample.Timestamp.Before(expire) {
+				delete(samples, key)
+			}
+		}
+	}
+	for peerID, at := range q.lastReport {
+		if at.Before(expire) {
+			delete(q.lastReport, peerID)
+		}
+	}
+}
+
+// 마지막 저장 이후 갱신된 샘플 (broadcaster_id -> 샘플)
+func (q *qualityStore) unsaved(now time.Time) map[string][]QualitySample {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	result := make(map[string][]QualitySample)
+	for broadcasterID, samples := range q.samples {
+		for _, sample := range samples {
+			if sample.Timestamp.After(q.lastPersist) {
+				result[broadcasterID] = append(result[broadcasterID], sample)
+			}
+		}
+	}
+	q.lastPersist = now
+	return result
+}
+
+// 샘플 목록의 품질 등급 (가장 나쁜 값 기준)
+func qualityLevel(samples []QualitySample) string {
+	level := QualityGood
+	for _, sample := range samples {
+		switch {
+		case sample.Loss >= qualityPoorLoss || sample.RTT >= qualityPoorRTT || sample.Jitter >= qualityPoorJitter || sample.Freezes > 0:
+			return QualityPoor
+		case sample.Loss >= qualityFairLoss || sample.RTT >= qualityFairRTT || sample.Jitter >= qualityFairJitter:
+			level = QualityFair
+		}
+	}
+	return level
+}
+
+// 방송 품질 현황 (방송자, 시청자별 최근 샘플과 등급, 시청자 요약)
+func (q *qualityStore) broadcastQuality(broadcasterID string) map[string]interface{} {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	broadcaster := make([]QualitySample, 0)
+	viewers := make(map[string][]QualitySample)
+	for _, sample := range q.samples[broadcasterID] {
+		if sample.Role == "broadcaster" {
+			broadcaster = append(broadcaster, sample)
+		} else {
+			viewers[sample.PeerID] = append(viewers[sample.PeerID], sample)
+		}
+	}
+
+	viewerQuality := make(map[string]interface{}, len(viewers))
+	levels := map[string]int{QualityGood: 0, QualityFair: 0, QualityPoor: 0}
+	var totalLoss, totalJitter, totalRTT, maxLoss float64
+	count := 0
+	for viewerID, samples := range viewers {
+		level := qualityLevel(samples)
+		levels[level]++
+		viewerQuality[viewerID] = map[string]interface{}{
+			"level":   level,
+			"samples": samples,
+		}
+		for _, sample := range samples {
+			totalLoss += sample.Loss
+			totalJitter += sample.Jitter
+			totalRTT += sample.RTT
+			if sample.Loss > maxLoss {
+				maxLoss = sample.Loss
+			}
+			count++
+		}
+	}
+
+	summary := map[string]interface{}{
+		"viewers": len(viewers),
+		"levels":  levels,
+	}
+	if count > 0 {
+		summary["avg_loss"] = totalLoss / float64(count)
+		summary["max_loss"] = maxLoss
+		summary["avg_jitter"] = totalJitter / float64(count)
+		summary["avg_rtt"] = totalRTT / float64(count)
+	}
+
+	return map[string]interface{}{
+		"broadcaster": map[string]interface{}{
+			"level":   qualityLevel(broadcaster),
+			"samples": broadcaster,
+		},
+		"viewers": viewerQuality,
+		"summary": summary,
+	}
+}
+
+// 주기적으로 SFU 연결의 RTCP 통계를 수집하고 품질 기록 저장
+func (wsService *WebSocketService) runTelemetry() {
+	if config.TelemetryInterval <= 0 {
+		return
+	}
+	interval := time.Duration(config.TelemetryInterval) * time.Second
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for now := range ticker.C {
+		wsService.telemetry.updateRTCP(wsService.webrtc.rtpStats(), now)
+
+		wsService.Mutex.RLock()
+		active := make(map[string]bool, len(wsService.ActiveBroadcasts))
+		records := make(map[string]*models.Broadcast, len(wsService.ActiveBroadcasts))
+		for broadcasterID, broadcast := range wsService.ActiveBroadcasts {
+			active[broadcasterID] = true
+			records[broadcasterID] = broadcast.record
+		}
+		wsService.Mutex.RUnlock()
+
+		// 보고가 끊긴 연결은 세 주기 뒤 제외
+		wsService.telemetry.prune(active, now.Add(-3*interval))
+
+		if config.TelemetryPersist {
+			wsService.persistQuality(wsService.telemetry.unsaved(now), records)
+		}
+	}
+}
+
+// 품질 샘플을 DB에 저장 (방송 기록 저장 큐 사용)
+func (wsService *WebSocketService) persistQuality(samples map[string][]QualitySample, records map[string]*models.Broadcast) {
+	for broadcasterID, list := range samples {
+		record := records[broadcasterID]
+		user := userIDToInt(broadcasterID)
+		list := list
+		wsService.history.enqueue(func(conn *sql.DB) {
+			// 방송 기록 insert가 먼저 처리되므로 ID를 그대로 사용
+			var broadcast int64
+			if record != nil {
+				broadcast = record.Id
+			}
+
+			manager := models.NewStreamStatManager(conn)
+			for _, sample := range list {
+				item := &models.StreamStat{
+					Broadcast:   broadcast,
+					User:        user,
+					Peer:        sample.PeerID,
+					Role:        sample.Role,
+					Source:      sample.Source,
+					Kind:        sample.Kind,
+					Layer:       sample.Layer,
+					Bitrate:     sample.Bitrate,
+					Packetslost: sample.PacketsLost,
+					Loss:        sample.Loss,
+					Jitter:      sample.Jitter,
+					Rtt:         sample.RTT,
+					Framerate:   sample.Framerate,
+					Freezes:     sample.Freezes,
+					Date:        global.GetDate(sample.Timestamp),
+				}
+				if err := manager.Insert(item); err != nil {
+					fmt.Printf("❌ 품질 기록 저장 실패 (%s): %v\n", broadcasterID, err)
+					return
+				}
+			}
+		})
+	}
+}
+
+// 메시지 data에서 클라이언트 보고 추출 ({"reports":[...]} 또는 보고 하나)
+func statsReportsFromMessage(msg *Message) []clientStatsReport {
+	data, ok := msg.Data.(map[string]interface{})
+	if !ok {
+		return nil
+	}
+
+	var value interface{} = []interface{}{data}
+	if list, ok := data["reports"]; ok {
+		value = list
+	}
+	raw, err := json.Marshal(value)
+	if err != nil {
+		return nil
+	}
+	var reports []clientStatsReport
+	if err := json.Unmarshal(raw, &reports); err != nil {
+		return nil
+	}
+	return reports
+}
+
+// 클라이언트 품질 보고 처리 (stats_report, 방송자 또는 시청자)
+func (wsService *WebSocketService) handleStatsReport(broadcasterID string, peerID string, role string, msg *Message) {
+	if broadcasterID == "" {
+		return
+	}
+
+	now := time.Now()
+	q := wsService.telemetry
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if now.Sub(q.lastReport[peerID]) < minStatsReportInterval {
+		return
+	}
+	q.lastReport[peerID] = now
+
+	for _, report := range statsReportsFromMessage(msg) {
+		if report.Kind != webrtc.RTPCodecTypeAudio.String() && report.Kind != webrtc.RTPCodecTypeVideo.String() {
+			continue
+		}
+		loss := report.Loss
+		if loss < 0 {
+			loss = 0
+		} else if loss > 1 {
+			loss = 1
+		}
+
+		q.put(broadcasterID, QualitySample{
+			PeerID:      peerID,
+			Role:        role,
+			Source:      models.StreamStatSourceClient,
+			Kind:        report.Kind,
+			Layer:       truncate(report.Layer, 16),
+			Bitrate:     report.Bitrate,
+			PacketsLost: report.PacketsLost,
+			Loss:        loss,
+			Jitter:      report.Jitter,
+			RTT:         report.RTT,
+			Framerate:   report.Framerate,
+			Freezes:     report.Freezes,
+			Timestamp:   now,
+		})
+	}
+}
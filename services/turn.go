@@ -0,0 +1,270 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+	"toysgo/auth"
+	"toysgo/config"
+
+	"github.com/pion/turn/v2"
+)
+
+// 할당이 갱신되지 않고 유지되는 최대 시간 (TURN 기본 할당 수명)
+const turnAllocationLifetime = 10 * time.Minute
+
+var ErrTURNServerConfig = errors.New("TURN 서버 설정이 올바르지 않습니다")
+
+// 실행 중인 내장 TURN 서버 (비활성화면 nil)
+var embeddedTURN *TURNServer
+
+// 내장 TURN 서버
+type TURNServer struct {
+	server *turn.Server
+
+	// 사용자별 클라이언트 주소와 마지막 인증 시각 (user_id -> 주소 -> 시각)
+	// 할당은 클라이언트 주소마다 하나이므로 활성 주소 수를 할당 수로 본다.
+	mu      sync.Mutex
+	clients map[string]map[string]time.Time
+
+	relayBytesIn    uint64
+	relayBytesOut   uint64
+	authFailures    uint64
+	quotaRejections uint64
+}
+
+// 내장 TURN 서버 시작
+//
+// 자격 증명은 두 가지를 받는다.
+//   - TURN REST API 임시 자격 증명: username "만료시각:사용자", password는 turnSecret HMAC (/api/ice-servers 발급)
+//   - 로그인 토큰: username은 액세스 토큰, password는 토큰의 사용자 ID
+func StartTURNServer() (*TURNServer, error) {
+	relayIP := net.ParseIP(config.TURNRelayIP)
+	if relayIP == nil || config.TURNRelayMinPort <= 0 || config.TURNRelayMaxPort < config.TURNRelayMinPort || config.TURNRelayMaxPort > 65535 {
+		return nil, ErrTURNServerConfig
+	}
+
+	t := &TURNServer{
+		clients: make(map[string]map[string]time.Time),
+	}
+
+	newRelayGenerator := func() turn.RelayAddressGenerator {
+		return &turnRelayGenerator{
+			RelayAddressGenerator: &turn.RelayAddressGeneratorPortRange{
+				RelayAddress: relayIP,
+				Address:      "0.0.0.0",
+				MinPort:      uint16(config.TURNRelayMinPort),
+				MaxPort:      uint16(config.TURNRelayMaxPort),
+			},
+			server: t,
+		}
+	}
+
+	serverConfig := turn.ServerConfig{
+		Realm:       config.TURNRealm,
+		AuthHandler: t.authenticate,
+	}
+
+	if config.TURNListenUDP != "" {
+		conn, err := net.ListenPacket("udp4", config.TURNListenUDP)
+		if err != nil {
+			return nil, fmt.Errorf("failed to listen TURN UDP: %v", err)
+		}
+		serverConfig.PacketConnConfigs = append(serverConfig.PacketConnConfigs, turn.PacketConnConfig{
+			PacketConn:            conn,
+			RelayAddressGenerator: newRelayGenerator(),
+		})
+	}
+
+	if config.TURNListenTCP != "" {
+		listener, err := net.Listen("tcp4", config.TURNListenTCP)
+		if err != nil {
+			for _, packetConfig := range serverConfig.PacketConnConfigs {
+				packetConfig.PacketConn.Close()
+			}
+			return nil, fmt.Errorf("failed to listen TURN TCP: %v", err)
+		}
+		serverConfig.ListenerConfigs = append(serverConfig.ListenerConfigs, turn.ListenerConfig{
+			Listener:              listener,
+			RelayAddressGenerator: newRelayGenerator(),
+		})
+	}
+
+	if len(serverConfig.PacketConnConfigs) == 0 && len(serverConfig.ListenerConfigs) == 0 {
+		return nil, ErrTURNServerConfig
+	}
+
+	server, err := turn.NewServer(serverConfig)
+	if err != nil {
+		return nil, err
+	}
+	t.server = server
+	embeddedTURN = t
+
+	fmt.Printf("🔁 TURN 서버 시작: UDP %s, TCP %s, 릴레이 %s:%d-%d (realm %s)\n",
+		config.TURNListenUDP, config.TURNListenTCP, config.TURNRelayIP, config.TURNRelayMinPort, config.TURNRelayMaxPort, config.TURNRealm)
+	return t, nil
+}
+
+// TURN 서버 종료
+func (t *TURNServer) Close() error {
+	if embeddedTURN == t {
+		embeddedTURN = nil
+	}
+	return t.server.Close()
+}
+
+// 자격 증명 확인 후 사용자별 할당 한도 적용 (인증 키 반환)
+func (t *TURNServer) authenticate(username string, realm string, srcAddr net.Addr) ([]byte, bool) {
+	userID, password, ok := turnPassword(username)
+	if !ok {
+		atomic.AddUint64(&t.authFailures, 1)
+		fmt.Printf("❌ TURN 인증 실패: %s\n", srcAddr)
+		return nil, false
+	}
+
+	if !t.admit(userID, srcAddr.String()) {
+		atomic.AddUint64(&t.quotaRejections, 1)
+		fmt.Printf("🈵 TURN 할당 한도 초과: 사용자 %s (%s)\n", userID, srcAddr)
+		return nil, false
+	}
+
+	return turn.GenerateAuthKey(username, realm, password), true
+}
+
+// username으로 사용자와 기대하는 password 확인
+func turnPassword(username string) (string, string, bool) {
+	// 임시 자격 증명 (만료시각:사용자)
+	if expiry, userID, found := strings.Cut(username, ":"); found && config.TURNSecret != "" {
+		expires, err := strconv.ParseInt(expiry, 10, 64)
+		if err != nil || expires < time.Now().Unix() || userID == "" {
+			return "", "", false
+		}
+		return userID, turnCredential(username), true
+	}
+
+	// 로그인 토큰
+	claims, err := auth.ParseToken(username)
+	if err != nil {
+		return "", "", false
+	}
+	userID := fmt.Sprintf("%d", claims.UserID())
+	return userID, userID, true
+}
+
+// 사용자별 할당 한도 (익명 자격 증명은 요청마다 ID가 달라 별도 한도 적용)
+func turnQuota(userID string) int {
+	if strings.HasPrefix(userID, "anonymous_") {
+		return config.TURNAnonQuota
+	}
+	return config.TURNUserQuota
+}
+
+// 클라이언트 주소 등록 (새 주소인데 사용자 한도를 넘으면 false)
+func (t *TURNServer) admit(userID string, address string) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	now := time.Now()
+	t.pruneClients(now)
+
+	clients := t.clients[userID]
+	if clients == nil {
+		clients = make(map[string]time.Time)
+		t.clients[userID] = clients
+	}
+	if _, exists := clients[address]; !exists && turnQuota(userID) > 0 && len(clients) >= turnQuota(userID) {
+		return false
+	}
+	clients[address] = now
+	return true
+}
+
+// 갱신되지 않은 클라이언트 주소 정리 (호출자가 잠금을 보유해야 함)
+func (t *TURNServer) pruneClients(now time.Time) {
+	for userID, clients := range t.clients {
+		for address, seen := range clients {
+			if now.Sub(seen) > turnAllocationLifetime {
+				delete(clients, address)
+			}
+		}
+		if len(clients) == 0 {
+			delete(t.clients, userID)
+		}
+	}
+}
+
+// TURN 현황 (API용)
+func (t *TURNServer) Stats() map[string]interface{} {
+	t.mu.Lock()
+	t.pruneClients(time.Now())
+	users := make(map[string]int, len(t.clients))
+	for userID, clients := range t.clients {
+		users[userID] = len(clients)
+	}
+	t.mu.Unlock()
+
+	return map[string]interface{}{
+		"enabled":          true,
+		"realm":            config.TURNRealm,
+		"allocations":      t.server.AllocationCount(),
+		"user_quota":       config.TURNUserQuota,
+		"anonymous_quota":  config.TURNAnonQuota,
+		"user_allocations": users,
+		"relay_bytes_in":   atomic.LoadUint64(&t.relayBytesIn),
+		"relay_bytes_out":  atomic.LoadUint64(&t.relayBytesOut),
+		"auth_failures":    atomic.LoadUint64(&t.authFailures),
+		"quota_rejections": atomic.LoadUint64(&t.quotaRejections),
+	}
+}
+
+// 내장 TURN 서버 현황 (실행 중이 아니면 enabled: false)
+func GetTURNStats() map[string]interface{} {
+	if t := embeddedTURN; t != nil {
+		return t.Stats()
+	}
+	return map[string]interface{}{
+		"enabled": false,
+	}
+}
+
+// 릴레이 소켓의 송수신량을 집계하는 주소 생성기
+type turnRelayGenerator struct {
+	turn.RelayAddressGenerator
+	server *TURNServer
+}
+
+func (g *turnRelayGenerator) AllocatePacketConn(network string, requestedPort int) (net.PacketConn, net.Addr, error) {
+	conn, addr, err := g.RelayAddressGenerator.AllocatePacketConn(network, requestedPort)
+	if err != nil {
+		return nil, nil, err
+	}
+	return &turnRelayConn{PacketConn: conn, server: g.server}, addr, nil
+}
+
+// 릴레이 소켓 (상대에게서 받은 양은 in, 상대에게 보낸 양은 out)
+type turnRelayConn struct {
+	net.PacketConn
+	server *TURNServer
+}
+
+func (c *turnRelayConn) ReadFrom(p []byte) (int, net.Addr, error) {
+	n, addr, err := c.PacketConn.ReadFrom(p)
+	if n > 0 {
+		atomic.AddUint64(&c.server.relayBytesIn, uint64(n))
+	}
+	return n, addr, err
+}
+
+func (c *turnRelayConn) WriteTo(p []byte, addr net.Addr) (int, error) {
+	n, err := c.PacketConn.WriteTo(p, addr)
+	if n > 0 {
+		atomic.AddUint64(&c.server.relayBytesOut, uint64(n))
+	}
+	return n, err
+}
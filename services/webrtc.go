@@ -1,42 +1,255 @@
 package services
 
 import (
+	"encoding/json"
 	"fmt"
+	"strings"
 	"sync"
 
+	"github.com/pion/interceptor"
+	"github.com/pion/interceptor/pkg/cc"
+	"github.com/pion/interceptor/pkg/gcc"
+	"github.com/pion/interceptor/pkg/stats"
 	"github.com/pion/webrtc/v3"
 )
 
+// 송신 대역 추정 범위 (bps)
+const (
+	bweInitialBitrate = 1_000_000
+	bweMinBitrate     = 100_000
+	bweMaxBitrate     = 10_000_000
+)
+
 // WebRTCService manages WebRTC connections and data channels
 type WebRTCService struct {
 	mu       sync.Mutex
 	peers    map[string]*webrtc.PeerConnection
 	onTrack  func(track *webrtc.TrackRemote, receiver *webrtc.RTPReceiver)
-	onSignal func(signalType string, data string)
+	onSignal func(peerID string, signalType string, data string)
+
+	// HLS 첫 세그먼트가 준비되면 호출 (broadcaster_id)
+	onHLSReady func(broadcasterID string)
+	// ICE 연결 실패로 서버가 연결을 정리하면 호출 (peer_id)
+	onPeerClosed func(peerID string)
+	// SFU 이벤트 채널로 메시지를 받으면 호출 (viewer_id가 비어 있으면 방송자)
+	onDataMessage func(broadcasterID string, viewerID string, data []byte)
+
+	// SFU 세션 (broadcaster_id -> sfuSession)
+	sessions map[string]*sfuSession
+
+	// simulcast 수신과 TWCC 송신 대역 추정을 설정한 API (생성 실패 시 nil이면 기본 설정 사용)
+	// 대역 추정기는 PeerConnection 생성 중에 estimators로 전달되므로 생성은 apiMu로 직렬화한다.
+	api        *webrtc.API
+	apiMu      sync.Mutex
+	estimators chan cc.BandwidthEstimator
+
+	// RTCP 송수신 보고서 기반 통계 (peer_id -> 통계 조회기, 생성 시 statsGetters로 전달됨)
+	peerStats    map[string]stats.Getter
+	statsGetters chan stats.Getter
 }
 
 // NewWebRTCService initializes a new WebRTCService
 func NewWebRTCService() *WebRTCService {
-	return &WebRTCService{
-		peers: make(map[string]*webrtc.PeerConnection),
+	w := &WebRTCService{
+		peers:      make(map[string]*webrtc.PeerConnection),
+		sessions:   make(map[string]*sfuSession),
+		estimators: make(chan cc.BandwidthEstimator, 1),
+
+		peerStats:    make(map[string]stats.Getter),
+		statsGetters: make(chan stats.Getter, 1),
+	}
+
+	api, err := w.newAPI()
+	if err != nil {
+		fmt.Printf("⚠️ WebRTC API 설정 실패, 기본 설정 사용: %v\n", err)
 	}
+	w.api = api
+	return w
 }
 
-// CreatePeerConnection creates a new WebRTC PeerConnection
-func (w *WebRTCService) CreatePeerConnection(peerID string) (*webrtc.PeerConnection, error) {
+// newAPI builds the WebRTC API with default codecs and interceptors, simulcast header extensions,
+// REMB feedback, a send-side bandwidth estimator fed by TWCC and RTCP-based stream statistics
+func (w *WebRTCService) newAPI() (*webrtc.API, error) {
+	m := &webrtc.MediaEngine{}
+	if err := m.RegisterDefaultCodecs(); err != nil {
+		return nil, err
+	}
+	m.RegisterFeedback(webrtc.RTCPFeedback{Type: webrtc.TypeRTCPFBGoogREMB}, webrtc.RTPCodecTypeVideo)
+
+	i := &interceptor.Registry{}
+	if err := webrtc.RegisterDefaultInterceptors(m, i); err != nil {
+		return nil, err
+	}
+	if err := webrtc.ConfigureSimulcastExtensionHeaders(m); err != nil {
+		return nil, err
+	}
+
+	// 대역 추정만 사용하고 송신 속도는 조절하지 않음 (레이어 선택으로 맞춤)
+	congestionController, err := cc.NewInterceptor(func() (cc.BandwidthEstimator, error) {
+		return gcc.NewSendSideBWE(
+			gcc.SendSideBWEInitialBitrate(bweInitialBitrate),
+			gcc.SendSideBWEMinBitrate(bweMinBitrate),
+			gcc.SendSideBWEMaxBitrate(bweMaxBitrate),
+			gcc.SendSideBWEPacer(gcc.NewNoOpPacer()),
+		)
+	})
+	if err != nil {
+		return nil, err
+	}
+	congestionController.OnNewPeerConnection(func(id string, estimator cc.BandwidthEstimator) {
+		select {
+		case w.estimators <- estimator:
+		default:
+		}
+	})
+	i.Add(congestionController)
+
+	if err := webrtc.ConfigureTWCCHeaderExtensionSender(m, i); err != nil {
+		return nil, err
+	}
+
+	statsInterceptor, err := stats.NewInterceptor()
+	if err != nil {
+		return nil, err
+	}
+	statsInterceptor.OnNewPeerConnection(func(id string, getter stats.Getter) {
+		select {
+		case w.statsGetters <- getter:
+		default:
+		}
+	})
+	i.Add(statsInterceptor)
+
+	return webrtc.NewAPI(webrtc.WithMediaEngine(m), webrtc.WithInterceptorRegistry(i)), nil
+}
+
+// OnSignal registers the callback used to send signaling messages (answer, offer, candidate, failed) to a peer
+func (w *WebRTCService) OnSignal(handler func(peerID string, signalType string, data string)) {
+	w.mu.Lock()
+	w.onSignal = handler
+	w.mu.Unlock()
+}
+
+// OnHLSReady registers the callback called when a broadcast's first HLS segment is ready
+func (w *WebRTCService) OnHLSReady(handler func(broadcasterID string)) {
+	w.mu.Lock()
+	w.onHLSReady = handler
+	w.mu.Unlock()
+}
+
+// OnDataMessage registers a handler for messages received on SFU event channels
+func (w *WebRTCService) OnDataMessage(handler func(broadcasterID string, viewerID string, data []byte)) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.onDataMessage = handler
+}
+
+// OnPeerClosed registers the callback called when a PeerConnection is closed after an ICE failure
+func (w *WebRTCService) OnPeerClosed(handler func(peerID string)) {
+	w.mu.Lock()
+	w.onPeerClosed = handler
+	w.mu.Unlock()
+}
+
+// signal sends a signaling message through the registered callback
+func (w *WebRTCService) signal(peerID string, signalType string, data string) {
+	w.mu.Lock()
+	onSignal := w.onSignal
+	w.mu.Unlock()
+
+	if onSignal != nil {
+		onSignal(peerID, signalType, data)
+	}
+}
+
+// newPeerConnection creates a PeerConnection with the server ICE configuration
+// Its send-side bandwidth estimator and stats getter are returned when available (nil otherwise).
+func (w *WebRTCService) newPeerConnection() (*webrtc.PeerConnection, cc.BandwidthEstimator, stats.Getter, error) {
 	config := webrtc.Configuration{
-		ICEServers: []webrtc.ICEServer{
-			{
-				URLs: []string{"stun:stun.l.google.com:19302"},
-			},
-		},
+		ICEServers: webrtcICEServers(),
+	}
+
+	if w.api == nil {
+		pc, err := webrtc.NewPeerConnection(config)
+		return pc, nil, nil, err
+	}
+
+	w.apiMu.Lock()
+	defer w.apiMu.Unlock()
+
+	pc, err := w.api.NewPeerConnection(config)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	var estimator cc.BandwidthEstimator
+	select {
+	case estimator = <-w.estimators:
+	default:
+	}
+	var getter stats.Getter
+	select {
+	case getter = <-w.statsGetters:
+	default:
+	}
+	return pc, estimator, getter, nil
+}
+
+// addPeer registers a PeerConnection and its stats getter under peerID (caller must hold w.mu)
+func (w *WebRTCService) addPeer(peerID string, pc *webrtc.PeerConnection, getter stats.Getter) {
+	w.peers[peerID] = pc
+	if getter != nil {
+		w.peerStats[peerID] = getter
+	} else {
+		delete(w.peerStats, peerID)
 	}
+}
+
+// removePeer unregisters a PeerConnection (caller must hold w.mu)
+func (w *WebRTCService) removePeer(peerID string) {
+	delete(w.peers, peerID)
+	delete(w.peerStats, peerID)
+}
+
+// CreatePeerConnection creates a new WebRTC PeerConnection
+func (w *WebRTCService) CreatePeerConnection(peerID string) (*webrtc.PeerConnection, error) {
+	// 같은 ID의 이전 연결 정리
+	w.ClosePeerConnection(peerID)
 
-	peerConnection, err := webrtc.NewPeerConnection(config)
+	peerConnection, _, getter, err := w.newPeerConnection()
 	if err != nil {
 		return nil, err
 	}
 
+	// 서버 ICE 후보를 클라이언트로 전달 (trickle ICE)
+	peerConnection.OnICECandidate(func(candidate *webrtc.ICECandidate) {
+		if candidate == nil {
+			return
+		}
+		data, err := json.Marshal(candidate.ToJSON())
+		if err != nil {
+			return
+		}
+		w.signal(peerID, "candidate", string(data))
+	})
+
+	// ICE 연결 실패 시 정리
+	peerConnection.OnICEConnectionStateChange(func(state webrtc.ICEConnectionState) {
+		if state != webrtc.ICEConnectionStateFailed {
+			return
+		}
+		fmt.Printf("❌ ICE 연결 실패: %s\n", peerID)
+		if w.closePeer(peerID, peerConnection) {
+			w.peerClosed(peerID)
+		}
+		w.signal(peerID, "failed", "")
+	})
+
+	// 수신 트랙 처리 (SFU 방송이면 시청자에게 전달)
+	peerConnection.OnTrack(func(track *webrtc.TrackRemote, receiver *webrtc.RTPReceiver) {
+		w.handleTrack(peerID, track, receiver)
+	})
+
 	// 오디오 트랜시버 추가 (opus 코덱 지원)
 	_, err = peerConnection.AddTransceiverFromKind(webrtc.RTPCodecTypeAudio, webrtc.RTPTransceiverInit{
 		Direction: webrtc.RTPTransceiverDirectionRecvonly,
@@ -55,19 +268,19 @@ func (w *WebRTCService) CreatePeerConnection(peerID string) (*webrtc.PeerConnect
 
 	// PeerConnection 저장
 	w.mu.Lock()
-	w.peers[peerID] = peerConnection
+	w.addPeer(peerID, peerConnection, getter)
 	w.mu.Unlock()
 
 	return peerConnection, nil
 }
 
-// HandleSDP handles SDP offer/answer
-func (w *WebRTCService) HandleSDP(peerID string, sdpType webrtc.SDPType, sdp string) error {
+// HandleSDP handles SDP offer/answer and returns the generated answer for an offer
+func (w *WebRTCService) HandleSDP(peerID string, sdpType webrtc.SDPType, sdp string) (string, error) {
 	w.mu.Lock()
 	pc, exists := w.peers[peerID]
 	w.mu.Unlock()
 	if !exists {
-		return fmt.Errorf("peer not found")
+		return "", fmt.Errorf("peer not found")
 	}
 
 	offer := webrtc.SessionDescription{
@@ -78,22 +291,48 @@ func (w *WebRTCService) HandleSDP(peerID string, sdpType webrtc.SDPType, sdp str
 	// SDP 설정
 	err := pc.SetRemoteDescription(offer)
 	if err != nil {
-		return fmt.Errorf("failed to set remote description: %v", err)
+		return "", fmt.Errorf("failed to set remote description: %v", err)
+	}
+	if sdpType != webrtc.SDPTypeOffer {
+		return "", nil
 	}
 
 	// SDP Answer 생성
-	if sdpType == webrtc.SDPTypeOffer {
-		answer, err := pc.CreateAnswer(nil)
-		if err != nil {
-			return fmt.Errorf("failed to create answer: %v", err)
-		}
-		err = pc.SetLocalDescription(answer)
-		if err != nil {
-			return fmt.Errorf("failed to set local description: %v", err)
-		}
-		fmt.Println("Generated SDP Answer:", answer.SDP)
+	answer, err := pc.CreateAnswer(nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to create answer: %v", err)
+	}
+	err = pc.SetLocalDescription(answer)
+	if err != nil {
+		return "", fmt.Errorf("failed to set local description: %v", err)
+	}
+
+	return answer.SDP, nil
+}
+
+// Renegotiate creates a server-initiated offer and sends it through the signal callback
+func (w *WebRTCService) Renegotiate(peerID string) error {
+	w.mu.Lock()
+	pc, exists := w.peers[peerID]
+	w.mu.Unlock()
+	if !exists {
+		return fmt.Errorf("peer not found")
+	}
+
+	if pc.SignalingState() != webrtc.SignalingStateStable {
+		return fmt.Errorf("negotiation already in progress")
+	}
+
+	offer, err := pc.CreateOffer(nil)
+	if err != nil {
+		return fmt.Errorf("failed to create offer: %v", err)
+	}
+	err = pc.SetLocalDescription(offer)
+	if err != nil {
+		return fmt.Errorf("failed to set local description: %v", err)
 	}
 
+	w.signal(peerID, "offer", offer.SDP)
 	return nil
 }
 
@@ -106,7 +345,13 @@ func (w *WebRTCService) HandleCandidate(peerID string, candidate string) error {
 		return fmt.Errorf("peer not found")
 	}
 
+	// JSON(RTCIceCandidateInit) 또는 candidate 문자열만 전달될 수 있음
 	iceCandidate := webrtc.ICECandidateInit{Candidate: candidate}
+	if strings.HasPrefix(strings.TrimSpace(candidate), "{") {
+		if err := json.Unmarshal([]byte(candidate), &iceCandidate); err != nil {
+			return fmt.Errorf("invalid ICE candidate: %v", err)
+		}
+	}
 	err := pc.AddICECandidate(iceCandidate)
 	if err != nil {
 		return fmt.Errorf("failed to add ICE candidate: %v", err)
@@ -122,6 +367,31 @@ func (w *WebRTCService) ClosePeerConnection(peerID string) {
 
 	if pc, exists := w.peers[peerID]; exists {
 		pc.Close()
-		delete(w.peers, peerID)
+		w.removePeer(peerID)
+	}
+}
+
+// closePeer closes a PeerConnection, removing it only if it is still registered under peerID
+// It reports whether the connection was still the registered one.
+func (w *WebRTCService) closePeer(peerID string, pc *webrtc.PeerConnection) bool {
+	w.mu.Lock()
+	current := w.peers[peerID] == pc
+	if current {
+		w.removePeer(peerID)
+	}
+	w.mu.Unlock()
+
+	go pc.Close()
+	return current
+}
+
+// peerClosed notifies the registered callback that a connection was closed by the server
+func (w *WebRTCService) peerClosed(peerID string) {
+	w.mu.Lock()
+	onPeerClosed := w.onPeerClosed
+	w.mu.Unlock()
+
+	if onPeerClosed != nil {
+		onPeerClosed(peerID)
 	}
 }
@@ -4,7 +4,10 @@ import (
 	"encoding/json"
 	"fmt"
 	"sync"
+	"sync/atomic"
 	"time"
+	"toysgo/config"
+	"toysgo/models"
 
 	"github.com/gofiber/websocket/v2"
 )
@@ -16,14 +19,82 @@ type BroadcastInfo struct {
 	StartTime       time.Time `json:"start_time"`
 	ViewerCount     int       `json:"viewer_count"`
 	IsLive          bool      `json:"is_live"`
+	Status          string    `json:"status"`
+	BroadcastMetadata
+
+	// 전송 방식 (mesh 또는 sfu)
+	Mode string `json:"mode"`
+	// 송출 경로 (websocket 또는 whip)
+	Ingest string `json:"ingest"`
+
+	// 서버 녹화 여부
+	Recording bool `json:"recording"`
+	recording *models.Recording
+
+	// HLS 재생 목록 주소 (SFU 방송에서 첫 세그먼트가 만들어진 뒤, 비공개 방송은 제외)
+	HLSURL   string `json:"hls_url,omitempty"`
+	hlsReady bool
+
+	// 최대 시청자 수와 대기열 (viewer_id, 먼저 온 순서)
+	MaxViewers   int `json:"max_viewers"`
+	WaitingCount int `json:"waiting_count"`
+	waiting      []string
+
+	// 공개 범위
+	Visibility        string `json:"visibility"`
+	PasswordProtected bool   `json:"password_protected"`
+	access            *broadcastAccess
+
+	// WHEP 시청자 (viewer_id -> ViewerInfo, 시그널링 소켓 없이 시청)
+	whep map[string]*ViewerInfo
+
+	// 재접속 세션 정보
+	resumeToken       string
+	reconnectDeadline time.Time
+	reconnectTimer    *time.Timer
+
+	// 채팅
+	chat *chatRoom
+
+	// 마지막 방송 이벤트 번호
+	eventSeq int64
+
+	// 방송 기록
+	record       *models.Broadcast
+	peakViewers  int
+	totalViewers int
 }
 
 // 연결 정보 구조체
 type Connection struct {
-	Conn   *websocket.Conn
-	UserID string
-	Name   string
-	Role   string
+	Conn          *websocket.Conn
+	UserID        string
+	Name          string
+	Role          string
+	Authenticated bool
+
+	// 클라이언트 주소 (익명 시청자의 비밀번호 시도 제한용)
+	IP string
+
+	// 인증 단계에서 미리 읽은 첫 메시지
+	pending []byte
+	// 인증 대기 시간 안에 도착하지 않은 첫 메시지 (익명 연결로 진행한 경우)
+	firstRead chan readResult
+
+	// 송신 큐 (단일 송신 고루틴이 소비)
+	send      chan []byte
+	closing   chan struct{}
+	done      chan struct{}
+	closeOnce sync.Once
+	slowOnce  sync.Once
+	dropped   uint64
+	stats     *sendStats
+
+	// 마지막 수신 시각 (UnixNano)
+	lastSeen int64
+
+	// 웹소켓이 없는 연결(WHEP 시청자)의 종료 처리 (Shutdown 시 한 번 호출)
+	onClose func()
 }
 
 // 시청자 정보 구조체
@@ -31,11 +102,26 @@ type ViewerInfo struct {
 	Connection      *Connection
 	BroadcasterID   string
 	JoinTime        time.Time
+
+	// 비공개 방송 접근 (연결 시 전달된 초대 토큰, 접근 확인 전 요청한 방송)
+	invite    string
+	requested string
+
+	// 스트림 자리 배정 여부 (최대 시청자 수에 포함됨)
+	admitted bool
+
+	// 구독한 방송 이벤트 주제 (nil이면 모든 주제)
+	eventTopics map[string]bool
+
+	// 시청 기록
+	attendance      *models.BroadcastViewer
+	attendanceStart time.Time
 }
 
 // WebSocket 메시지 구조체
 type Message struct {
 	Type          string                 `json:"type"`
+	Token         string                 `json:"token,omitempty"`
 	Data          interface{}            `json:"data,omitempty"`
 	BroadcasterID interface{}            `json:"broadcaster_id,omitempty"`
 	ViewerID      interface{}            `json:"viewer_id,omitempty"`
@@ -73,7 +159,7 @@ type WebSocketService struct {
 	Viewers map[string]*ViewerInfo
 	
 	// 방송 목록 구독자 (connection -> user_id)
-	ListSubscribers map[*websocket.Conn]string
+	ListSubscribers map[*Connection]string
 	
 	// 활성 방송 목록 (broadcaster_id -> BroadcastInfo)
 	ActiveBroadcasts map[string]*BroadcastInfo
@@ -83,6 +169,24 @@ type WebSocketService struct {
 	
 	Mutex       sync.RWMutex
 	initialized bool
+
+	// 송신 큐 통계
+	sendStats sendStats
+
+	// 방송/시청 기록 저장
+	history *historyRecorder
+
+	// 방송자별 차단/매니저 목록 (broadcaster_id -> moderationList)
+	moderation map[string]*moderationList
+
+	// SFU 모드 방송의 서버 측 미디어 전달
+	webrtc *WebRTCService
+
+	// WHIP/WHEP 세션 (resource_id -> whipResource)
+	whipResources map[string]*whipResource
+
+	// 연결 품질 (RTCP 통계와 클라이언트 보고)
+	telemetry *qualityStore
 }
 
 // 안전한 초기화
@@ -92,12 +196,23 @@ func NewWebSocketService() *WebSocketService {
 	service := &WebSocketService{
 		Broadcasters:     make(map[string]*Connection),
 		Viewers:          make(map[string]*ViewerInfo),
-		ListSubscribers:  make(map[*websocket.Conn]string),
+		ListSubscribers:  make(map[*Connection]string),
 		ActiveBroadcasts: make(map[string]*BroadcastInfo),
 		PendingOffers:    make(map[string]string),
+		history:          newHistoryRecorder(),
+		moderation:       make(map[string]*moderationList),
+		webrtc:           NewWebRTCService(),
+		whipResources:    make(map[string]*whipResource),
+		telemetry:        newQualityStore(),
 		initialized:      true,
 	}
 	
+	go service.runReaper()
+	go service.runTelemetry()
+	service.webrtc.OnHLSReady(service.handleHLSReady)
+	service.webrtc.OnPeerClosed(service.handlePeerClosed)
+	service.webrtc.OnDataMessage(service.handleDataMessage)
+
 	fmt.Printf("✅ WebSocketService 초기화 완료:\n")
 	fmt.Printf("  - Broadcasters: %v\n", service.Broadcasters != nil)
 	fmt.Printf("  - Viewers: %v\n", service.Viewers != nil)
@@ -114,56 +229,50 @@ func (wsService *WebSocketService) validateService() error {
 		return fmt.Errorf("서비스가 초기화되지 않았습니다")
 	}
 	if wsService.ListSubscribers == nil {
-		wsService.ListSubscribers = make(map[*websocket.Conn]string)
+		wsService.ListSubscribers = make(map[*Connection]string)
 		fmt.Printf("⚠️ ListSubscribers 재초기화\n")
 	}
 	return nil
 }
 
 // 방송자 처리 (안전성 강화)
-func (wsService *WebSocketService) HandleBroadcaster(conn *websocket.Conn) {
+func (wsService *WebSocketService) HandleBroadcaster(connection *Connection) {
 	if err := wsService.validateService(); err != nil {
 		fmt.Printf("❌ 방송자 핸들러 초기화 오류: %v\n", err)
 		return
 	}
 
-	userID := conn.Query("user_id")
-	userName := conn.Query("user_name")
-	
-	fmt.Printf("🎥 방송자 핸들러 시작: userID=%s, userName=%s\n", userID, userName)
-	
-	if userID == "" {
-		fmt.Println("❌ 방송자 user_id가 없습니다")
-		conn.WriteMessage(websocket.TextMessage, []byte(`{"type":"error","data":"user_id가 필요합니다"}`))
-		return // Close() 제거하고 연결 유지
-	}
+	userID := connection.UserID
+	userName := connection.Name
 
-	connection := &Connection{
-		Conn:   conn,
-		UserID: userID,
-		Name:   userName,
-		Role:   "broadcaster",
-	}
+	fmt.Printf("🎥 방송자 핸들러 시작: userID=%s, userName=%s\n", userID, userName)
 
 	wsService.Mutex.Lock()
+	if previous := wsService.Broadcasters[userID]; previous != nil {
+		fmt.Printf("⚠️ 기존 방송자 연결 교체: %s\n", userID)
+		previous.Shutdown()
+	}
 	wsService.Broadcasters[userID] = connection
 	wsService.Mutex.Unlock()
 
 	fmt.Printf("✅ 방송자 연결 등록: %s (%s)\n", userName, userID)
+	wsService.sendAuthenticated(connection)
+
+	if resumeToken := connection.Conn.Query("resume_token"); resumeToken != "" {
+		wsService.resumeBroadcast(userID, resumeToken)
+	}
 
 	defer func() {
 		fmt.Printf("🧹 방송자 정리 시작: %s\n", userID)
-		wsService.stopBroadcast(userID)
-		wsService.Mutex.Lock()
-		if wsService.Broadcasters != nil {
-			delete(wsService.Broadcasters, userID)
+		if !wsService.removeBroadcaster(userID, connection) {
+			fmt.Printf("🧹 방송자 정리 생략 (이미 정리되었거나 새 연결로 교체됨): %s\n", userID)
+			return
 		}
-		wsService.Mutex.Unlock()
 		fmt.Printf("🧹 방송자 정리 완료: %s\n", userID)
 	}()
 
 	for {
-		_, message, err := conn.ReadMessage()
+		message, err := connection.ReadMessage()
 		if err != nil {
 			fmt.Printf("❌ 방송자 연결 오류 (%s): %v\n", userID, err)
 			break
@@ -182,51 +291,71 @@ func (wsService *WebSocketService) HandleBroadcaster(conn *websocket.Conn) {
 }
 
 // 시청자 처리 (안전성 강화)
-func (wsService *WebSocketService) HandleViewer(conn *websocket.Conn) {
+func (wsService *WebSocketService) HandleViewer(connection *Connection) {
 	if err := wsService.validateService(); err != nil {
 		fmt.Printf("❌ 시청자 핸들러 초기화 오류: %v\n", err)
 		return
 	}
 
-	userID := conn.Query("user_id")
-	userName := conn.Query("user_name")
-	broadcasterID := conn.Query("broadcaster_id")
-	
+	userID := connection.UserID
+	userName := connection.Name
+	broadcasterID := connection.Conn.Query("broadcaster_id")
+
 	fmt.Printf("👀 시청자 핸들러 시작: userID=%s, userName=%s, broadcasterID=%s\n", userID, userName, broadcasterID)
-	
-	if userID == "" {
-		fmt.Println("❌ 시청자 user_id가 없습니다")
-		conn.WriteMessage(websocket.TextMessage, []byte(`{"type":"error","data":"user_id가 필요합니다"}`))
-		return
-	}
 
-	connection := &Connection{
-		Conn:   conn,
-		UserID: userID,
-		Name:   userName,
-		Role:   "viewer",
+	// 차단된 사용자는 연결을 받지 않음 (방송 중이 아니면 방송 시작 시 확인)
+	wsService.Mutex.RLock()
+	banned := wsService.isBanned(broadcasterID, userID)
+	wsService.Mutex.RUnlock()
+	if banned {
+		fmt.Printf("🚫 차단된 시청자 연결 거부: %s -> 방송 %s\n", userID, broadcasterID)
+		wsService.sendBanned(connection, broadcasterID)
+		return
 	}
 
 	viewerInfo := &ViewerInfo{
 		Connection:    connection,
 		BroadcasterID: broadcasterID,
 		JoinTime:      time.Now(),
+		invite:        connection.Conn.Query("invite"),
 	}
 
 	wsService.Mutex.Lock()
+	if previous := wsService.Viewers[userID]; previous != nil {
+		fmt.Printf("⚠️ 기존 시청자 연결 교체: %s\n", userID)
+		previous.Connection.Shutdown()
+	}
 	wsService.Viewers[userID] = viewerInfo
+
+	// 비공개 방송은 접근이 확인될 때까지 시청자로 묶지 않음
+	var deniedBroadcast *BroadcastInfo
+	deniedCode := ""
+	if broadcast := wsService.ActiveBroadcasts[broadcasterID]; broadcast != nil {
+		if deniedCode = wsService.checkAccess(broadcast, viewerInfo, "", ""); deniedCode != "" {
+			viewerInfo.BroadcasterID = ""
+			viewerInfo.requested = broadcasterID
+			deniedBroadcast = broadcast
+		}
+	}
 	wsService.Mutex.Unlock()
 
 	fmt.Printf("✅ 시청자 연결 등록: %s (%s)\n", userName, userID)
+	wsService.sendAuthenticated(connection)
+
+	if deniedBroadcast != nil {
+		wsService.Mutex.RLock()
+		wsService.sendAccessDenied(connection, deniedBroadcast, deniedCode)
+		wsService.Mutex.RUnlock()
+	}
 
 	defer func() {
 		fmt.Printf("🧹 시청자 정리 시작: %s\n", userID)
-		wsService.removeViewer(userID)
+		wsService.removeViewerInfo(userID, viewerInfo)
 		fmt.Printf("🧹 시청자 정리 완료: %s\n", userID)
 	}()
 
 	for {
-		_, message, err := conn.ReadMessage()
+		message, err := connection.ReadMessage()
 		if err != nil {
 			fmt.Printf("❌ 시청자 연결 오류 (%s): %v\n", userID, err)
 			break
@@ -245,26 +374,14 @@ func (wsService *WebSocketService) HandleViewer(conn *websocket.Conn) {
 }
 
 // 방송 목록 구독자 처리 (완전히 수정)
-func (wsService *WebSocketService) HandleViewerList(conn *websocket.Conn) {
+func (wsService *WebSocketService) HandleViewerList(connection *Connection) {
 	if err := wsService.validateService(); err != nil {
 		fmt.Printf("❌ 목록 구독자 핸들러 초기화 오류: %v\n", err)
 		return
 	}
 
-	userID := conn.Query("user_id")
+	userID := connection.UserID
 	fmt.Printf("📋 목록 구독자 핸들러 시작: userID=%s\n", userID)
-	
-	if userID == "" {
-		fmt.Println("❌ 목록 구독자 user_id가 없습니다")
-		// 익명 사용자 ID 생성
-		userID = fmt.Sprintf("anonymous_%d", time.Now().UnixNano())
-		fmt.Printf("⚠️ 익명 사용자 ID 할당: %s\n", userID)
-		
-		conn.WriteMessage(websocket.TextMessage, []byte(fmt.Sprintf(`{
-			"type": "warning",
-			"data": "user_id가 없어 익명 사용자로 설정되었습니다: %s"
-		}`, userID)))
-	}
 
 	// 안전한 등록
 	fmt.Printf("🔐 뮤텍스 잠금 시도 (목록 구독자 등록)...\n")
@@ -272,26 +389,27 @@ func (wsService *WebSocketService) HandleViewerList(conn *websocket.Conn) {
 	
 	// 재확인
 	if wsService.ListSubscribers == nil {
-		wsService.ListSubscribers = make(map[*websocket.Conn]string)
+		wsService.ListSubscribers = make(map[*Connection]string)
 		fmt.Printf("⚠️ ListSubscribers 긴급 재초기화\n")
 	}
 	
-	wsService.ListSubscribers[conn] = userID
+	wsService.ListSubscribers[connection] = userID
 	subscriberCount := len(wsService.ListSubscribers)
 	wsService.Mutex.Unlock()
 	fmt.Printf("🔓 뮤텍스 잠금 해제 완료\n")
 
 	fmt.Printf("✅ 방송 목록 구독자 등록 완료: %s (총 %d명)\n", userID, subscriberCount)
+	wsService.sendAuthenticated(connection)
 
 	// 즉시 방송 목록 전송
 	fmt.Printf("📤 즉시 방송 목록 전송...\n")
-	wsService.sendBroadcastList(conn)
+	wsService.sendBroadcastList(connection)
 
 	defer func() {
 		fmt.Printf("🧹 목록 구독자 정리 시작: %s\n", userID)
 		wsService.Mutex.Lock()
 		if wsService.ListSubscribers != nil {
-			delete(wsService.ListSubscribers, conn)
+			delete(wsService.ListSubscribers, connection)
 		}
 		wsService.Mutex.Unlock()
 		fmt.Printf("🧹 목록 구독자 정리 완료: %s\n", userID)
@@ -300,7 +418,7 @@ func (wsService *WebSocketService) HandleViewerList(conn *websocket.Conn) {
 	fmt.Printf("🔄 메시지 수신 루프 시작: %s\n", userID)
 
 	for {
-		_, message, err := conn.ReadMessage()
+		message, err := connection.ReadMessage()
 		if err != nil {
 			fmt.Printf("❌ 목록 구독자 연결 종료 (%s): %v\n", userID, err)
 			break
@@ -317,11 +435,10 @@ func (wsService *WebSocketService) HandleViewerList(conn *websocket.Conn) {
 		switch msg.Type {
 		case "get_broadcast_list":
 			fmt.Printf("📋 방송 목록 요청 수신 from %s\n", userID)
-			wsService.sendBroadcastList(conn)
+			wsService.sendBroadcastList(connection)
 		case "ping":
 			fmt.Printf("🏓 Ping 수신 from %s\n", userID)
-			pongMsg := fmt.Sprintf(`{"type": "pong", "timestamp": "%s"}`, time.Now().Format(time.RFC3339))
-			conn.WriteMessage(websocket.TextMessage, []byte(pongMsg))
+			wsService.sendPong(connection)
 		default:
 			fmt.Printf("❓ 알 수 없는 메시지 타입 (%s): %s\n", userID, msg.Type)
 		}
@@ -337,14 +454,56 @@ func (wsService *WebSocketService) handleBroadcasterMessage(broadcasterID string
 	switch msg.Type {
 	case "start_broadcast":
 		wsService.startBroadcast(broadcasterID, msg)
+	case "update_broadcast":
+		wsService.updateBroadcast(broadcasterID, msg)
 	case "stop_broadcast":
 		wsService.stopBroadcast(broadcasterID)
+	case "resume_broadcast":
+		wsService.resumeBroadcast(broadcasterID, resumeTokenFromMessage(msg))
+	case "publish":
+		wsService.publishStream(broadcasterID, msg)
+	case "start_recording", "stop_recording":
+		wsService.handleRecordingMessage(broadcasterID, msg)
 	case "offer":
 		wsService.forwardOfferToViewer(broadcasterID, msg, rawMessage)
 	case "candidate":
-		wsService.forwardCandidateToViewer(broadcasterID, msg, rawMessage)
+		if !wsService.handleSFUBroadcasterCandidate(broadcasterID, msg) {
+			wsService.forwardCandidateToViewer(broadcasterID, msg, rawMessage)
+		}
 	case "offer_request":
 		wsService.handleOfferRequest(broadcasterID, msg)
+	case "kick_viewer", "ban_viewer", "unban_viewer", "mute_viewer", "unmute_viewer", "slow_mode", "add_moderator", "remove_moderator":
+		wsService.Mutex.RLock()
+		broadcaster := wsService.Broadcasters[broadcasterID]
+		wsService.Mutex.RUnlock()
+		if broadcaster != nil {
+			wsService.handleModeration(broadcaster, broadcasterID, msg)
+		}
+	case "chat_message":
+		wsService.Mutex.RLock()
+		broadcaster := wsService.Broadcasters[broadcasterID]
+		wsService.Mutex.RUnlock()
+		if broadcaster != nil {
+			wsService.handleChatMessage(broadcaster, broadcasterID, msg)
+		}
+	case "stats_report":
+		wsService.handleStatsReport(broadcasterID, broadcasterID, "broadcaster", msg)
+	case "stream_event":
+		wsService.Mutex.RLock()
+		broadcaster := wsService.Broadcasters[broadcasterID]
+		wsService.Mutex.RUnlock()
+		if broadcaster != nil {
+			wsService.handleStreamEvent(broadcasterID, broadcasterID, msg, func(response *Message) {
+				wsService.sendToConnection(broadcaster, response)
+			})
+		}
+	case "ping":
+		wsService.Mutex.RLock()
+		broadcaster := wsService.Broadcasters[broadcasterID]
+		wsService.Mutex.RUnlock()
+		if broadcaster != nil {
+			wsService.sendPong(broadcaster)
+		}
 	default:
 		fmt.Printf("🔄 알 수 없는 방송자 메시지: %s\n", msg.Type)
 	}
@@ -358,13 +517,66 @@ func (wsService *WebSocketService) handleViewerMessage(viewerID string, msg *Mes
 	case "request_stream":
 		wsService.handleStreamRequest(viewerID, msg)
 	case "answer":
-		wsService.forwardAnswerToBroadcaster(viewerID, msg, rawMessage)
+		if !wsService.handleSFUViewerSignal(viewerID, msg) {
+			wsService.forwardAnswerToBroadcaster(viewerID, msg, rawMessage)
+		}
 	case "candidate":
-		wsService.forwardCandidateToBroadcaster(viewerID, msg, rawMessage)
+		if !wsService.handleSFUViewerSignal(viewerID, msg) {
+			wsService.forwardCandidateToBroadcaster(viewerID, msg, rawMessage)
+		}
 	case "viewer_join":
 		wsService.handleViewerJoin(viewerID, msg)
 	case "viewer_leave":
 		wsService.handleViewerLeave(viewerID, msg)
+	case "select_quality":
+		wsService.handleSelectQuality(viewerID, msg)
+	case "stats_report":
+		wsService.Mutex.RLock()
+		viewer := wsService.Viewers[viewerID]
+		wsService.Mutex.RUnlock()
+		if viewer != nil {
+			wsService.handleStatsReport(viewer.BroadcasterID, viewerID, "viewer", msg)
+		}
+	case "subscribe_events":
+		wsService.Mutex.RLock()
+		viewer := wsService.Viewers[viewerID]
+		wsService.Mutex.RUnlock()
+		if viewer != nil {
+			topics := wsService.subscribeEvents(viewer.BroadcasterID, viewerID, msg)
+			wsService.sendToConnection(viewer.Connection, eventSubscriptionMessage(viewer.BroadcasterID, topics))
+		}
+	case "stream_event":
+		// 방송자가 지정한 매니저만 처리됨
+		wsService.Mutex.RLock()
+		viewer := wsService.Viewers[viewerID]
+		wsService.Mutex.RUnlock()
+		if viewer != nil {
+			wsService.handleStreamEvent(viewer.BroadcasterID, viewer.Connection.UserID, msg, func(response *Message) {
+				wsService.sendToConnection(viewer.Connection, response)
+			})
+		}
+	case "chat_message":
+		wsService.Mutex.RLock()
+		viewer := wsService.Viewers[viewerID]
+		wsService.Mutex.RUnlock()
+		if viewer != nil {
+			wsService.handleChatMessage(viewer.Connection, viewer.BroadcasterID, msg)
+		}
+	case "kick_viewer", "ban_viewer", "unban_viewer", "mute_viewer", "unmute_viewer", "slow_mode":
+		// 방송자가 지정한 매니저만 처리됨
+		wsService.Mutex.RLock()
+		viewer := wsService.Viewers[viewerID]
+		wsService.Mutex.RUnlock()
+		if viewer != nil {
+			wsService.handleModeration(viewer.Connection, viewer.BroadcasterID, msg)
+		}
+	case "ping":
+		wsService.Mutex.RLock()
+		viewer := wsService.Viewers[viewerID]
+		wsService.Mutex.RUnlock()
+		if viewer != nil {
+			wsService.sendPong(viewer.Connection)
+		}
 	default:
 		fmt.Printf("🔄 알 수 없는 시청자 메시지: %s\n", msg.Type)
 	}
@@ -372,6 +584,8 @@ func (wsService *WebSocketService) handleViewerMessage(viewerID string, msg *Mes
 
 // 방송 시작
 func (wsService *WebSocketService) startBroadcast(broadcasterID string, msg *Message) {
+	wsService.loadModeration(broadcasterID)
+
 	wsService.Mutex.Lock()
 	defer wsService.Mutex.Unlock()
 
@@ -381,18 +595,77 @@ func (wsService *WebSocketService) startBroadcast(broadcasterID string, msg *Mes
 		return
 	}
 
-	broadcast := &BroadcastInfo{
-		BroadcasterID:   broadcasterID,
-		BroadcasterName: broadcaster.Name,
-		StartTime:       time.Now(),
-		ViewerCount:     0,
-		IsLive:          true,
+	// 제목, 카테고리 등 메타데이터와 공개 범위
+	metadata := BroadcastMetadata{}
+	broadcast := &BroadcastInfo{}
+	update, err := parseMetadataUpdate(msg.Data)
+	if err == nil {
+		err = metadata.apply(update)
+	}
+	if err == nil {
+		var accessUpdate *broadcastAccessUpdate
+		if accessUpdate, err = parseAccessUpdate(msg.Data); err == nil {
+			err = broadcast.applyAccess(accessUpdate)
+		}
+	}
+	if err == nil {
+		broadcast.Mode, err = broadcastModeFromMessage(msg)
+	}
+	if err != nil {
+		wsService.sendToConnection(broadcaster, &Message{
+			Type: "error",
+			Data: err.Error(),
+		})
+		return
+	}
+
+	if previous := wsService.ActiveBroadcasts[broadcasterID]; previous != nil {
+		if previous.reconnectTimer != nil {
+			previous.reconnectTimer.Stop()
+		}
+		if previous.Mode == BroadcastModeSFU {
+			// 파일 정리는 잠금 밖에서 실행
+			go wsService.completeRecording(wsService.finishRecording(previous))
+			wsService.webrtc.Unpublish(broadcasterID)
+		}
+		wsService.closeWHIPResources(previous)
+	}
+
+	broadcast.BroadcasterID = broadcasterID
+	broadcast.BroadcasterName = broadcaster.Name
+	broadcast.StartTime = time.Now()
+	broadcast.ViewerCount = 0
+	broadcast.IsLive = true
+	broadcast.Status = BroadcastStatusLive
+	broadcast.BroadcastMetadata = metadata
+	broadcast.resumeToken = newResumeToken()
+	broadcast.chat = newChatRoom()
+	broadcast.MaxViewers = normalizeMaxViewers(config.MaxViewers)
+	if maxViewers, ok := maxViewersFromMessage(msg); ok {
+		broadcast.MaxViewers = normalizeMaxViewers(maxViewers)
 	}
 
-	wsService.ActiveBroadcasts[broadcasterID] = broadcast
+	broadcast.Ingest = BroadcastIngestWebSocket
+	wsService.registerBroadcast(broadcast)
+
+	// 방송자에게 재접속용 세션 정보 전달
+	wsService.sendBroadcastSession(broadcaster, broadcast)
+}
+
+// 방송을 활성 목록에 등록하고 목록 구독자에게 알림 (호출자가 잠금을 보유해야 함)
+func (wsService *WebSocketService) registerBroadcast(broadcast *BroadcastInfo) {
+	wsService.ActiveBroadcasts[broadcast.BroadcasterID] = broadcast
+	wsService.recordBroadcastStart(broadcast)
+	wsService.kickBannedViewers(broadcast.BroadcasterID)
+	wsService.detachUngrantedViewers(broadcast)
 	fmt.Printf("🔴 방송 시작:\n")
 	fmt.Printf("  - ID: %s\n", broadcast.BroadcasterID)
 	fmt.Printf("  - 이름: %s\n", broadcast.BroadcasterName)
+	fmt.Printf("  - 제목: %s\n", broadcast.Title)
+	fmt.Printf("  - 카테고리: %s\n", broadcast.Category)
+	fmt.Printf("  - 공개 범위: %s\n", broadcast.Visibility)
+	fmt.Printf("  - 최대 시청자 수: %d\n", broadcast.MaxViewers)
+	fmt.Printf("  - 전송 방식: %s (%s)\n", broadcast.Mode, broadcast.Ingest)
 	fmt.Printf("  - 시작 시간: %s\n", broadcast.StartTime.Format("2006-01-02 15:04:05"))
 
 	wsService.printCurrentState()
@@ -418,7 +691,19 @@ func (wsService *WebSocketService) stopBroadcast(broadcasterID string) {
 		return
 	}
 
+	if broadcast.reconnectTimer != nil {
+		broadcast.reconnectTimer.Stop()
+	}
+
 	delete(wsService.ActiveBroadcasts, broadcasterID)
+	delete(wsService.moderation, broadcasterID)
+	wsService.recordBroadcastEnd(broadcast)
+	if broadcast.Mode == BroadcastModeSFU {
+		// 파일 정리는 잠금 밖에서 실행
+		go wsService.completeRecording(wsService.finishRecording(broadcast))
+		wsService.webrtc.Unpublish(broadcasterID)
+	}
+	wsService.closeWHIPResources(broadcast)
 	fmt.Printf("⚫ 방송 종료: %s (%s)\n", broadcast.BroadcasterName, broadcasterID)
 
 	// 해당 방송의 모든 시청자에게 방송 종료 알림 전송
@@ -432,32 +717,32 @@ func (wsService *WebSocketService) stopBroadcast(broadcasterID string) {
 	for _, viewer := range wsService.Viewers {
 		if viewer.BroadcasterID == broadcasterID {
 			// 방송 종료 알림 전송
-			wsService.sendToConnection(viewer.Connection.Conn, broadcastEndMsg)
+			wsService.sendToConnection(viewer.Connection, broadcastEndMsg)
 			notifiedViewers++
 		}
 	}
 	fmt.Printf("📺 방송 %s 종료 알림을 %d명의 시청자에게 전송\n", broadcasterID, notifiedViewers)
 
-	// 잠시 대기 후 연결 해제 (알림 전송 시간 확보)
-	go func() {
-		time.Sleep(100 * time.Millisecond)
-		wsService.Mutex.Lock()
-		defer wsService.Mutex.Unlock()
-		
-		// 해당 방송의 모든 시청자 연결 해제
-		for viewerID, viewer := range wsService.Viewers {
-			if viewer.BroadcasterID == broadcasterID {
-				viewer.Connection.Conn.Close()
-				delete(wsService.Viewers, viewerID)
-			}
+	// 대기열의 시청자에게도 종료 알림
+	wsService.closeQueue(broadcast, broadcastEndMsg)
+
+	// 해당 방송의 모든 시청자 연결 해제 (송신 큐의 종료 알림을 보낸 뒤 닫힘)
+	for viewerID, viewer := range wsService.Viewers {
+		if viewer.BroadcasterID == broadcasterID {
+			wsService.recordViewerLeave(viewer)
+			viewer.Connection.Shutdown()
+			delete(wsService.Viewers, viewerID)
+			delete(wsService.PendingOffers, viewerID)
 		}
-	}()
+	}
 
-	// 모든 목록 구독자에게 알림
-	wsService.broadcastToListSubscribers(&Message{
-		Type:          "broadcast_ended",
-		BroadcasterID: broadcasterID,
-	})
+	// 모든 목록 구독자에게 알림 (목록에 노출된 방송만)
+	if broadcast.isListed() {
+		wsService.sendToListSubscribers(&Message{
+			Type:          "broadcast_ended",
+			BroadcasterID: broadcasterID,
+		})
+	}
 }
 
 // 스트림 요청 처리
@@ -475,34 +760,54 @@ func (wsService *WebSocketService) handleStreamRequest(viewerID string, msg *Mes
 	if broadcasterID == "" {
 		broadcasterID = viewer.BroadcasterID
 	}
+	if broadcasterID == "" {
+		broadcasterID = viewer.requested
+	}
 
 	fmt.Printf("🎯 스트림 요청: 시청자 %s -> 방송자 %s\n", viewerID, broadcasterID)
 
+	if wsService.isBanned(broadcasterID, viewerID) {
+		fmt.Printf("🚫 차단된 시청자 스트림 요청 거부: %s -> %s\n", viewerID, broadcasterID)
+		wsService.sendBanned(viewer.Connection, broadcasterID)
+		return
+	}
+
 	broadcaster := wsService.Broadcasters[broadcasterID]
 	broadcast := wsService.ActiveBroadcasts[broadcasterID]
 
-	if broadcaster == nil || broadcast == nil {
+	// 비공개 방송 접근 확인 (비밀번호 또는 초대 토큰)
+	if broadcast != nil {
+		password, invite := accessCredentialsFromMessage(msg)
+		if code := wsService.checkAccess(broadcast, viewer, password, invite); code != "" {
+			fmt.Printf("🔒 비공개 방송 접근 거부: %s -> %s (%s)\n", viewerID, broadcasterID, code)
+			viewer.requested = broadcasterID
+			wsService.sendAccessDenied(viewer.Connection, broadcast, code)
+			return
+		}
+	}
+
+	// 방송자가 재접속 중이면 자리만 잡아 두고, 재개될 때 Offer 요청을 다시 보냄
+	if broadcast == nil || (broadcaster == nil && broadcast.Status != BroadcastStatusReconnecting && !broadcast.isWHIP()) {
 		fmt.Printf("❌ 방송자 또는 방송을 찾을 수 없음: %s\n", broadcasterID)
 		errorMsg := &Message{
 			Type: "error",
 			Data: "방송을 찾을 수 없습니다.",
 		}
-		wsService.sendToConnection(viewer.Connection.Conn, errorMsg)
+		wsService.sendToConnection(viewer.Connection, errorMsg)
 		return
 	}
 
-	broadcast.ViewerCount++
-	viewer.BroadcasterID = broadcasterID
+	// 다른 방송의 대기열이나 자리는 반납
+	wsService.leaveOtherBroadcast(viewerID, viewer, broadcasterID)
 
-	offerRequest := &Message{
-		Type:       "offer_request",
-		ViewerID:   viewerID,
-		ViewerName: viewer.Connection.Name,
+	// 이미 자리가 있는 시청자의 재요청이 아니면 빈 자리 확인
+	if !viewer.admitted && !wsService.hasCapacity(broadcast) {
+		fmt.Printf("🈵 최대 시청자 수 도달: 방송 %s (%d명), %s 대기열로 이동\n", broadcasterID, broadcast.MaxViewers, viewerID)
+		wsService.enqueueViewer(viewerID, viewer, broadcast)
+		return
 	}
 
-	wsService.sendToConnection(broadcaster.Conn, offerRequest)
-	wsService.PendingOffers[viewerID] = broadcasterID
-	wsService.updateViewerCount(broadcasterID, broadcast.ViewerCount)
+	wsService.admitViewer(viewerID, viewer, broadcast)
 
 	fmt.Printf("✅ 스트림 요청 처리 완료: %s -> %s\n", viewerID, broadcasterID)
 }
@@ -532,8 +837,8 @@ func (wsService *WebSocketService) forwardOfferToViewer(broadcasterID string, ms
 		msgData["broadcaster_id"] = broadcasterID
 
 		modifiedMessage, _ := json.Marshal(msgData)
-		if err := viewer.Connection.Conn.WriteMessage(websocket.TextMessage, modifiedMessage); err != nil {
-			fmt.Printf("❌ Offer 전달 실패: %v\n", err)
+		if !viewer.Connection.Send(modifiedMessage) {
+			fmt.Printf("❌ Offer 전달 실패: %s\n", viewerID)
 		} else {
 			fmt.Printf("✅ Offer 전달 성공: %s -> %s\n", broadcasterID, viewerID)
 		}
@@ -559,8 +864,8 @@ func (wsService *WebSocketService) forwardAnswerToBroadcaster(viewerID string, m
 		msgData["viewer_id"] = viewerID
 
 		modifiedMessage, _ := json.Marshal(msgData)
-		if err := broadcaster.Conn.WriteMessage(websocket.TextMessage, modifiedMessage); err != nil {
-			fmt.Printf("❌ Answer 전달 실패: %v\n", err)
+		if !broadcaster.Send(modifiedMessage) {
+			fmt.Printf("❌ Answer 전달 실패: %s\n", broadcasterID)
 		} else {
 			fmt.Printf("✅ Answer 전달 성공: %s -> %s\n", viewerID, broadcasterID)
 		}
@@ -580,7 +885,7 @@ func (wsService *WebSocketService) forwardCandidateToViewer(broadcasterID string
 		msgData["broadcaster_id"] = broadcasterID
 
 		modifiedMessage, _ := json.Marshal(msgData)
-		viewer.Connection.Conn.WriteMessage(websocket.TextMessage, modifiedMessage)
+		viewer.Connection.Send(modifiedMessage)
 	}
 }
 
@@ -601,7 +906,7 @@ func (wsService *WebSocketService) forwardCandidateToBroadcaster(viewerID string
 		msgData["viewer_id"] = viewerID
 
 		modifiedMessage, _ := json.Marshal(msgData)
-		broadcaster.Conn.WriteMessage(websocket.TextMessage, modifiedMessage)
+		broadcaster.Send(modifiedMessage)
 	}
 }
 
@@ -620,6 +925,9 @@ func (wsService *WebSocketService) handleViewerJoin(viewerID string, msg *Messag
 	if broadcasterID == "" {
 		broadcasterID = viewer.BroadcasterID
 	}
+	if broadcasterID == "" {
+		broadcasterID = viewer.requested
+	}
 
 	broadcast := wsService.ActiveBroadcasts[broadcasterID]
 	if broadcast == nil {
@@ -627,10 +935,39 @@ func (wsService *WebSocketService) handleViewerJoin(viewerID string, msg *Messag
 		return
 	}
 
-	// 시청자 수 증가
-	broadcast.ViewerCount++
-	viewer.BroadcasterID = broadcasterID
+	if wsService.isBanned(broadcasterID, viewerID) {
+		fmt.Printf("🚫 차단된 시청자 입장 거부: %s -> %s\n", viewerID, broadcasterID)
+		wsService.sendBanned(viewer.Connection, broadcasterID)
+		return
+	}
+
+	password, invite := accessCredentialsFromMessage(msg)
+	if code := wsService.checkAccess(broadcast, viewer, password, invite); code != "" {
+		fmt.Printf("🔒 비공개 방송 입장 거부: %s -> %s (%s)\n", viewerID, broadcasterID, code)
+		viewer.requested = broadcasterID
+		wsService.sendAccessDenied(viewer.Connection, broadcast, code)
+		return
+	}
+
+	// 이미 이 방송에 자리가 있으면 다시 세지 않고 확인 메시지만 보냄
+	if viewer.admitted && viewer.BroadcasterID == broadcasterID {
+		wsService.sendJoinConfirmed(viewerID, viewer, broadcast)
+		return
+	}
+
+	// 다른 방송의 대기열이나 자리는 반납
+	wsService.leaveOtherBroadcast(viewerID, viewer, broadcasterID)
+
+	// 빈 자리가 없으면 대기열로
+	if !wsService.hasCapacity(broadcast) {
+		fmt.Printf("🈵 최대 시청자 수 도달: 방송 %s (%d명), %s 대기열로 이동\n", broadcasterID, broadcast.MaxViewers, viewerID)
+		wsService.enqueueViewer(viewerID, viewer, broadcast)
+		return
+	}
+
 	viewer.JoinTime = time.Now()
+	wsService.admitViewer(viewerID, viewer, broadcast)
+	wsService.recordViewerJoin(viewer, broadcast)
 
 	fmt.Printf("👋 시청자 입장: %s (%s) -> 방송 %s, 총 시청자 수: %d명\n", 
 		viewer.Connection.Name, viewerID, broadcasterID, broadcast.ViewerCount)
@@ -643,19 +980,26 @@ func (wsService *WebSocketService) handleViewerJoin(viewerID string, msg *Messag
 			ViewerName: viewer.Connection.Name,
 			Count:      broadcast.ViewerCount,
 		}
-		wsService.sendToConnection(broadcaster.Conn, joinMsg)
+		wsService.sendToConnection(broadcaster, joinMsg)
 	}
 
-	// 시청자 수 업데이트
-	wsService.updateViewerCount(broadcasterID, broadcast.ViewerCount)
+	wsService.sendJoinConfirmed(viewerID, viewer, broadcast)
+}
 
-	// 시청자에게 입장 확인 메시지
+// 시청자에게 입장 확인 메시지 (채팅 기록과 ICE 설정 포함, 호출자가 잠금을 보유해야 함)
+func (wsService *WebSocketService) sendJoinConfirmed(viewerID string, viewer *ViewerInfo, broadcast *BroadcastInfo) {
+	broadcasterID := broadcast.BroadcasterID
 	confirmMsg := &Message{
 		Type:          "join_confirmed",
 		BroadcasterID: broadcasterID,
 		Broadcast:     broadcast,
+		Data: addICEConfiguration(map[string]interface{}{
+			"chat":      broadcast.chat.history(0),
+			"slow_mode": int(broadcast.chat.slowMode.Seconds()),
+			"moderator": wsService.isModerator(broadcasterID, viewerID),
+		}, viewer.Connection.UserID),
 	}
-	wsService.sendToConnection(viewer.Connection.Conn, confirmMsg)
+	wsService.sendToConnection(viewer.Connection, confirmMsg)
 }
 
 // 시청자 퇴장 처리
@@ -689,7 +1033,7 @@ func (wsService *WebSocketService) handleViewerLeave(viewerID string, msg *Messa
 				ViewerName: viewer.Connection.Name,
 				Count:      broadcast.ViewerCount,
 			}
-			wsService.sendToConnection(broadcaster.Conn, leaveMsg)
+			wsService.sendToConnection(broadcaster, leaveMsg)
 		}
 
 		// 시청자 수 업데이트
@@ -701,17 +1045,20 @@ func (wsService *WebSocketService) handleViewerLeave(viewerID string, msg *Messa
 		Type:          "leave_confirmed",
 		BroadcasterID: broadcasterID,
 	}
-	wsService.sendToConnection(viewer.Connection.Conn, confirmMsg)
+	wsService.sendToConnection(viewer.Connection, confirmMsg)
 
 	// 시청자 완전 제거 (중복 처리 방지)
+	wsService.recordViewerLeave(viewer)
 	delete(wsService.Viewers, viewerID)
 	delete(wsService.PendingOffers, viewerID)
+
+	// 빈 자리는 대기열의 다음 시청자에게
+	wsService.releaseViewerSlot(viewerID, viewer, broadcasterID)
 }
 
 // 시청자 수 업데이트
 func (wsService *WebSocketService) updateViewerCount(broadcasterID string, count int) {
 	fmt.Println("🔄 시청자 수 업데이트:", broadcasterID, "->", count)
-	fmt.Println(broadcasterID, count)
 	
 	// 방송별 시청자 수 상세 로그
 	fmt.Printf("📊 방송별 시청자 수 현황:\n")
@@ -733,7 +1080,7 @@ func (wsService *WebSocketService) updateViewerCount(broadcasterID string, count
 			Type:  "viewer_count_update",
 			Count: count,
 		}
-		wsService.sendToConnection(wsService.Broadcasters[broadcasterID].Conn, msg)
+		wsService.sendToConnection(wsService.Broadcasters[broadcasterID], msg)
 	}
 
 	// 해당 방송의 모든 시청자에게 시청자 수 업데이트 전송
@@ -746,7 +1093,7 @@ func (wsService *WebSocketService) updateViewerCount(broadcasterID string, count
 	sentToViewers := 0
 	for _, viewer := range wsService.Viewers {
 		if viewer.BroadcasterID == broadcasterID {
-			wsService.sendToConnection(viewer.Connection.Conn, viewerUpdateMsg)
+			wsService.sendToConnection(viewer.Connection, viewerUpdateMsg)
 			sentToViewers++
 		}
 	}
@@ -775,11 +1122,54 @@ func (wsService *WebSocketService) removeViewer(viewerID string) {
 			wsService.updateViewerCount(broadcasterID, broadcast.ViewerCount)
 		}
 
+		wsService.recordViewerLeave(viewer)
 		delete(wsService.Viewers, viewerID)
 		delete(wsService.PendingOffers, viewerID)
+		wsService.releaseViewerSlot(viewerID, viewer, broadcasterID)
 	}
 }
 
+// 시청자 제거 (해당 연결이 현재 등록된 연결일 때만)
+func (wsService *WebSocketService) removeViewerInfo(viewerID string, viewerInfo *ViewerInfo) bool {
+	wsService.Mutex.RLock()
+	current := wsService.Viewers[viewerID] == viewerInfo
+	wsService.Mutex.RUnlock()
+	if !current {
+		return false
+	}
+
+	wsService.removeViewer(viewerID)
+	return true
+}
+
+// 방송자 제거 (해당 연결이 현재 등록된 연결일 때만 방송 종료)
+func (wsService *WebSocketService) removeBroadcaster(broadcasterID string, connection *Connection) bool {
+	wsService.Mutex.RLock()
+	current := wsService.Broadcasters[broadcasterID] == connection
+	wsService.Mutex.RUnlock()
+	if !current {
+		return false
+	}
+
+	// WHIP 방송은 시그널링 소켓과 무관하게 유지
+	wsService.Mutex.RLock()
+	broadcast := wsService.ActiveBroadcasts[broadcasterID]
+	whip := broadcast != nil && broadcast.isWHIP()
+	wsService.Mutex.RUnlock()
+
+	// 유예 시간 동안 방송을 유지하고, 유예가 없으면 바로 종료
+	if !whip && !wsService.suspendBroadcast(broadcasterID, connection) {
+		wsService.stopBroadcast(broadcasterID)
+	}
+
+	wsService.Mutex.Lock()
+	if wsService.Broadcasters[broadcasterID] == connection {
+		delete(wsService.Broadcasters, broadcasterID)
+	}
+	wsService.Mutex.Unlock()
+	return true
+}
+
 // 현재 상태 출력
 func (wsService *WebSocketService) printCurrentState() {
 	fmt.Printf("\n📊 현재 서버 상태:\n")
@@ -797,14 +1187,11 @@ func (wsService *WebSocketService) printCurrentState() {
 }
 
 // 방송 목록 전송
-func (wsService *WebSocketService) sendBroadcastList(conn *websocket.Conn) {
+func (wsService *WebSocketService) sendBroadcastList(connection *Connection) {
 	wsService.Mutex.RLock()
 	defer wsService.Mutex.RUnlock()
 
-	broadcasts := make([]BroadcastInfo, 0, len(wsService.ActiveBroadcasts))
-	for _, broadcast := range wsService.ActiveBroadcasts {
-		broadcasts = append(broadcasts, *broadcast)
-	}
+	broadcasts := wsService.activeBroadcastList()
 
 	msg := &Message{
 		Type:       "broadcast_list",
@@ -823,15 +1210,28 @@ func (wsService *WebSocketService) sendBroadcastList(conn *websocket.Conn) {
 			i+1, broadcast.BroadcasterName, broadcast.BroadcasterID, broadcast.ViewerCount)
 	}
 
-	if err := conn.WriteMessage(websocket.TextMessage, data); err != nil {
-		fmt.Printf("❌ 방송 목록 전송 실패: %v\n", err)
+	if !connection.Send(data) {
+		fmt.Printf("❌ 방송 목록 전송 실패: %s\n", connection.UserID)
 	} else {
 		fmt.Printf("✅ 방송 목록 전송 성공\n")
 	}
 }
 
-// 구독자들에게 브로드캐스트
+// 구독자들에게 브로드캐스트 (목록에 노출되지 않는 방송에 대한 알림은 보내지 않음)
 func (wsService *WebSocketService) broadcastToListSubscribers(msg *Message) {
+	broadcast := msg.Broadcast
+	if broadcast == nil {
+		broadcast = wsService.ActiveBroadcasts[toString(msg.BroadcasterID)]
+	}
+	if broadcast != nil && !broadcast.isListed() {
+		return
+	}
+
+	wsService.sendToListSubscribers(msg)
+}
+
+// 구독자들에게 전송 (공개 범위 확인 없음)
+func (wsService *WebSocketService) sendToListSubscribers(msg *Message) {
 	data, err := json.Marshal(msg)
 	if err != nil {
 		fmt.Printf("❌ 구독자 알림 JSON 마샬링 오류: %v\n", err)
@@ -841,9 +1241,9 @@ func (wsService *WebSocketService) broadcastToListSubscribers(msg *Message) {
 	fmt.Printf("📢 구독자들에게 브로드캐스트: %s\n", msg.Type)
 	successCount := 0
 	
-	for conn, userID := range wsService.ListSubscribers {
-		if err := conn.WriteMessage(websocket.TextMessage, data); err != nil {
-			fmt.Printf("❌ 구독자 %s에게 전송 실패: %v\n", userID, err)
+	for connection, userID := range wsService.ListSubscribers {
+		if !connection.Send(data) {
+			fmt.Printf("❌ 구독자 %s에게 전송 실패\n", userID)
 		} else {
 			successCount++
 		}
@@ -851,32 +1251,53 @@ func (wsService *WebSocketService) broadcastToListSubscribers(msg *Message) {
 	fmt.Printf("📊 총 %d명 중 %d명에게 성공적으로 전송\n", len(wsService.ListSubscribers), successCount)
 }
 
-// 연결에 메시지 전송
-func (wsService *WebSocketService) sendToConnection(conn *websocket.Conn, msg *Message) {
+// 연결에 메시지 전송 (송신 큐에 추가)
+func (wsService *WebSocketService) sendToConnection(connection *Connection, msg *Message) {
 	data, err := json.Marshal(msg)
 	if err != nil {
 		fmt.Printf("❌ JSON 마샬링 오류: %v\n", err)
 		return
 	}
 
-	if err := conn.WriteMessage(websocket.TextMessage, data); err != nil {
-		fmt.Printf("❌ 메시지 전송 실패: %v\n", err)
+	if !connection.Send(data) {
+		fmt.Printf("❌ 메시지 전송 실패: %s\n", connection.UserID)
 	}
 }
 
+// Pong 응답 (애플리케이션 레벨 ping)
+func (wsService *WebSocketService) sendPong(connection *Connection) {
+	wsService.sendToConnection(connection, &Message{
+		Type:      "pong",
+		Timestamp: time.Now().Format(time.RFC3339),
+	})
+}
+
 // Offer 요청 처리
 func (wsService *WebSocketService) handleOfferRequest(broadcasterID string, msg *Message) {
 	fmt.Printf("🔔 Offer 요청 처리: %s\n", broadcasterID)
 }
 
-// API용 활성 방송 목록 반환
-func (wsService *WebSocketService) GetActiveBroadcasts() []BroadcastInfo {
+// API용 활성 방송 목록 반환 (카테고리, 태그, 언어 필터)
+func (wsService *WebSocketService) GetActiveBroadcasts(filter BroadcastFilter) []BroadcastInfo {
 	wsService.Mutex.RLock()
 	defer wsService.Mutex.RUnlock()
 
 	broadcasts := make([]BroadcastInfo, 0, len(wsService.ActiveBroadcasts))
 	for _, broadcast := range wsService.ActiveBroadcasts {
-		broadcasts = append(broadcasts, *broadcast)
+		if broadcast.isListed() && filter.matches(broadcast) {
+			broadcasts = append(broadcasts, *broadcast)
+		}
+	}
+	return broadcasts
+}
+
+// 목록에 노출되는 활성 방송 복사 (호출자가 잠금을 보유해야 함)
+func (wsService *WebSocketService) activeBroadcastList() []BroadcastInfo {
+	broadcasts := make([]BroadcastInfo, 0, len(wsService.ActiveBroadcasts))
+	for _, broadcast := range wsService.ActiveBroadcasts {
+		if broadcast.isListed() {
+			broadcasts = append(broadcasts, *broadcast)
+		}
 	}
 	return broadcasts
 }
@@ -887,20 +1308,27 @@ func (wsService *WebSocketService) GetBroadcastStats(broadcasterID string) map[s
 	defer wsService.Mutex.RUnlock()
 
 	broadcast := wsService.ActiveBroadcasts[broadcasterID]
-	if broadcast == nil {
+	if broadcast == nil || broadcast.Visibility == BroadcastVisibilityPrivate {
 		return map[string]interface{}{
 			"error": "방송을 찾을 수 없습니다",
 		}
 	}
 
+	// 연결 품질 (방송자, 시청자별 최근 측정값)
+	quality := wsService.telemetry.broadcastQuality(broadcasterID)
+	viewerQuality, _ := quality["viewers"].(map[string]interface{})
+
 	// 해당 방송의 시청자 목록
 	viewers := make([]map[string]interface{}, 0)
-	for _, viewer := range wsService.Viewers {
+	for viewerID, viewer := range wsService.Viewers {
 		if viewer.BroadcasterID == broadcasterID {
 			viewers = append(viewers, map[string]interface{}{
 				"viewer_id":   viewer.Connection.UserID,
 				"viewer_name": viewer.Connection.Name,
 				"join_time":   viewer.JoinTime,
+				"queue_depth": viewer.Connection.QueueDepth(),
+				"dropped":     viewer.Connection.Dropped(),
+				"quality":     viewerQuality[viewerID],
 			})
 		}
 	}
@@ -909,6 +1337,7 @@ func (wsService *WebSocketService) GetBroadcastStats(broadcasterID string) map[s
 		"broadcast_info": broadcast,
 		"viewers":        viewers,
 		"viewer_count":   len(viewers),
+		"quality":        quality,
 	}
 }
 
@@ -918,17 +1347,42 @@ func (wsService *WebSocketService) GetServerStatus() map[string]interface{} {
 	defer wsService.Mutex.RUnlock()
 
 	// 방송별 시청자 수 계산
-	broadcasterViewers := make(map[string]int)
+	viewerCounts := make(map[string]int)
 	for _, viewer := range wsService.Viewers {
 		if viewer.BroadcasterID != "" {
-			broadcasterViewers[viewer.BroadcasterID]++
+			viewerCounts[viewer.BroadcasterID]++
 		}
 	}
 
-	// 총 시청자 수 계산
+	// 총 시청자 수는 전체 기준, 방송별 목록은 목록에 노출되는 방송만 (일부 공개/비공개 방송 ID 노출 방지)
 	totalActiveViewers := 0
-	for _, count := range broadcasterViewers {
+	broadcasterViewers := make(map[string]int)
+	for broadcasterID, count := range viewerCounts {
 		totalActiveViewers += count
+		if broadcast, exists := wsService.ActiveBroadcasts[broadcasterID]; exists && broadcast.isListed() {
+			broadcasterViewers[broadcasterID] = count
+		}
+	}
+
+	// 송신 큐 현황
+	totalDepth := 0
+	maxDepth := 0
+	connections := make([]*Connection, 0, len(wsService.Broadcasters)+len(wsService.Viewers)+len(wsService.ListSubscribers))
+	for _, broadcaster := range wsService.Broadcasters {
+		connections = append(connections, broadcaster)
+	}
+	for _, viewer := range wsService.Viewers {
+		connections = append(connections, viewer.Connection)
+	}
+	for subscriber := range wsService.ListSubscribers {
+		connections = append(connections, subscriber)
+	}
+	for _, connection := range connections {
+		depth := connection.QueueDepth()
+		totalDepth += depth
+		if depth > maxDepth {
+			maxDepth = depth
+		}
 	}
 
 	averageViewers := 0.0
@@ -953,6 +1407,17 @@ func (wsService *WebSocketService) GetServerStatus() map[string]interface{} {
 			"average_viewers":     averageViewers,
 			"broadcaster_viewers": broadcasterViewers,
 		},
-		"active_broadcasts": wsService.GetActiveBroadcasts(),
+		"sfu":  wsService.webrtc.GetSFUStats(),
+		"turn": GetTURNStats(),
+		"send_queues": map[string]interface{}{
+			"capacity":         config.WsSendQueueSize,
+			"policy":           config.WsSlowConsumerPolicy,
+			"total_depth":      totalDepth,
+			"max_depth":        maxDepth,
+			"dropped_messages": atomic.LoadUint64(&wsService.sendStats.dropped),
+			"slow_disconnects": atomic.LoadUint64(&wsService.sendStats.slowDisconnects),
+			"write_errors":     atomic.LoadUint64(&wsService.sendStats.writeErrors),
+		},
+		"active_broadcasts": wsService.activeBroadcastList(),
 	}
 }
\ No newline at end of file
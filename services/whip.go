@@ -0,0 +1,422 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"toysgo/auth"
+	"toysgo/config"
+
+	"github.com/pion/webrtc/v3"
+)
+
+// 방송 송출 경로
+const (
+	BroadcastIngestWebSocket = "websocket" // 시그널링 소켓으로 시작한 방송
+	BroadcastIngestWHIP      = "whip"      // WHIP(HTTP)로 송출하는 방송
+)
+
+// WHIP/WHEP 리소스 종류 (/whip/:stream/:resource, /whep/:stream/:resource)
+const (
+	WHIPKindIngest   = "whip"
+	WHIPKindPlayback = "whep"
+)
+
+var (
+	ErrWHIPForbidden   = errors.New("본인 스트림만 송출할 수 있습니다")
+	ErrWHIPConflict    = errors.New("이미 시그널링 소켓으로 방송 중입니다")
+	ErrWHIPNotFound    = errors.New("WHIP/WHEP 세션을 찾을 수 없습니다")
+	ErrWHEPUnavailable = errors.New("SFU 방송을 찾을 수 없습니다")
+	ErrWHEPForbidden   = errors.New("방송을 시청할 권한이 없습니다")
+	ErrWHEPFull        = errors.New("최대 시청자 수에 도달했습니다")
+)
+
+// WHIP 송출 또는 WHEP 시청 세션 하나 (서비스 뮤텍스로 보호됨)
+type whipResource struct {
+	id            string
+	kind          string
+	broadcasterID string
+	peerID        string
+
+	// 리소스를 만든 사용자 (익명 시청자는 빈 문자열)
+	userID string
+
+	// WHEP 시청자
+	viewerID string
+	viewer   *ViewerInfo
+}
+
+// WHIP 송출 방송 여부 (방송자 시그널링 소켓 없이 진행됨)
+func (broadcast *BroadcastInfo) isWHIP() bool {
+	return broadcast.Ingest == BroadcastIngestWHIP
+}
+
+// Authorization 헤더의 Bearer 토큰으로 사용자 확인
+// 토큰이 없을 때 anonymous가 true면 익명 사용자로 허용한다.
+func authenticateBearer(authorization string, role string, anonymous bool) (*Connection, error) {
+	if authorization == "" {
+		if !anonymous {
+			return nil, ErrAuthRequired
+		}
+		return &Connection{
+			UserID: fmt.Sprintf("anonymous_%d", time.Now().UnixNano()),
+			Role:   role,
+		}, nil
+	}
+
+	if !strings.HasPrefix(authorization, "Bearer ") {
+		return nil, ErrAuthInvalid
+	}
+	_, user, err := auth.Authenticate(authorization)
+	if err != nil {
+		fmt.Printf("❌ 토큰 검증 실패 (%s): %v\n", role, err)
+		return nil, ErrAuthInvalid
+	}
+
+	return &Connection{
+		UserID:        fmt.Sprintf("%d", user.Id),
+		Name:          user.Name,
+		Role:          role,
+		Authenticated: true,
+	}, nil
+}
+
+// WHIP 송출 시작 (방송자의 Offer를 받아 리소스 ID와 Answer 반환)
+// 이미 WHIP으로 송출 중이면 송출 연결만 교체하고 시청자는 유지한다.
+// data는 제목, 카테고리, 공개 범위 등 방송 시작 시 적용할 메타데이터다.
+func (wsService *WebSocketService) PublishWHIP(authorization string, stream string, offer string, data map[string]interface{}) (string, string, error) {
+	connection, err := authenticateBearer(authorization, "broadcaster", false)
+	if err != nil {
+		return "", "", err
+	}
+	if connection.UserID != stream {
+		return "", "", ErrWHIPForbidden
+	}
+
+	// 메타데이터와 공개 범위 검증
+	metadata := BroadcastMetadata{}
+	broadcast := &BroadcastInfo{}
+	update, err := parseMetadataUpdate(data)
+	if err == nil {
+		err = metadata.apply(update)
+	}
+	if err == nil {
+		var accessUpdate *broadcastAccessUpdate
+		if accessUpdate, err = parseAccessUpdate(data); err == nil {
+			err = broadcast.applyAccess(accessUpdate)
+		}
+	}
+	if err != nil {
+		return "", "", err
+	}
+
+	wsService.loadModeration(stream)
+
+	wsService.Mutex.RLock()
+	existing := wsService.ActiveBroadcasts[stream]
+	wsService.Mutex.RUnlock()
+	if existing != nil && !existing.isWHIP() {
+		return "", "", ErrWHIPConflict
+	}
+
+	// ICE 후보 수집을 기다리므로 잠금 밖에서 연결 생성
+	answer, err := wsService.webrtc.Publish(stream, offer)
+	if err != nil {
+		fmt.Printf("❌ WHIP 송출 실패 (%s): %v\n", stream, err)
+		return "", "", err
+	}
+
+	resource := &whipResource{
+		id:            newResumeToken(),
+		kind:          WHIPKindIngest,
+		broadcasterID: stream,
+		peerID:        publisherPeerID(stream),
+		userID:        connection.UserID,
+	}
+
+	wsService.Mutex.Lock()
+	defer wsService.Mutex.Unlock()
+
+	existing = wsService.ActiveBroadcasts[stream]
+	if existing != nil && !existing.isWHIP() {
+		return "", "", ErrWHIPConflict
+	}
+
+	if existing != nil {
+		for id, previous := range wsService.whipResources {
+			if previous.kind == WHIPKindIngest && previous.broadcasterID == stream {
+				delete(wsService.whipResources, id)
+			}
+		}
+		fmt.Printf("🔁 WHIP 송출 교체: %s\n", stream)
+	} else {
+		broadcast.BroadcasterID = stream
+		broadcast.BroadcasterName = connection.Name
+		broadcast.StartTime = time.Now()
+		broadcast.ViewerCount = 0
+		broadcast.IsLive = true
+		broadcast.Status = BroadcastStatusLive
+		broadcast.BroadcastMetadata = metadata
+		broadcast.Mode = BroadcastModeSFU
+		broadcast.Ingest = BroadcastIngestWHIP
+		broadcast.resumeToken = newResumeToken()
+		broadcast.chat = newChatRoom()
+		broadcast.MaxViewers = normalizeMaxViewers(config.MaxViewers)
+		wsService.registerBroadcast(broadcast)
+	}
+
+	wsService.whipResources[resource.id] = resource
+	fmt.Printf("📥 WHIP 송출 시작: %s (리소스 %s)\n", stream, resource.id)
+	return resource.id, answer, nil
+}
+
+// WHEP 시청 시작 (시청자의 Offer를 받아 리소스 ID와 Answer 반환)
+// 비공개 방송은 초대 토큰(invite)이나 매니저 권한이 있어야 하며, 빈 자리가 없으면 대기하지 않고 거부한다.
+func (wsService *WebSocketService) PlayWHEP(authorization string, stream string, offer string, invite string) (string, string, error) {
+	connection, err := authenticateBearer(authorization, "viewer", config.AllowAnonymousViewer)
+	if err != nil {
+		return "", "", err
+	}
+
+	resource := &whipResource{
+		id:            newResumeToken(),
+		kind:          WHIPKindPlayback,
+		broadcasterID: stream,
+	}
+	resource.viewerID = "whep:" + resource.id
+	resource.peerID = subscriberPeerID(stream, resource.viewerID)
+	if connection.Authenticated {
+		resource.userID = connection.UserID
+	}
+	// 강퇴 등으로 연결을 닫으면 SFU 구독도 끊음
+	connection.onClose = func() {
+		wsService.webrtc.Unsubscribe(stream, resource.viewerID)
+	}
+	resource.viewer = &ViewerInfo{
+		Connection:    connection,
+		BroadcasterID: stream,
+		JoinTime:      time.Now(),
+		invite:        invite,
+		admitted:      true,
+	}
+
+	// 자리를 먼저 잡아 두고 연결 생성
+	wsService.Mutex.Lock()
+	broadcast := wsService.ActiveBroadcasts[stream]
+	switch {
+	case broadcast == nil || broadcast.Mode != BroadcastModeSFU:
+		err = ErrWHEPUnavailable
+	case wsService.isBanned(stream, connection.UserID):
+		err = ErrWHEPForbidden
+	case wsService.checkAccess(broadcast, resource.viewer, "", invite) != "":
+		err = ErrWHEPForbidden
+	case !wsService.hasCapacity(broadcast):
+		err = ErrWHEPFull
+	}
+	if err != nil {
+		wsService.Mutex.Unlock()
+		return "", "", err
+	}
+
+	if broadcast.whep == nil {
+		broadcast.whep = make(map[string]*ViewerInfo)
+	}
+	broadcast.whep[resource.viewerID] = resource.viewer
+	wsService.whipResources[resource.id] = resource
+	broadcast.ViewerCount++
+	wsService.recordViewerJoin(resource.viewer, broadcast)
+	wsService.updateViewerCount(stream, broadcast.ViewerCount)
+	wsService.Mutex.Unlock()
+
+	answer, err := wsService.webrtc.SubscribeWithOffer(stream, resource.viewerID, offer)
+	if err != nil {
+		fmt.Printf("❌ WHEP 시청 연결 실패 (%s -> %s): %v\n", stream, resource.viewerID, err)
+		wsService.Mutex.Lock()
+		wsService.dropWHEPViewer(resource)
+		wsService.Mutex.Unlock()
+		return "", "", err
+	}
+
+	// 연결을 만드는 동안 방송이 끝났으면 정리
+	wsService.Mutex.RLock()
+	current := wsService.whipResources[resource.id] == resource
+	wsService.Mutex.RUnlock()
+	if !current {
+		wsService.webrtc.Unsubscribe(stream, resource.viewerID)
+		return "", "", ErrWHEPUnavailable
+	}
+
+	fmt.Printf("📤 WHEP 시청 시작: %s -> %s\n", resource.viewerID, stream)
+	return resource.id, answer, nil
+}
+
+// WHIP/WHEP 리소스에 ICE 후보 추가 (trickle ICE, application/trickle-ice-sdpfrag)
+func (wsService *WebSocketService) PatchWHIPResource(authorization string, kind string, stream string, resourceID string, fragment string) error {
+	wsService.Mutex.RLock()
+	resource, err := wsService.authorizeWHIPResource(authorization, kind, stream, resourceID)
+	wsService.Mutex.RUnlock()
+	if err != nil {
+		return err
+	}
+
+	for _, candidate := range candidatesFromSDPFragment(fragment) {
+		if err := wsService.webrtc.AddSFUCandidate(resource.broadcasterID, resource.viewerID, candidate); err != nil {
+			fmt.Printf("❌ %s ICE 후보 처리 실패 (%s): %v\n", strings.ToUpper(kind), resource.id, err)
+			if err == ErrSFUPeerNotFound {
+				return ErrWHIPNotFound
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+// WHIP/WHEP 리소스 종료 (WHIP은 방송 종료, WHEP은 시청 종료)
+func (wsService *WebSocketService) DeleteWHIPResource(authorization string, kind string, stream string, resourceID string) error {
+	wsService.Mutex.Lock()
+	resource, err := wsService.authorizeWHIPResource(authorization, kind, stream, resourceID)
+	if err != nil {
+		wsService.Mutex.Unlock()
+		return err
+	}
+
+	if resource.kind == WHIPKindPlayback {
+		fmt.Printf("👋 WHEP 시청 종료: %s <- 방송 %s\n", resource.viewerID, stream)
+		wsService.dropWHEPViewer(resource)
+		wsService.Mutex.Unlock()
+		return nil
+	}
+	wsService.Mutex.Unlock()
+
+	fmt.Printf("📥 WHIP 송출 종료: %s\n", stream)
+	wsService.stopBroadcast(stream)
+	return nil
+}
+
+// 리소스 조회와 권한 확인 (호출자가 잠금을 보유해야 함)
+// 리소스를 만든 사용자와 같은 토큰이어야 하며, 익명 시청자는 리소스 주소만으로 허용한다.
+func (wsService *WebSocketService) authorizeWHIPResource(authorization string, kind string, stream string, resourceID string) (*whipResource, error) {
+	resource := wsService.whipResources[resourceID]
+	if resource == nil || resource.kind != kind || resource.broadcasterID != stream {
+		return nil, ErrWHIPNotFound
+	}
+	if resource.userID == "" {
+		return resource, nil
+	}
+
+	connection, err := authenticateBearer(authorization, kind, false)
+	if err != nil {
+		return nil, err
+	}
+	if connection.UserID != resource.userID {
+		return nil, ErrWHIPForbidden
+	}
+	return resource, nil
+}
+
+// WHEP 시청자 제거 후 빈 자리 정리 (호출자가 잠금을 보유해야 함)
+func (wsService *WebSocketService) dropWHEPViewer(resource *whipResource) {
+	delete(wsService.whipResources, resource.id)
+	resource.viewer.Connection.Shutdown()
+
+	broadcast := wsService.ActiveBroadcasts[resource.broadcasterID]
+	if broadcast == nil || broadcast.whep[resource.viewerID] != resource.viewer {
+		return
+	}
+	delete(broadcast.whep, resource.viewerID)
+	wsService.recordViewerLeave(resource.viewer)
+
+	if broadcast.ViewerCount > 0 {
+		broadcast.ViewerCount--
+		wsService.updateViewerCount(resource.broadcasterID, broadcast.ViewerCount)
+	}
+	wsService.admitWaiting(resource.broadcasterID)
+}
+
+// 사용자의 WHEP 시청 세션을 모두 끊음 (강퇴/차단용, 호출자가 잠금을 보유해야 함)
+// viewerID는 WHEP 시청자 ID(whep:...) 또는 사용자 ID이며, 끊은 세션이 없으면 false를 반환한다.
+func (wsService *WebSocketService) kickWHEPViewer(broadcasterID string, viewerID string) bool {
+	kicked := false
+	for _, resource := range wsService.whipResources {
+		if resource.kind != WHIPKindPlayback || resource.broadcasterID != broadcasterID {
+			continue
+		}
+		if resource.viewerID != viewerID && resource.viewer.Connection.UserID != viewerID {
+			continue
+		}
+		fmt.Printf("👢 WHEP 시청 종료: %s <- 방송 %s\n", resource.viewerID, broadcasterID)
+		wsService.dropWHEPViewer(resource)
+		kicked = true
+	}
+	return kicked
+}
+
+// 방송 종료 시 WHIP/WHEP 리소스 정리 (연결은 SFU 세션과 함께 닫힘, 호출자가 잠금을 보유해야 함)
+func (wsService *WebSocketService) closeWHIPResources(broadcast *BroadcastInfo) {
+	for id, resource := range wsService.whipResources {
+		if resource.broadcasterID == broadcast.BroadcasterID {
+			delete(wsService.whipResources, id)
+		}
+	}
+	for _, viewer := range broadcast.whep {
+		wsService.recordViewerLeave(viewer)
+	}
+	broadcast.whep = nil
+}
+
+// ICE 연결 실패로 서버 연결이 닫혔을 때 WHIP/WHEP 세션 정리
+func (wsService *WebSocketService) handlePeerClosed(peerID string) {
+	wsService.Mutex.Lock()
+	var resource *whipResource
+	for _, r := range wsService.whipResources {
+		if r.peerID == peerID {
+			resource = r
+			break
+		}
+	}
+	if resource == nil {
+		wsService.Mutex.Unlock()
+		return
+	}
+
+	if resource.kind == WHIPKindPlayback {
+		fmt.Printf("💀 WHEP 시청 연결 끊김: %s <- 방송 %s\n", resource.viewerID, resource.broadcasterID)
+		wsService.dropWHEPViewer(resource)
+		wsService.Mutex.Unlock()
+		return
+	}
+	wsService.Mutex.Unlock()
+
+	fmt.Printf("💀 WHIP 송출 연결 끊김: %s\n", resource.broadcasterID)
+	wsService.stopBroadcast(resource.broadcasterID)
+}
+
+// trickle-ice-sdpfrag 본문에서 ICE 후보 추출 (a=mid와 m= 줄 순서로 후보의 미디어를 지정)
+func candidatesFromSDPFragment(fragment string) []webrtc.ICECandidateInit {
+	candidates := make([]webrtc.ICECandidateInit, 0)
+
+	var mid *string
+	var lineIndex *uint16
+	mediaCount := 0
+	for _, line := range strings.Split(fragment, "\n") {
+		line = strings.TrimSpace(line)
+		switch {
+		case strings.HasPrefix(line, "m="):
+			index := uint16(mediaCount)
+			lineIndex = &index
+			mid = nil
+			mediaCount++
+		case strings.HasPrefix(line, "a=mid:"):
+			value := strings.TrimPrefix(line, "a=mid:")
+			mid = &value
+		case strings.HasPrefix(line, "a=candidate:"):
+			candidates = append(candidates, webrtc.ICECandidateInit{
+				Candidate:     strings.TrimPrefix(line, "a="),
+				SDPMid:        mid,
+				SDPMLineIndex: lineIndex,
+			})
+		}
+	}
+	return candidates
+}